@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -100,6 +101,64 @@ do something`
 	}
 }
 
+func TestParseParallelConfig_PerTaskAgents(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+	config.ResetModelsConfigCacheForTest()
+
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "default_backend": "codex",
+  "default_model": "gpt-test",
+  "agents": {
+    "explore": {"backend": "opencode", "model": "oc-model"},
+    "develop": {"backend": "codex", "model": "gpt-dev", "reasoning": "high"}
+  }
+}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := parseParallelConfig([]byte(`---TASK---
+id: a
+agent: explore
+---CONTENT---
+look around
+---TASK---
+id: b
+agent: develop
+---CONTENT---
+build it
+---TASK---
+id: c
+---CONTENT---
+plain task`))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() unexpected error: %v", err)
+	}
+	a, b, c := cfg.Tasks[0], cfg.Tasks[1], cfg.Tasks[2]
+	if a.Backend != "opencode" || a.Model != "oc-model" {
+		t.Fatalf("task a = %s/%s, want opencode/oc-model", a.Backend, a.Model)
+	}
+	if b.Backend != "codex" || b.Model != "gpt-dev" || b.ReasoningEffort != "high" {
+		t.Fatalf("task b = %s/%s/%s, want codex/gpt-dev/high", b.Backend, b.Model, b.ReasoningEffort)
+	}
+	// Tasks without an agent keep an empty backend so the run-level one applies.
+	if c.Agent != "" || c.Backend != "" {
+		t.Fatalf("task c = agent %q backend %q, want both empty", c.Agent, c.Backend)
+	}
+
+	// An unknown agent is a config error rather than a silent fallback.
+	_, err = parseParallelConfig([]byte("---TASK---\nid: a\nagent: typo\n---CONTENT---\nx"))
+	if err == nil || !strings.Contains(err.Error(), `agent "typo" not found`) {
+		t.Fatalf("unknown agent error = %v, want not found", err)
+	}
+}
+
 func TestDefaultRunCodexTaskFn_AppliesAgentPromptFile(t *testing.T) {
 	defer resetTestHooks()
 
@@ -148,3 +207,45 @@ func TestDefaultRunCodexTaskFn_AppliesAgentPromptFile(t *testing.T) {
 		t.Fatalf("stdin mismatch:\n got=%q\nwant=%q", got, want)
 	}
 }
+
+func TestDefaultRunCodexTaskFn_MissingAgentPromptFile(t *testing.T) {
+	defer resetTestHooks()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	var gotArgs []string
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		gotArgs = args
+		return newFakeCmd(fakeCmdConfig{
+			StdoutPlan: []fakeStdoutEvent{
+				{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}` + "\n"},
+			},
+			WaitDelay: 2 * time.Millisecond,
+		})
+	})
+	_ = executor.SetSelectBackendFn(func(name string) (Backend, error) {
+		return testBackend{
+			name:    name,
+			command: "fake-cmd",
+			argsFn: func(cfg *Config, targetArg string) []string {
+				return []string{targetArg}
+			},
+		}, nil
+	})
+
+	task := TaskSpec{ID: "t", Task: "do", Backend: "codex", PromptFile: "~/.claude/missing.md"}
+	if res := defaultRunCodexTaskFn(task, 5); res.ExitCode == 0 {
+		t.Fatalf("explicit prompt_file that does not exist should fail: %+v", res)
+	}
+
+	task.Agent = "oracle"
+	res := defaultRunCodexTaskFn(task, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("missing agent prompt file should only warn: %+v", res)
+	}
+	if len(gotArgs) != 1 || gotArgs[0] != "do" {
+		t.Fatalf("args = %q, want the raw task", gotArgs)
+	}
+}
@@ -1,6 +1,7 @@
 package wrapper
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -26,6 +27,10 @@ const (
 	stdoutCloseReasonDrain = "drain-timeout"
 	stdoutCloseReasonCtx   = "context-cancel"
 	stdoutDrainTimeout     = 500 * time.Millisecond
+
+	// exitConfigError (EX_CONFIG from sysexits.h) reports an invalid parallel
+	// task configuration, as opposed to a task that ran and failed.
+	exitConfigError = 78
 )
 
 // Test hooks for dependency injection
@@ -39,11 +44,51 @@ var (
 	buildCodexArgsFn   = buildCodexArgs
 	selectBackendFn    = selectBackend
 	cleanupLogsFn      = cleanupOldLogs
+	trimLogsFn         = trimLogsToLimit
+	latestLogFn        = latestLogPath
 	defaultBuildArgsFn = buildCodexArgs
 	runTaskFn          = runCodexTask
 	exitFn             = os.Exit
 )
 
+// logAlsoStderr mirrors the async log to stderr as entries are written
+// (--log-also-stderr).
+var logAlsoStderr bool
+
+// maxRetainedLogs caps how many wrapper logs survive cleanup (--max-logs).
+// Zero disables count-based retention.
+var maxRetainedLogs int
+
+// cleanupLogs runs orphan cleanup and, when maxLogs is positive, trims the
+// remaining logs down to the newest maxLogs.
+func cleanupLogs(cleanup func() (CleanupStats, error), trim func(int) (CleanupStats, error), maxLogs int) (CleanupStats, error) {
+	stats, err := cleanup()
+	if err != nil || maxLogs <= 0 || trim == nil {
+		return stats, err
+	}
+
+	trimmed, err := trim(maxLogs)
+	if trimmed.Deleted > 0 {
+		removed := make(map[string]struct{}, len(trimmed.DeletedFiles))
+		for _, f := range trimmed.DeletedFiles {
+			removed[f] = struct{}{}
+		}
+		kept := stats.KeptFiles[:0]
+		for _, f := range stats.KeptFiles {
+			if _, ok := removed[f]; ok {
+				stats.Kept--
+				continue
+			}
+			kept = append(kept, f)
+		}
+		stats.KeptFiles = kept
+		stats.Deleted += trimmed.Deleted
+		stats.DeletedFiles = append(stats.DeletedFiles, trimmed.DeletedFiles...)
+	}
+	stats.Errors += trimmed.Errors
+	return stats, err
+}
+
 func runStartupCleanup() {
 	if cleanupLogsFn == nil {
 		return
@@ -53,7 +98,7 @@ func runStartupCleanup() {
 			logWarn(fmt.Sprintf("cleanupOldLogs panic: %v", r))
 		}
 	}()
-	if _, err := cleanupLogsFn(); err != nil {
+	if _, err := cleanupLogs(cleanupLogsFn, trimLogsFn, maxRetainedLogs); err != nil {
 		logWarn(fmt.Sprintf("cleanupOldLogs error: %v", err))
 	}
 }
@@ -66,14 +111,14 @@ func scheduleStartupCleanup() {
 	if cleanupLogsFn == nil {
 		return
 	}
-	fn := cleanupLogsFn
+	fn, trim, maxLogs := cleanupLogsFn, trimLogsFn, maxRetainedLogs
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
 				logWarn(fmt.Sprintf("cleanupOldLogs panic: %v", r))
 			}
 		}()
-		if _, err := fn(); err != nil {
+		if _, err := cleanupLogs(fn, trim, maxLogs); err != nil {
 			logWarn(fmt.Sprintf("cleanupOldLogs error: %v", err))
 		}
 	}()
@@ -85,32 +130,129 @@ func runCleanupMode() int {
 		return 1
 	}
 
-	stats, err := cleanupLogsFn()
+	stats, err := cleanupLogs(cleanupLogsFn, trimLogsFn, maxRetainedLogs)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Cleanup failed: %v\n", err)
 		return 1
 	}
 
 	fmt.Println("Cleanup completed")
-	fmt.Printf("Files scanned: %d\n", stats.Scanned)
-	fmt.Printf("Files deleted: %d\n", stats.Deleted)
-	if len(stats.DeletedFiles) > 0 {
-		for _, f := range stats.DeletedFiles {
-			fmt.Printf("  - %s\n", f)
-		}
+	printCleanupStats("Files", stats)
+	return 0
+}
+
+// printCleanupStats writes the scanned/deleted/kept summary shared by
+// --cleanup and --worktree-cleanup stale; noun names what was scanned.
+func printCleanupStats(noun string, stats CleanupStats) {
+	fmt.Printf("%s scanned: %d\n", noun, stats.Scanned)
+	fmt.Printf("%s deleted: %d\n", noun, stats.Deleted)
+	for _, f := range stats.DeletedFiles {
+		fmt.Printf("  - %s\n", f)
 	}
-	fmt.Printf("Files kept: %d\n", stats.Kept)
-	if len(stats.KeptFiles) > 0 {
-		for _, f := range stats.KeptFiles {
-			fmt.Printf("  - %s\n", f)
-		}
+	fmt.Printf("%s kept: %d\n", noun, stats.Kept)
+	for _, f := range stats.KeptFiles {
+		fmt.Printf("  - %s\n", f)
 	}
 	if stats.Errors > 0 {
 		fmt.Printf("Deletion errors: %d\n", stats.Errors)
 	}
+}
+
+// runDumpLastLogMode prints the newest retained wrapper log to stdout so users
+// can attach it to bug reports (--dump-last-log).
+func runDumpLastLogMode() int {
+	if latestLogFn == nil {
+		fmt.Fprintln(os.Stderr, "ERROR: log lookup function not configured")
+		return 1
+	}
+
+	path, err := latestLogFn()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to open log %s: %v\n", path, err)
+		return 1
+	}
+	defer f.Close()
+
+	fmt.Fprintf(os.Stderr, "Log file: %s\n", path)
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to read log %s: %v\n", path, err)
+		return 1
+	}
 	return 0
 }
 
+// sandboxBypassFlags are backend arguments that disable approvals, sandboxing
+// or permission prompts.
+var sandboxBypassFlags = []string{
+	"--dangerously-bypass-approvals-and-sandbox",
+	"--dangerously-skip-permissions",
+}
+
+func usesSandboxBypass(args []string) bool {
+	for _, arg := range args {
+		for _, flag := range sandboxBypassFlags {
+			if arg == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// confirmBypassRun asks on stderr whether to run backend with its bypass
+// enabled and reads the answer from stdin (--confirm). Only "y"/"yes" proceed.
+func confirmBypassRun(backend, workdir string) bool {
+	dir := workdir
+	if abs, err := filepath.Abs(workdir); err == nil {
+		dir = abs
+	}
+	fmt.Fprintf(os.Stderr, "Run %s with bypass in %s? [y/N] ", backend, dir)
+
+	answer, err := bufio.NewReader(stdinReader).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveClaudeAllowFile expands ~ and returns the absolute path of an
+// existing regular settings file, so claude finds it regardless of workdir.
+func resolveClaudeAllowFile(path string) (string, error) {
+	expanded := strings.TrimSpace(path)
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") || strings.HasPrefix(expanded, "~\\") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		expanded = home + expanded[1:]
+	}
+
+	absPath, err := filepath.Abs(expanded)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file: %s", absPath)
+	}
+	return absPath, nil
+}
+
 func readAgentPromptFile(path string, allowOutsideClaudeDir bool) (string, error) {
 	raw := strings.TrimSpace(path)
 	if raw == "" {
@@ -253,9 +395,12 @@ Usage:
     %[1]s - [workdir]              Read task from stdin
     %[1]s resume <session_id> "task" [workdir]
     %[1]s resume <session_id> - [workdir]
+    %[1]s resume-last "task" [workdir]  Resume the latest session recorded for workdir
     %[1]s --parallel               Run tasks in parallel (config from stdin)
     %[1]s --parallel --full-output Run tasks in parallel with full output (legacy)
     %[1]s --version
+    %[1]s --list-backends
+    %[1]s --check [backend...]
     %[1]s --help
 
 Parallel mode examples:
@@ -271,6 +416,9 @@ Environment Variables:
 Exit Codes:
     0    Success
     1    General error (missing args, no output)
+    66   Workdir does not exist
+    75   Too many backend reconnects (CODEAGENT_MAX_RECONNECTS)
+    78   Invalid parallel configuration (parse error, cycle, missing dependency)
     124  Timeout
     127  backend command not found
     130  Interrupted (Ctrl+C)
@@ -1,7 +1,11 @@
 package wrapper
 
-import backend "codeagent-wrapper/internal/backend"
+import (
+	backend "codeagent-wrapper/internal/backend"
+	config "codeagent-wrapper/internal/config"
+)
 
 func init() {
 	backend.SetLogFuncs(logWarn, logError)
+	config.SetLogWarnFunc(logWarn)
 }
@@ -3,3 +3,5 @@ package wrapper
 import backend "codeagent-wrapper/internal/backend"
 
 func selectBackend(name string) (Backend, error) { return backend.Select(name) }
+
+func resolveBackendCommand(name string) string { return backend.ResolveCommand(name) }
@@ -0,0 +1,105 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	backend "codeagent-wrapper/internal/backend"
+)
+
+// checkProbeTimeout bounds each command --check runs.
+const checkProbeTimeout = 10 * time.Second
+
+// backendAuthProbes lists, per backend, the arguments of a command that
+// exits non-zero when the CLI is not logged in. Backends without one are
+// only checked for a working binary.
+var backendAuthProbes = map[string][]string{
+	"codex": {"login", "status"},
+}
+
+// runCheckMode implements --check [backend...]: for every named backend (all
+// registered ones when none are given) it resolves the binary on PATH, runs
+// "<binary> --version" and, where available, an auth status command, then
+// prints one status line per backend. It returns 1 if any is unavailable.
+func runCheckMode(w io.Writer, names []string) int {
+	if len(names) == 0 {
+		for name := range backend.Registry() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	backends := make([]Backend, 0, len(names))
+	for _, name := range names {
+		b, err := selectBackendFn(strings.TrimSpace(name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		backends = append(backends, b)
+	}
+
+	exitCode := 0
+	for _, b := range backends {
+		status, detail := checkBackend(b)
+		fmt.Fprintf(w, "%s: %s (%s)\n", b.Name(), status, detail)
+		if status != "OK" {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// checkBackend returns OK, MISSING, BROKEN or UNAUTHENTICATED with the
+// resolved binary path and what the probe reported.
+func checkBackend(b Backend) (status, detail string) {
+	command := resolveBackendCommand(b.Command())
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "MISSING", fmt.Sprintf("command %q not found on PATH", command)
+	}
+
+	versionOut, err := runCheckProbe(path, "--version")
+	if err != nil {
+		return "BROKEN", fmt.Sprintf("%s --version: %s", path, err)
+	}
+	detail = path
+	if versionOut != "" {
+		detail = fmt.Sprintf("%s, %s", path, versionOut)
+	}
+
+	if args, ok := backendAuthProbes[b.Name()]; ok {
+		if _, err := runCheckProbe(path, args...); err != nil {
+			return "UNAUTHENTICATED", fmt.Sprintf("%s; %s %s: %s", detail, path, strings.Join(args, " "), err)
+		}
+	}
+	return "OK", detail
+}
+
+// runCheckProbe runs path with args and returns the first line of its
+// output; a failure is reported with that line (or the exit status).
+func runCheckProbe(path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkProbeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	first, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	first = safeTruncate(strings.TrimSpace(first), 80)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return first, fmt.Errorf("timed out after %s", checkProbeTimeout)
+	}
+	if err != nil {
+		if first != "" {
+			return first, errors.New(first)
+		}
+		return first, err
+	}
+	return first, nil
+}
@@ -1,12 +1,15 @@
 package wrapper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	config "codeagent-wrapper/internal/config"
 
@@ -28,18 +31,68 @@ type cliOptions struct {
 	Model           string
 	ReasoningEffort string
 	Agent           string
+	Pipeline        string
 	PromptFile      string
+	SystemPrompt    string
+	Workdir         string
 	Output          string
+	StatusFile      string
 	Skills          string
 	SkipPermissions bool
 	Worktree        bool
+	WorktreeCleanup string
+
+	JSONStreamPassthrough bool
+	ClaudeAllow           string
+	InterruptFile         string
+	ResumeFile            string
+	Retries               int
+	RetryBackoff          string
+	RetryBase             time.Duration
+	TaskOrder             string
+	FailOnTurnFailed      bool
+	FailIfNoFilesChanged  bool
+	StreamJSONValidate    bool
+	AdaptiveConcurrency   bool
+	FailFast              bool
+	MaxParallel           int
+	TaskJSON              string
+	TaskField             string
+	TaskTemplate          string
+	TemplateVars          []string
+	Confirm               bool
+	AppendWorkdirContext  bool
+	Quiet                 bool
+	CopySession           bool
+	StripControl          bool
+	ResumeWorkdir         bool
+	WorkdirGitCheck       bool
+	RequireClean          bool
 
 	Parallel   bool
+	Probe      bool
+	Detach     bool
+	Attach     string
+	DryRun     bool
 	FullOutput bool
-
-	Cleanup    bool
-	Version    bool
-	ConfigFile string
+	JSON       bool
+
+	MaxMessageLines int
+	Timeout         int
+
+	CacheDir     string
+	CacheRefresh bool
+	NoCacheWrite bool
+
+	Cleanup      bool
+	DumpLastLog  bool
+	MaxLogs      int
+	Profile      bool
+	LogStderr    bool
+	Version      bool
+	ListBackends bool
+	Check        bool
+	ConfigFile   string
 }
 
 func Main() {
@@ -75,8 +128,24 @@ func newRootCommand() *cobra.Command {
 		SilenceUsage:  true,
 		Args:          cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.MaxLogs < 0 {
+				fmt.Fprintln(os.Stderr, "ERROR: --max-logs must be >= 0")
+				return exitError{code: 1}
+			}
+			maxRetainedLogs = opts.MaxLogs
+			logAlsoStderr = opts.LogStderr
+
 			if opts.Version {
-				fmt.Printf("%s version %s\n", name, version)
+				return printVersion(os.Stdout, name, opts.JSON)
+			}
+			if opts.ListBackends {
+				printBackends(os.Stdout)
+				return nil
+			}
+			if opts.Check {
+				if code := runCheckMode(os.Stdout, args); code != 0 {
+					return exitError{code: code}
+				}
 				return nil
 			}
 			if opts.Cleanup {
@@ -86,26 +155,76 @@ func newRootCommand() *cobra.Command {
 				}
 				return exitError{code: code}
 			}
+			if cmd.Flags().Changed("attach") {
+				if opts.Detach || len(args) > 0 {
+					fmt.Fprintln(os.Stderr, "ERROR: --attach takes only a handle; it cannot be combined with --detach or a task")
+					return exitError{code: 1}
+				}
+				if code := runAttachMode(opts.Attach); code != 0 {
+					return exitError{code: code}
+				}
+				return nil
+			}
+			if cmd.Flags().Changed("worktree-cleanup") {
+				if code := runWorktreeCleanupMode(opts.WorktreeCleanup, args); code != 0 {
+					return exitError{code: code}
+				}
+				return nil
+			}
+			if opts.DumpLastLog {
+				if code := runDumpLastLogMode(); code != 0 {
+					return exitError{code: code}
+				}
+				return nil
+			}
+
+			if opts.Profile {
+				startupProfiler = newPhaseProfile()
+				defer func() {
+					startupProfiler.writeReport(os.Stderr)
+					startupProfiler = nil
+				}()
+			}
 
+			stopLoggerInit := startupProfiler.track("logger init")
 			exitCode := runWithLoggerAndCleanup(func() int {
+				stopLoggerInit()
+
+				stopArgParse := startupProfiler.track("arg parse")
 				v, err := config.NewViper(opts.ConfigFile)
 				if err != nil {
 					logError(err.Error())
 					return 1
 				}
 
-				if opts.Parallel {
+				if opts.Parallel || cmd.Flags().Changed("resume-file") {
 					return runParallelMode(cmd, args, opts, v, name)
 				}
+				if opts.Detach && (opts.Probe || cmd.Flags().Changed("pipeline")) {
+					logError("--detach is not supported with --probe or --pipeline")
+					return 1
+				}
+				if opts.Probe {
+					stopArgParse()
+					return runProbeMode(cmd, args, opts, v, name)
+				}
+				if cmd.Flags().Changed("pipeline") {
+					stopArgParse()
+					return runPipelineMode(cmd, args, opts, v, name)
+				}
 
 				logInfo("Script started")
 
 				cfg, err := buildSingleConfig(cmd, args, os.Args[1:], opts, v)
+				stopArgParse()
 				if err != nil {
 					logError(err.Error())
 					return 1
 				}
 				logInfo(fmt.Sprintf("Parsed args: mode=%s, task_len=%d, backend=%s", cfg.Mode, len(cfg.Task), cfg.Backend))
+				if opts.Detach {
+					return runDetached(cfg, os.Args[1:])
+				}
 				return runSingleMode(cfg, name)
 			})
 
@@ -126,35 +245,84 @@ func newRootCommand() *cobra.Command {
 func addRootFlags(fs *pflag.FlagSet, opts *cliOptions) {
 	fs.StringVar(&opts.ConfigFile, "config", "", "Config file path (default: $HOME/.codeagent/config.*)")
 	fs.BoolVarP(&opts.Version, "version", "v", false, "Print version and exit")
+	fs.BoolVar(&opts.ListBackends, "list-backends", false, "Print the available backends with their command and sample args, then exit")
+	fs.BoolVar(&opts.Check, "check", false, "Check that the named backends (default: all) are installed and logged in, then exit")
 	fs.BoolVar(&opts.Cleanup, "cleanup", false, "Clean up old logs and exit")
+	fs.BoolVar(&opts.DumpLastLog, "dump-last-log", false, "Print the most recent retained wrapper log to stdout and exit")
+	fs.IntVar(&opts.MaxLogs, "max-logs", 0, "Keep at most N wrapper logs after cleanup (0 = unlimited)")
+	fs.BoolVar(&opts.LogStderr, "log-also-stderr", false, "Mirror log entries to stderr in addition to the log file")
+	fs.BoolVar(&opts.Profile, "profile", false, "Print wrapper phase timings to stderr on exit")
 
 	fs.BoolVar(&opts.Parallel, "parallel", false, "Run tasks in parallel (config from stdin)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Print the resolved backend command, workdir and stdin decision without running it")
+	fs.BoolVar(&opts.Detach, "detach", false, "Start the run in the background, print a handle file and exit immediately")
+	fs.StringVar(&opts.Attach, "attach", "", "Wait for a --detach run (handle file or directory), streaming its log, then print its result")
+	fs.BoolVar(&opts.Probe, "probe", false, "Send a trivial task to the backend and report whether it answered, with timing")
 	fs.BoolVar(&opts.FullOutput, "full-output", false, "Parallel mode: include full task output (legacy)")
-
-	fs.StringVar(&opts.Backend, "backend", defaultBackendName, "Backend to use (codex, claude, gemini, opencode)")
+	fs.IntVar(&opts.Timeout, "timeout", 0, "Task timeout in seconds, values above 10000 are read as milliseconds (overrides CODEX_TIMEOUT)")
+	fs.IntVar(&opts.MaxMessageLines, "max-message-lines", 0, "Print at most N lines of each message to stdout (0 = no cap); --output keeps the full text")
+	fs.StringVar(&opts.CacheDir, "cache-dir", "", "Reuse results of identical successful tasks (backend, model, workdir and task text) from this directory")
+	fs.BoolVar(&opts.CacheRefresh, "refresh", false, "With --cache-dir: ignore cached results but store the new ones")
+	fs.BoolVar(&opts.NoCacheWrite, "no-cache-write", false, "With --cache-dir: use cached results but do not store new ones")
+	fs.BoolVar(&opts.JSON, "json", false, "Parallel mode: print results and summary to stdout as JSON instead of the text report; with --version, print build metadata as JSON")
+	fs.BoolVar(&opts.FailOnTurnFailed, "fail-on-turn-failed", false, "Fail tasks whose stream reported turn.failed/error events even if the backend exited 0")
+	fs.BoolVar(&opts.FailIfNoFilesChanged, "fail-if-no-files-changed", false, "Fail tasks whose stream reported no file_change events")
+	fs.BoolVar(&opts.StreamJSONValidate, "stream-json-validate", false, "Log a warning for backend events missing fields expected for their type")
+	fs.IntVar(&opts.Retries, "retries", 0, "Retry failed tasks up to N times (also via CODEAGENT_RETRIES)")
+	fs.StringVar(&opts.RetryBackoff, "retry-backoff", "", "Pause curve between retries: fixed, linear or exponential (default fixed)")
+	fs.DurationVar(&opts.RetryBase, "retry-base", 0, "Base pause between retries, e.g. 2s (default 1s)")
+	fs.StringVar(&opts.TaskOrder, "task-order", "declared", "Parallel mode: start order within a layer (declared, dependency, id)")
+	fs.StringVar(&opts.InterruptFile, "interrupt-file", "", "Parallel mode: abort the run when this file appears")
+	fs.StringVar(&opts.ResumeFile, "resume-file", "", "Resume many sessions in parallel from a file of session_id<TAB>task lines or a JSON array")
+	fs.BoolVar(&opts.AdaptiveConcurrency, "adaptive-concurrency", false, "Parallel mode: lower concurrency on rate-limit failures and ramp back up on success")
+	fs.BoolVar(&opts.FailFast, "fail-fast", false, "Parallel mode: cancel running tasks and skip the rest as soon as one task fails")
+	fs.IntVar(&opts.MaxParallel, "max-parallel", 0, "Parallel mode: run at most N tasks at once (0 = unlimited; also via CODEAGENT_MAX_PARALLEL)")
+
+	fs.StringVar(&opts.Backend, "backend", defaultBackendName, "Backend to use (codex, claude, gemini, opencode); comma-separate for a fallback chain")
 	fs.StringVar(&opts.Model, "model", "", "Model override")
 	fs.StringVar(&opts.ReasoningEffort, "reasoning-effort", "", "Reasoning effort (backend-specific)")
 	fs.StringVar(&opts.Agent, "agent", "", "Agent preset name (from ~/.codeagent/models.json)")
+	fs.StringVar(&opts.Pipeline, "pipeline", "", "Comma-separated agents to run in sequence, each receiving the previous agent's output")
 	fs.StringVar(&opts.PromptFile, "prompt-file", "", "Prompt file path")
+	fs.StringVarP(&opts.Workdir, "workdir", "C", "", "Directory to run the backend in (overrides a positional workdir)")
+	fs.StringVar(&opts.SystemPrompt, "system-prompt", "", "Text appended to the backend's system prompt (claude --append-system-prompt; prepended to the task elsewhere)")
+	fs.StringVar(&opts.TaskJSON, "task-json", "", "Read the task from a field of this JSON file (see --task-field)")
+	fs.StringVar(&opts.TaskField, "task-field", defaultTaskJSONField, "Dot-separated field of --task-json holding the task text")
+	fs.StringVar(&opts.TaskTemplate, "task-from-template", "", "Read the task from a template file with {{name}} placeholders (see --var)")
+	fs.StringArrayVar(&opts.TemplateVars, "var", nil, "Template variable as name=value for --task-from-template (repeatable)")
 	fs.StringVar(&opts.Output, "output", "", "Write structured JSON output to file")
+	fs.StringVar(&opts.StatusFile, "status-file", "", "Atomically write a {\"ok\",\"total\",\"failed\"} status summary to file")
 	fs.StringVar(&opts.Skills, "skills", "", "Comma-separated skill names for spec injection")
+	fs.BoolVar(&opts.AppendWorkdirContext, "append-workdir-context", false, "Append a bounded tree listing of the workdir to the task")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "Do not print the startup banner to stderr (also via CODEAGENT_QUIET); the log file still records it")
+	fs.BoolVar(&opts.StripControl, "strip-control", false, "Strip terminal control sequences (cursor moves, clears, \\r redraws) from the captured message")
 
 	fs.BoolVar(&opts.SkipPermissions, "skip-permissions", false, "Skip permissions prompts (also via CODEAGENT_SKIP_PERMISSIONS)")
 	fs.BoolVar(&opts.SkipPermissions, "dangerously-skip-permissions", false, "Alias for --skip-permissions")
+	fs.BoolVar(&opts.Confirm, "confirm", false, "Ask for confirmation on a TTY before running a backend with sandbox/permission bypass")
+	fs.BoolVar(&opts.CopySession, "copy-session-to-clipboard", false, "Copy the captured session id to the system clipboard (pbcopy, wl-copy, xclip, xsel or clip.exe)")
+	fs.BoolVar(&opts.ResumeWorkdir, "resume-workdir", false, "Resume mode: default the workdir to the one recorded for the session")
+	fs.BoolVar(&opts.WorkdirGitCheck, "workdir-git-check", false, "Warn before running when the workdir has uncommitted git changes")
+	fs.BoolVar(&opts.RequireClean, "require-clean", false, "Refuse to run when the workdir has uncommitted git changes (implies --workdir-git-check)")
 	fs.BoolVar(&opts.Worktree, "worktree", false, "Execute in a new git worktree (auto-generates task ID)")
+	fs.StringVar(&opts.WorktreeCleanup, "worktree-cleanup", "", "Remove the worktree and merged branch of a --worktree task id (or every stale worktree with 'stale'), then exit")
+	fs.BoolVar(&opts.JSONStreamPassthrough, "json-stream-passthrough", false, "Forward the raw backend JSON stream to stdout instead of the final message")
+	fs.StringVar(&opts.ClaudeAllow, "claude-allow", "", "Claude settings file with curated permissions (passed as --settings)")
 }
 
 func newVersionCommand(name string) *cobra.Command {
-	return &cobra.Command{
+	var asJSON bool
+	cmd := &cobra.Command{
 		Use:           "version",
 		Short:         "Print version and exit",
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Printf("%s version %s\n", name, version)
-			return nil
+			return printVersion(os.Stdout, name, asJSON)
 		},
 	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print name, version, Go version and backends as JSON")
+	return cmd
 }
 
 func newCleanupCommand() *cobra.Command {
@@ -175,12 +343,11 @@ func newCleanupCommand() *cobra.Command {
 
 func runWithLoggerAndCleanup(fn func() int) (exitCode int) {
 	ensureExecutableTempDir()
-	logger, err := NewLogger()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize logger: %v\n", err)
-		return 1
-	}
+	logger := newLoggerWithFallback()
 	setLogger(logger)
+	if logAlsoStderr {
+		logger.MirrorTo(os.Stderr)
+	}
 
 	defer func() {
 		logger := activeLogger()
@@ -200,7 +367,9 @@ func runWithLoggerAndCleanup(fn func() int) (exitCode int) {
 				for _, entry := range entries {
 					fmt.Fprintln(os.Stderr, entry)
 				}
-				fmt.Fprintf(os.Stderr, "Log file: %s\n", logger.Path())
+				if path := logger.Path(); path != "" {
+					fmt.Fprintf(os.Stderr, "Log file: %s\n", path)
+				}
 			}
 		}
 	}()
@@ -292,6 +461,22 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		outputPath = val
 	}
 
+	statusFile, err := resolveStatusFile(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Flags().Changed("json") {
+		return nil, fmt.Errorf("--json is only supported with --parallel")
+	}
+	maxMessageLines, err := resolveMaxMessageLines(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := resolveCacheDir(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+
 	agentFlagChanged := cmd.Flags().Changed("agent")
 	backendFlagChanged := cmd.Flags().Changed("backend")
 	if backendFlagChanged {
@@ -348,6 +533,77 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		skipPermissions = v.GetBool("skip-permissions")
 	}
 
+	var claudeAllowFile string
+	if cmd.Flags().Changed("claude-allow") {
+		claudeAllowFile = strings.TrimSpace(opts.ClaudeAllow)
+		if claudeAllowFile == "" {
+			return nil, fmt.Errorf("--claude-allow flag requires a value")
+		}
+	} else {
+		claudeAllowFile = strings.TrimSpace(v.GetString("claude-allow"))
+	}
+	if claudeAllowFile != "" {
+		resolved, err := resolveClaudeAllowFile(claudeAllowFile)
+		if err != nil {
+			return nil, fmt.Errorf("--claude-allow: %w", err)
+		}
+		claudeAllowFile = resolved
+	}
+
+	retries, err := resolveRetries(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+	timeoutFlag, err := resolveTimeoutFlag(cmd, opts)
+	if err != nil {
+		return nil, err
+	}
+	retryBackoff, err := resolveRetryBackoff(cmd, opts, v)
+	if err != nil {
+		return nil, err
+	}
+
+	taskFromFile := false
+	if cmd.Flags().Changed("task-json") && cmd.Flags().Changed("task-from-template") {
+		return nil, fmt.Errorf("--task-json and --task-from-template are mutually exclusive")
+	}
+	if cmd.Flags().Changed("task-json") {
+		taskJSON := strings.TrimSpace(opts.TaskJSON)
+		if taskJSON == "" {
+			return nil, fmt.Errorf("--task-json flag requires a value")
+		}
+		task, err := loadTaskFromJSON(taskJSON, opts.TaskField)
+		if err != nil {
+			return nil, fmt.Errorf("--task-json: %w", err)
+		}
+		if args, err = insertTaskArg(args, task, "--task-json <file>"); err != nil {
+			return nil, err
+		}
+		taskFromFile = true
+	} else if cmd.Flags().Changed("task-field") {
+		return nil, fmt.Errorf("--task-field requires --task-json")
+	}
+	if cmd.Flags().Changed("task-from-template") {
+		tmplPath := strings.TrimSpace(opts.TaskTemplate)
+		if tmplPath == "" {
+			return nil, fmt.Errorf("--task-from-template flag requires a value")
+		}
+		vars, err := parseTemplateVars(opts.TemplateVars)
+		if err != nil {
+			return nil, err
+		}
+		task, err := renderTaskTemplate(tmplPath, vars)
+		if err != nil {
+			return nil, fmt.Errorf("--task-from-template: %w", err)
+		}
+		if args, err = insertTaskArg(args, task, "--task-from-template <file>"); err != nil {
+			return nil, err
+		}
+		taskFromFile = true
+	} else if cmd.Flags().Changed("var") {
+		return nil, fmt.Errorf("--var requires --task-from-template")
+	}
+
 	if len(args) == 0 {
 		return nil, fmt.Errorf("task required")
 	}
@@ -369,6 +625,7 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		PromptFile:         promptFile,
 		PromptFileExplicit: promptFileExplicit,
 		OutputPath:         outputPath,
+		StatusFile:         statusFile,
 		SkipPermissions:    skipPermissions,
 		Yolo:               yolo,
 		Model:              model,
@@ -378,17 +635,88 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 		DisallowedTools:    resolvedDisallowedTools,
 		Skills:             skills,
 		Worktree:           opts.Worktree,
+
+		JSONStreamPassthrough: opts.JSONStreamPassthrough,
+		ClaudeAllowFile:       claudeAllowFile,
+		Retries:               retries,
+		Timeout:               timeoutFlag,
+		RetryBackoff:          retryBackoff.Curve,
+		RetryBase:             retryBackoff.Base,
+		FailOnTurnFailed:      opts.FailOnTurnFailed,
+		FailIfNoFilesChanged:  opts.FailIfNoFilesChanged,
+		StreamJSONValidate:    opts.StreamJSONValidate,
+		AppendWorkdirContext:  opts.AppendWorkdirContext,
+		Confirm:               opts.Confirm,
+
+		CopySessionToClipboard: opts.CopySession,
+		StripControl:           opts.StripControl,
+		WorkdirGitCheck:        opts.WorkdirGitCheck || opts.RequireClean,
+		MaxMessageLines:        maxMessageLines,
+		DryRun:                 opts.DryRun,
+		SystemPrompt:           strings.TrimSpace(opts.SystemPrompt),
+		CacheDir:               cacheDir,
+		CacheRefresh:           opts.CacheRefresh,
+		CacheNoWrite:           opts.NoCacheWrite,
+		StreamMessages:         config.EnvFlagEnabled("CODEAGENT_STREAM") && !opts.JSONStreamPassthrough,
+		RequireClean:           opts.RequireClean,
+		Quiet:                  opts.Quiet || (!cmd.Flags().Changed("quiet") && v.GetBool("quiet")),
+	}
+
+	flagWorkdir := ""
+	if cmd.Flags().Changed("workdir") {
+		flagWorkdir = strings.TrimSpace(opts.Workdir)
+		if flagWorkdir == "" {
+			return nil, fmt.Errorf("--workdir flag requires a value")
+		}
+		if flagWorkdir == "-" {
+			return nil, fmt.Errorf("invalid --workdir: '-' is not a valid directory path")
+		}
+	}
+
+	if args[0] == "resume-last" {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("resume-last mode requires: resume-last <task> [workdir]")
+		}
+		workDir := defaultWorkdir
+		if len(args) > 2 {
+			if args[2] == "-" {
+				return nil, fmt.Errorf("invalid workdir: '-' is not a valid directory path")
+			}
+			workDir = args[2]
+		}
+		if flagWorkdir != "" {
+			workDir = flagWorkdir
+		}
+		// Sessions are recorded under the canonical backend name, so match
+		// "Claude" or a "claude,codex" chain to the backend that would run.
+		sessionBackend := cfg.Backend
+		if b, err := selectBackendFn(cfg.Backend); err == nil {
+			sessionBackend = b.Name()
+		}
+		sessionID, err := resolveLastSession(workDir, sessionBackend)
+		if err != nil {
+			return nil, err
+		}
+		args = append([]string{"resume", sessionID, args[1]}, args[2:]...)
 	}
 
 	if args[0] == "resume" {
 		if len(args) < 3 {
 			return nil, fmt.Errorf("resume mode requires: resume <session_id> <task>")
 		}
+		if cfg.Worktree {
+			return nil, fmt.Errorf("--worktree cannot be combined with resume mode; a resumed session cannot move into a new worktree")
+		}
 		cfg.Mode = "resume"
 		cfg.SessionID = strings.TrimSpace(args[1])
 		if cfg.SessionID == "" {
 			return nil, fmt.Errorf("resume mode requires non-empty session_id")
 		}
+		sessionID, err := config.NormalizeSessionID(cfg.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SessionID = sessionID
 		cfg.Task = args[2]
 		cfg.ExplicitStdin = (args[2] == "-")
 		if len(args) > 3 {
@@ -396,8 +724,17 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 				return nil, fmt.Errorf("invalid workdir: '-' is not a valid directory path")
 			}
 			cfg.WorkDir = args[3]
+		} else if opts.ResumeWorkdir && flagWorkdir == "" {
+			workDir, err := resolveResumeWorkdir(cfg.SessionID)
+			if err != nil {
+				return nil, err
+			}
+			cfg.WorkDir = workDir
 		}
 	} else {
+		if opts.ResumeWorkdir {
+			return nil, fmt.Errorf("--resume-workdir requires resume mode")
+		}
 		cfg.Mode = "new"
 		cfg.Task = args[0]
 		cfg.ExplicitStdin = (args[0] == "-")
@@ -408,10 +745,143 @@ func buildSingleConfig(cmd *cobra.Command, args []string, rawArgv []string, opts
 			cfg.WorkDir = args[1]
 		}
 	}
+	if taskFromFile {
+		cfg.ExplicitStdin = false
+	}
+	if flagWorkdir != "" {
+		if cfg.WorkDir != defaultWorkdir && cfg.WorkDir != flagWorkdir {
+			logWarn(fmt.Sprintf("--workdir %s overrides the positional workdir %s", flagWorkdir, cfg.WorkDir))
+		}
+		cfg.WorkDir = flagWorkdir
+	}
 
 	return cfg, nil
 }
 
+// resolveStatusFile returns the --status-file path, falling back to
+// CODEAGENT_STATUS_FILE or the config file.
+func resolveStatusFile(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (string, error) {
+	if cmd.Flags().Changed("status-file") {
+		path := strings.TrimSpace(opts.StatusFile)
+		if path == "" {
+			return "", fmt.Errorf("--status-file flag requires a value")
+		}
+		return path, nil
+	}
+	return strings.TrimSpace(v.GetString("status-file")), nil
+}
+
+// insertTaskArg puts a task read from a file into the task slot of args;
+// the remaining positional args (session id, workdir) keep their meaning.
+func insertTaskArg(args []string, task, usage string) ([]string, error) {
+	if len(args) > 0 && args[0] == "resume" {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("resume mode requires: resume <session_id> %s", usage)
+		}
+		return append([]string{"resume", args[1], task}, args[2:]...), nil
+	}
+	if len(args) > 0 && args[0] == "resume-last" {
+		return append([]string{"resume-last", task}, args[1:]...), nil
+	}
+	return append([]string{task}, args...), nil
+}
+
+// resolveMaxMessageLines returns the --max-message-lines display cap, falling
+// back to CODEAGENT_MAX_MESSAGE_LINES or the config file.
+func resolveMaxMessageLines(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (int, error) {
+	maxLines := opts.MaxMessageLines
+	if !cmd.Flags().Changed("max-message-lines") {
+		maxLines = v.GetInt("max-message-lines")
+	}
+	if maxLines < 0 {
+		return 0, fmt.Errorf("--max-message-lines must be >= 0, got %d", maxLines)
+	}
+	return maxLines, nil
+}
+
+// resolveMaxParallel returns the --max-parallel worker cap, falling back to
+// CODEAGENT_MAX_PARALLEL or the config file and then to the older
+// CODEAGENT_MAX_PARALLEL_WORKERS. 0 means unlimited.
+func resolveMaxParallel(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (int, error) {
+	maxParallel := opts.MaxParallel
+	if !cmd.Flags().Changed("max-parallel") {
+		maxParallel = v.GetInt("max-parallel")
+	}
+	if maxParallel < 0 {
+		return 0, fmt.Errorf("--max-parallel must be >= 0, got %d", maxParallel)
+	}
+	if maxParallel == 0 {
+		maxParallel = config.ResolveMaxParallelWorkers()
+	}
+	return maxParallel, nil
+}
+
+// resolveCacheDir returns the --cache-dir path, falling back to
+// CODEAGENT_CACHE_DIR or the config file. --refresh and --no-cache-write
+// require a cache directory.
+func resolveCacheDir(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (string, error) {
+	dir := strings.TrimSpace(opts.CacheDir)
+	if cmd.Flags().Changed("cache-dir") {
+		if dir == "" {
+			return "", fmt.Errorf("--cache-dir flag requires a value")
+		}
+	} else {
+		dir = strings.TrimSpace(v.GetString("cache-dir"))
+	}
+	if dir == "" && (opts.CacheRefresh || opts.NoCacheWrite) {
+		return "", fmt.Errorf("--refresh and --no-cache-write require --cache-dir")
+	}
+	return dir, nil
+}
+
+// resolveTimeoutFlag validates --timeout and returns its raw value, or 0 when
+// the flag was not given so resolveTimeoutSource falls back to CODEX_TIMEOUT.
+func resolveTimeoutFlag(cmd *cobra.Command, opts *cliOptions) (int, error) {
+	if !cmd.Flags().Changed("timeout") {
+		return 0, nil
+	}
+	if opts.Timeout <= 0 {
+		return 0, fmt.Errorf("--timeout must be > 0, got %d", opts.Timeout)
+	}
+	return opts.Timeout, nil
+}
+
+// resolveRetries returns the retry count from --retries, falling back to
+// CODEAGENT_RETRIES or the config file.
+func resolveRetries(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (int, error) {
+	retries := opts.Retries
+	if !cmd.Flags().Changed("retries") {
+		retries = v.GetInt("retries")
+	}
+	if retries < 0 {
+		return 0, fmt.Errorf("--retries must be >= 0, got %d", retries)
+	}
+	return retries, nil
+}
+
+// resolveRetryBackoff returns the retry pause curve from --retry-backoff and
+// --retry-base, falling back to CODEAGENT_RETRY_BACKOFF / CODEAGENT_RETRY_BASE
+// or the config file.
+func resolveRetryBackoff(cmd *cobra.Command, opts *cliOptions, v *viper.Viper) (RetryBackoff, error) {
+	curve := opts.RetryBackoff
+	if !cmd.Flags().Changed("retry-backoff") && v.IsSet("retry-backoff") {
+		curve = v.GetString("retry-backoff")
+	}
+	curve, err := validateRetryBackoff(curve)
+	if err != nil {
+		return RetryBackoff{}, fmt.Errorf("--retry-backoff: %w", err)
+	}
+
+	base := opts.RetryBase
+	if !cmd.Flags().Changed("retry-base") && v.IsSet("retry-base") {
+		base = v.GetDuration("retry-base")
+	}
+	if base < 0 {
+		return RetryBackoff{}, fmt.Errorf("--retry-base must be >= 0, got %s", base)
+	}
+	return RetryBackoff{Curve: curve, Base: base}, nil
+}
+
 func lastFlagIndex(argv []string, name string) int {
 	if len(argv) == 0 {
 		return -1
@@ -432,9 +902,56 @@ func lastFlagIndex(argv []string, name string) int {
 	return last
 }
 
+// parallelPositionalError explains why positional args cannot be combined
+// with --parallel, naming the single-mode form the user most likely meant.
+func parallelPositionalError(args []string) string {
+	if args[0] == "resume" || args[0] == "resume-last" {
+		return "--parallel cannot be combined with resume; set session_id in each ---TASK--- block to resume a session in parallel mode."
+	}
+	if len(args) == 1 {
+		return fmt.Sprintf("--parallel cannot be combined with a positional task (%q); --parallel reads every task from stdin. Drop --parallel to run a single task.", args[0])
+	}
+	return "--parallel reads its task configuration from stdin; no positional arguments are allowed."
+}
+
+// parallelRejectedFlags are single-task options that --parallel refuses; each
+// ---TASK--- block carries its own settings instead.
+var parallelRejectedFlags = []string{
+	"agent", "prompt-file", "system-prompt", "workdir", "reasoning-effort", "skills",
+	"json-stream-passthrough", "claude-allow", "task-json", "task-field", "task-from-template",
+	"var", "confirm", "append-workdir-context", "copy-session-to-clipboard", "strip-control",
+	"resume-workdir", "workdir-git-check", "require-clean", "pipeline", "probe", "dry-run",
+	"detach", "quiet",
+}
+
+// parallelUnlistedFlags are accepted alongside --parallel but are not options
+// of the parallel run, so the rejection message does not advertise them.
+var parallelUnlistedFlags = map[string]bool{
+	"parallel": true, "config": true, "version": true, "list-backends": true, "check": true,
+	"cleanup": true, "dump-last-log": true, "max-logs": true, "log-also-stderr": true,
+	"profile": true, "attach": true, "worktree": true, "worktree-cleanup": true,
+	"resume-file": true, "dangerously-skip-permissions": true, "help": true,
+}
+
+// parallelAllowedFlags returns the flags usable with --parallel: every flag in
+// fs that is neither rejected nor unlisted.
+func parallelAllowedFlags(fs *pflag.FlagSet) []string {
+	rejected := make(map[string]bool, len(parallelRejectedFlags))
+	for _, flag := range parallelRejectedFlags {
+		rejected[flag] = true
+	}
+	var allowed []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if !rejected[f.Name] && !parallelUnlistedFlags[f.Name] {
+			allowed = append(allowed, "--"+f.Name)
+		}
+	})
+	return allowed
+}
+
 func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *viper.Viper, name string) int {
 	if len(args) > 0 {
-		fmt.Fprintln(os.Stderr, "ERROR: --parallel reads its task configuration from stdin; no positional arguments are allowed.")
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", parallelPositionalError(args))
 		fmt.Fprintln(os.Stderr, "Usage examples:")
 		fmt.Fprintf(os.Stderr, "  %s --parallel < tasks.txt\n", name)
 		fmt.Fprintf(os.Stderr, "  echo '...' | %s --parallel\n", name)
@@ -443,9 +960,24 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 		return 1
 	}
 
-	if cmd.Flags().Changed("agent") || cmd.Flags().Changed("prompt-file") || cmd.Flags().Changed("reasoning-effort") || cmd.Flags().Changed("skills") {
-		fmt.Fprintln(os.Stderr, "ERROR: --parallel reads its task configuration from stdin; only --backend, --model, --output, --full-output and --skip-permissions are allowed.")
-		return 1
+	for _, flag := range parallelRejectedFlags {
+		if cmd.Flags().Changed(flag) {
+			fmt.Fprintf(os.Stderr, "ERROR: --parallel reads its task configuration from stdin; --%s is not supported. Allowed flags: %s.\n", flag, strings.Join(parallelAllowedFlags(cmd.Flags()), ", "))
+			return 1
+		}
+	}
+
+	resumeFile := ""
+	if cmd.Flags().Changed("resume-file") {
+		if opts.Parallel {
+			fmt.Fprintln(os.Stderr, "ERROR: --resume-file cannot be combined with --parallel; it already runs its sessions in parallel")
+			return 1
+		}
+		resumeFile = strings.TrimSpace(opts.ResumeFile)
+		if resumeFile == "" {
+			fmt.Fprintln(os.Stderr, "ERROR: --resume-file flag requires a value")
+			return 1
+		}
 	}
 
 	backendName := defaultBackendName
@@ -474,6 +1006,10 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 	if !cmd.Flags().Changed("full-output") && v.IsSet("full-output") {
 		fullOutput = v.GetBool("full-output")
 	}
+	jsonOutput := opts.JSON
+	if !cmd.Flags().Changed("json") && v.IsSet("json") {
+		jsonOutput = v.GetBool("json")
+	}
 
 	outputPath := ""
 	if cmd.Flags().Changed("output") {
@@ -486,6 +1022,27 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 		outputPath = val
 	}
 
+	statusFile, err := resolveStatusFile(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	maxMessageLines, err := resolveMaxMessageLines(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	cacheDir, err := resolveCacheDir(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	maxParallel, err := resolveMaxParallel(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
 	skipChanged := cmd.Flags().Changed("skip-permissions") || cmd.Flags().Changed("dangerously-skip-permissions")
 	skipPermissions := false
 	if skipChanged {
@@ -494,24 +1051,69 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 		skipPermissions = v.GetBool("skip-permissions")
 	}
 
-	backend, err := selectBackendFn(backendName)
+	adaptiveConcurrency := opts.AdaptiveConcurrency
+	if !cmd.Flags().Changed("adaptive-concurrency") && v.IsSet("adaptive-concurrency") {
+		adaptiveConcurrency = v.GetBool("adaptive-concurrency")
+	}
+
+	failFast := opts.FailFast
+	if !cmd.Flags().Changed("fail-fast") && v.IsSet("fail-fast") {
+		failFast = v.GetBool("fail-fast")
+	}
+
+	retries, err := resolveRetries(cmd, opts, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	timeoutFlag, err := resolveTimeoutFlag(cmd, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	retryBackoff, err := resolveRetryBackoff(cmd, opts, v)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		return 1
 	}
-	backendName = backend.Name()
 
-	data, err := io.ReadAll(stdinReader)
+	taskOrder, err := validateTaskOrder(opts.TaskOrder)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to read stdin: %v\n", err)
+		fmt.Fprintf(os.Stderr, "ERROR: --task-order: %v\n", err)
 		return 1
 	}
 
-	cfg, err := parseParallelConfig(data)
+	backend, err := selectBackendFn(backendName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		return 1
 	}
+	backendName = backend.Name()
+
+	var cfg *ParallelConfig
+	if resumeFile != "" {
+		data, err := os.ReadFile(resumeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read resume file: %v\n", err)
+			return 1
+		}
+		cfg, err = parseResumeList(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", resumeFile, err)
+			return exitConfigError
+		}
+	} else {
+		data, err := io.ReadAll(stdinReader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to read stdin: %v\n", err)
+			return 1
+		}
+		cfg, err = parseParallelConfig(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return exitConfigError
+		}
+	}
 
 	cfg.GlobalBackend = backendName
 	model = strings.TrimSpace(model)
@@ -523,16 +1125,44 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 			cfg.Tasks[i].Model = model
 		}
 		cfg.Tasks[i].SkipPermissions = cfg.Tasks[i].SkipPermissions || skipPermissions
+		cfg.Tasks[i].FailOnTurnFailed = opts.FailOnTurnFailed
+		cfg.Tasks[i].FailIfNoFilesChanged = opts.FailIfNoFilesChanged
+		cfg.Tasks[i].ValidateStream = opts.StreamJSONValidate
+		cfg.Tasks[i].CacheDir = cacheDir
+		cfg.Tasks[i].CacheRefresh = opts.CacheRefresh
+		cfg.Tasks[i].CacheNoWrite = opts.NoCacheWrite
 	}
 
-	timeoutSec := resolveTimeout()
+	timeoutSec, timeoutSource := resolveTimeoutSource(timeoutFlag)
+	logInfo(fmt.Sprintf("Timeout: %ds (from %s)", timeoutSec, timeoutSource))
 	layers, err := topologicalSort(cfg.Tasks)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		return 1
+		return exitConfigError
+	}
+
+	runCtx := context.Background()
+	if runTimeout := resolveRunTimeout(); runTimeout > 0 {
+		logInfo(fmt.Sprintf("Run timeout: %s (from CODEAGENT_RUN_TIMEOUT)", runTimeout))
+		var cancelRun context.CancelFunc
+		runCtx, cancelRun = context.WithTimeout(runCtx, runTimeout)
+		defer cancelRun()
 	}
 
-	results := executeConcurrent(layers, timeoutSec)
+	var drained atomic.Bool
+	results := executeConcurrentWithOptions(runCtx, layers, ConcurrentOptions{
+		Timeout:             timeoutSec,
+		MaxWorkers:          maxParallel,
+		Retries:             retries,
+		RetryBackoff:        retryBackoff,
+		TaskOrder:           taskOrder,
+		InterruptFile:       strings.TrimSpace(opts.InterruptFile),
+		AdaptiveConcurrency: adaptiveConcurrency,
+		FailFast:            failFast,
+		DrainOnInterrupt:    true,
+		OnDrain:             func() { drained.Store(true) },
+	})
+	runTimedOut := errors.Is(runCtx.Err(), context.DeadlineExceeded)
 
 	for i := range results {
 		results[i].CoverageTarget = defaultCoverageTarget
@@ -552,23 +1182,56 @@ func runParallelMode(cmd *cobra.Command, args []string, opts *cliOptions, v *vip
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		return 1
 	}
+	if err := writeStatusFile(statusFile, results); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
 
-	fmt.Println(generateFinalOutputWithMode(results, !fullOutput))
+	if jsonOutput {
+		if err := encodeOutputPayload(os.Stdout, results); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write JSON output: %v\n", err)
+			return 1
+		}
+	} else {
+		display := results
+		if fullOutput && maxMessageLines > 0 {
+			display = make([]TaskResult, len(results))
+			copy(display, results)
+			for i := range display {
+				display[i].Message = capMessageForDisplay(display[i].Message, maxMessageLines, outputPath)
+			}
+		}
+		fmt.Println(generateFinalOutputWithMode(display, !fullOutput))
+	}
 
+	if drained.Load() {
+		return 130
+	}
 	exitCode := 0
 	for _, res := range results {
+		if res.Interrupted {
+			return 130
+		}
 		if res.ExitCode != 0 {
 			exitCode = res.ExitCode
 		}
 	}
+	if runTimedOut && exitCode != 0 {
+		return 124
+	}
 	return exitCode
 }
 
-func runSingleMode(cfg *Config, name string) int {
+// executeSingleTask prepares cfg's task and runs it on the selected backend.
+// ran is false when the run stopped before the backend was invoked; exitCode
+// is then the code to exit with.
+func executeSingleTask(cfg *Config, name string) (result TaskResult, exitCode int, ran bool) {
+	stopBackendSelect := startupProfiler.track("backend select")
 	backend, err := selectBackendFn(cfg.Backend)
+	stopBackendSelect()
 	if err != nil {
 		logError(err.Error())
-		return 1
+		return TaskResult{}, 1, false
 	}
 	cfg.Backend = backend.Name()
 
@@ -583,8 +1246,8 @@ func runSingleMode(cfg *Config, name string) int {
 	}
 	logInfo(fmt.Sprintf("Selected backend: %s", backend.Name()))
 
-	timeoutSec := resolveTimeout()
-	logInfo(fmt.Sprintf("Timeout: %ds", timeoutSec))
+	timeoutSec, timeoutSource := resolveTimeoutSource(cfg.Timeout)
+	logInfo(fmt.Sprintf("Timeout: %ds (from %s)", timeoutSec, timeoutSource))
 	cfg.Timeout = timeoutSec
 
 	var taskText string
@@ -595,19 +1258,19 @@ func runSingleMode(cfg *Config, name string) int {
 		data, err := io.ReadAll(stdinReader)
 		if err != nil {
 			logError("Failed to read stdin: " + err.Error())
-			return 1
+			return TaskResult{}, 1, false
 		}
-		taskText = string(data)
+		taskText = normalizeStdinEOL(string(data))
 		if taskText == "" {
 			logError("Explicit stdin mode requires task input from stdin")
-			return 1
+			return TaskResult{}, 1, false
 		}
 		piped = !isTerminal()
 	} else {
 		pipedTask, err := readPipedTask()
 		if err != nil {
 			logError("Failed to read piped stdin: " + err.Error())
-			return 1
+			return TaskResult{}, 1, false
 		}
 		piped = pipedTask != ""
 		if piped {
@@ -617,13 +1280,33 @@ func runSingleMode(cfg *Config, name string) int {
 		}
 	}
 
+	systemPrompt := cfg.SystemPrompt
 	if strings.TrimSpace(cfg.PromptFile) != "" {
 		prompt, err := readAgentPromptFile(cfg.PromptFile, cfg.PromptFileExplicit)
-		if err != nil {
+		switch {
+		case err == nil:
+			if systemPrompt != "" {
+				prompt = prompt + "\n\n" + systemPrompt
+			}
+			systemPrompt = prompt
+		case !cfg.PromptFileExplicit && errors.Is(err, os.ErrNotExist):
+			// An agent's configured persona is optional; run the raw task.
+			logWarn(fmt.Sprintf("Agent prompt file %s not found; running the task without it", cfg.PromptFile))
+		default:
 			logError("Failed to read prompt file: " + err.Error())
-			return 1
+			return TaskResult{}, 1, false
 		}
-		taskText = wrapTaskWithAgentPrompt(prompt, taskText)
+	}
+
+	// Claude takes the agent persona and --system-prompt as a real system
+	// prompt; other backends have no such channel and get a task preamble.
+	promptPrepended := false
+	if cfg.Backend == "claude" {
+		cfg.SystemPrompt = systemPrompt
+	} else if systemPrompt != "" {
+		taskText = wrapTaskWithAgentPrompt(systemPrompt, taskText)
+		promptPrepended = true
+		cfg.SystemPrompt = ""
 	}
 
 	// Resolve skills: explicit > auto-detect from workdir
@@ -637,7 +1320,14 @@ func runSingleMode(cfg *Config, name string) int {
 		}
 	}
 
-	useStdin := cfg.ExplicitStdin || shouldUseStdin(taskText, piped)
+	if cfg.AppendWorkdirContext {
+		if listing := buildWorkdirContext(cfg.WorkDir); listing != "" {
+			taskText = taskText + "\n\n# Workdir Context\n\n" + listing
+		}
+	}
+
+	// Agent prompts are long and multi-line; always pass them on stdin.
+	useStdin := cfg.ExplicitStdin || promptPrepended || shouldUseStdin(taskText, piped)
 
 	targetArg := taskText
 	if useStdin {
@@ -648,54 +1338,63 @@ func runSingleMode(cfg *Config, name string) int {
 	logger := activeLogger()
 	if logger == nil {
 		fmt.Fprintln(os.Stderr, "ERROR: logger is not initialized")
-		return 1
+		return TaskResult{}, 1, false
 	}
 
-	fmt.Fprintf(os.Stderr, "[%s]\n", name)
-	fmt.Fprintf(os.Stderr, "  Backend: %s\n", cfg.Backend)
-	fmt.Fprintf(os.Stderr, "  Command: %s %s\n", codexCommand, strings.Join(codexArgs, " "))
-	fmt.Fprintf(os.Stderr, "  PID: %d\n", os.Getpid())
-	fmt.Fprintf(os.Stderr, "  Log: %s\n", logger.Path())
+	fullCommand := strings.TrimSpace(resolveBackendCommand(codexCommand) + " " + strings.Join(codexArgs, " "))
+	command := redactSecrets(fullCommand)
+	if command != fullCommand {
+		// Only recorded when CODEAGENT_LOG_LEVEL=debug.
+		logDebug(fmt.Sprintf("Command (unredacted): %s", fullCommand))
+	}
+	if cfg.Quiet {
+		logInfo(fmt.Sprintf("Command: %s", command))
+	} else {
+		fmt.Fprintf(os.Stderr, "[%s]\n", name)
+		fmt.Fprintf(os.Stderr, "  Backend: %s\n", cfg.Backend)
+		fmt.Fprintf(os.Stderr, "  Command: %s\n", command)
+		fmt.Fprintf(os.Stderr, "  PID: %d\n", os.Getpid())
+		if path := logger.Path(); path != "" {
+			fmt.Fprintf(os.Stderr, "  Log: %s\n", path)
+		} else {
+			fmt.Fprintln(os.Stderr, "  Log: (none, warnings go to stderr)")
+		}
+	}
+
+	if cfg.DryRun {
+		printDryRun(cfg, codexArgs, useStdin, stdinReasons(taskText, piped, cfg.ExplicitStdin))
+		return TaskResult{}, 0, false
+	}
 
 	if cfg.Mode == "new" && strings.TrimSpace(taskText) == "integration-log-check" {
 		logInfo("Integration log check: skipping backend execution")
-		return 0
+		return TaskResult{}, 0, false
 	}
 
 	if useStdin {
-		var reasons []string
-		if piped {
-			reasons = append(reasons, "piped input")
-		}
-		if cfg.ExplicitStdin {
-			reasons = append(reasons, "explicit \"-\"")
-		}
-		if strings.Contains(taskText, "\n") {
-			reasons = append(reasons, "newline")
-		}
-		if strings.Contains(taskText, "\\") {
-			reasons = append(reasons, "backslash")
-		}
-		if strings.Contains(taskText, "\"") {
-			reasons = append(reasons, "double-quote")
-		}
-		if strings.Contains(taskText, "'") {
-			reasons = append(reasons, "single-quote")
-		}
-		if strings.Contains(taskText, "`") {
-			reasons = append(reasons, "backtick")
-		}
-		if strings.Contains(taskText, "$") {
-			reasons = append(reasons, "dollar")
-		}
-		if len(taskText) > 800 {
-			reasons = append(reasons, "length>800")
-		}
+		reasons := stdinReasons(taskText, piped, cfg.ExplicitStdin)
 		if len(reasons) > 0 {
 			logWarn(fmt.Sprintf("Using stdin mode for task due to: %s", strings.Join(reasons, ", ")))
 		}
 	}
 
+	if cfg.Confirm && usesSandboxBypass(codexArgs) && isTerminal() {
+		if !confirmBypassRun(cfg.Backend, cfg.WorkDir) {
+			logWarn("Run aborted at --confirm prompt")
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return TaskResult{}, 130, false
+		}
+	}
+
+	// A --worktree run edits a fresh checkout, so the source tree's state
+	// does not mix with the agent's changes.
+	if cfg.WorkdirGitCheck && !cfg.Worktree {
+		if err := checkWorkdirClean(cfg.WorkDir, cfg.RequireClean); err != nil {
+			logError(err.Error())
+			return TaskResult{}, 1, false
+		}
+	}
+
 	logInfo(fmt.Sprintf("%s running...", cfg.Backend))
 
 	taskSpec := TaskSpec{
@@ -708,15 +1407,41 @@ func runSingleMode(cfg *Config, name string) int {
 		ReasoningEffort: cfg.ReasoningEffort,
 		Agent:           cfg.Agent,
 		SkipPermissions: cfg.SkipPermissions,
+		Yolo:            cfg.Yolo,
 		Worktree:        cfg.Worktree,
 		AllowedTools:    cfg.AllowedTools,
 		DisallowedTools: cfg.DisallowedTools,
 		UseStdin:        useStdin,
+
+		StreamPassthrough:    cfg.JSONStreamPassthrough,
+		FailOnTurnFailed:     cfg.FailOnTurnFailed,
+		FailIfNoFilesChanged: cfg.FailIfNoFilesChanged,
+		ValidateStream:       cfg.StreamJSONValidate,
+		ClaudeAllowFile:      cfg.ClaudeAllowFile,
+		CacheDir:             cfg.CacheDir,
+		CacheRefresh:         cfg.CacheRefresh,
+		CacheNoWrite:         cfg.CacheNoWrite,
+		StreamMessages:       cfg.StreamMessages,
+		Quiet:                cfg.Quiet,
+		SystemPrompt:         cfg.SystemPrompt,
 	}
 
-	result := runTaskFn(taskSpec, false, cfg.Timeout)
+	stopBackendRun := startupProfiler.track("backend run")
+	backoff := RetryBackoff{Curve: cfg.RetryBackoff, Base: cfg.RetryBase}
+	result = runWithResultCache(taskSpec, func(ts TaskSpec) TaskResult {
+		return runTaskWithBackoff(ts, cfg.Timeout, cfg.Retries, backoff, func(ts TaskSpec, timeout int) TaskResult {
+			return runTaskFn(ts, false, timeout)
+		})
+	})
+	startupProfiler.record("backend spawn", result.SpawnDuration)
+	stopBackendRun()
+
+	if cfg.StripControl {
+		result.Message = stripControlSequences(result.Message)
+		result.Error = stripControlSequences(result.Error)
+	}
 
-	exitCode := result.ExitCode
+	exitCode = result.ExitCode
 	if exitCode == 0 && strings.TrimSpace(result.Message) == "" {
 		errMsg := fmt.Sprintf("no output message: backend=%s returned empty result.Message with exit_code=0", cfg.Backend)
 		logError(errMsg)
@@ -725,26 +1450,91 @@ func runSingleMode(cfg *Config, name string) int {
 			result.Error = errMsg
 		}
 	}
+	return result, exitCode, true
+}
+
+// stdinReasons lists why a task is passed to the backend on stdin.
+func stdinReasons(taskText string, piped, explicit bool) []string {
+	var reasons []string
+	if piped {
+		reasons = append(reasons, "piped input")
+	}
+	if explicit {
+		reasons = append(reasons, "explicit \"-\"")
+	}
+	if strings.Contains(taskText, "\n") {
+		reasons = append(reasons, "newline")
+	}
+	if strings.Contains(taskText, "\\") {
+		reasons = append(reasons, "backslash")
+	}
+	if strings.Contains(taskText, "\"") {
+		reasons = append(reasons, "double-quote")
+	}
+	if strings.Contains(taskText, "'") {
+		reasons = append(reasons, "single-quote")
+	}
+	if strings.Contains(taskText, "`") {
+		reasons = append(reasons, "backtick")
+	}
+	if strings.Contains(taskText, "$") {
+		reasons = append(reasons, "dollar")
+	}
+	if threshold := stdinThreshold(); len(taskText) > threshold {
+		reasons = append(reasons, fmt.Sprintf("length>%d", threshold))
+	}
+	return reasons
+}
+
+func runSingleMode(cfg *Config, name string) int {
+	result, exitCode, ran := executeSingleTask(cfg, name)
+	if !ran {
+		return exitCode
+	}
+	// With CODEAGENT_STREAM the message was already printed as it arrived,
+	// unless it came from the result cache.
+	streamed := cfg.StreamMessages && !result.Cached
 
 	if err := writeStructuredOutput(cfg.OutputPath, []TaskResult{result}); err != nil {
 		logError(err.Error())
 		return 1
 	}
+	if err := writeStatusFile(cfg.StatusFile, []TaskResult{result}); err != nil {
+		logError(err.Error())
+		return 1
+	}
+
+	// The raw event stream already went to stdout; keep it machine-readable.
+	if cfg.JSONStreamPassthrough {
+		return exitCode
+	}
 
 	if exitCode != 0 {
 		// Surface any parsed backend output even on non-zero exit to avoid "(no output)" in tool runners.
 		if strings.TrimSpace(result.Message) != "" {
-			fmt.Println(result.Message)
+			if !streamed {
+				fmt.Println(capMessageForDisplay(result.Message, cfg.MaxMessageLines, cfg.OutputPath))
+			}
 			if result.SessionID != "" {
 				fmt.Printf("\n---\nSESSION_ID: %s\n", result.SessionID)
+				if cfg.CopySessionToClipboard {
+					copySessionToClipboard(result.SessionID)
+				}
 			}
 		}
 		return exitCode
 	}
 
-	fmt.Println(result.Message)
+	recordSessionRun(cfg, result)
+
+	if !streamed {
+		fmt.Println(capMessageForDisplay(result.Message, cfg.MaxMessageLines, cfg.OutputPath))
+	}
 	if result.SessionID != "" {
 		fmt.Printf("\n---\nSESSION_ID: %s\n", result.SessionID)
+		if cfg.CopySessionToClipboard {
+			copySessionToClipboard(result.SessionID)
+		}
 	}
 
 	return 0
@@ -0,0 +1,66 @@
+package wrapper
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Test hooks for the clipboard integration.
+var (
+	clipboardLookPathFn = exec.LookPath
+	clipboardCommandsFn = defaultClipboardCommands
+)
+
+// defaultClipboardCommands lists the clipboard tools to try for the current
+// platform, in order of preference.
+func defaultClipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}, {"clip"}}
+	default:
+		return [][]string{
+			{"wl-copy"},
+			{"xclip", "-selection", "clipboard"},
+			{"xsel", "--clipboard", "--input"},
+			{"clip.exe"}, // WSL
+		}
+	}
+}
+
+// copyToClipboard pipes text into the first available clipboard tool and
+// returns the tool's name.
+func copyToClipboard(text string) (string, error) {
+	var tried []string
+	for _, candidate := range clipboardCommandsFn() {
+		if len(candidate) == 0 {
+			continue
+		}
+		tried = append(tried, candidate[0])
+		path, err := clipboardLookPathFn(candidate[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, candidate[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return candidate[0], fmt.Errorf("%s failed: %v %s", candidate[0], err, strings.TrimSpace(string(out)))
+		}
+		return candidate[0], nil
+	}
+	return "", fmt.Errorf("no clipboard tool found (tried %s)", strings.Join(tried, ", "))
+}
+
+// copySessionToClipboard implements --copy-session-to-clipboard. Failures
+// only warn: the session id is already printed in the trailer.
+func copySessionToClipboard(sessionID string) {
+	tool, err := copyToClipboard(sessionID)
+	if err != nil {
+		logWarn(fmt.Sprintf("Could not copy session id to clipboard: %v", err))
+		return
+	}
+	logInfo(fmt.Sprintf("Copied session id to clipboard via %s", tool))
+}
@@ -344,6 +344,7 @@ func TestLoggerOrderPreservation(t *testing.T) {
 func TestConcurrentWorkerPoolLimit(t *testing.T) {
 	orig := runCodexTaskFn
 	defer func() { runCodexTaskFn = orig }()
+	t.Setenv("CODEAGENT_LOG_LEVEL", "debug")
 
 	logger, err := NewLoggerWithSuffix("pool-limit")
 	if err != nil {
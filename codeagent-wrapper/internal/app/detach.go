@@ -0,0 +1,223 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// detachHandle is the --detach handle file; --attach reads it back.
+type detachHandle struct {
+	PID       int       `json:"pid"`
+	Log       string    `json:"log"`
+	Output    string    `json:"output"`
+	WorkDir   string    `json:"workdir"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Test hooks for --detach/--attach.
+var (
+	detachExecutableFn = os.Executable
+	detachCommandFn    = exec.Command
+	attachPollInterval = 200 * time.Millisecond
+	detachHandleDirFn  = defaultDetachHandleDir
+	processAliveFn     = processAlive
+	attachStreamOutput io.Writer // os.Stderr when nil
+)
+
+func defaultDetachHandleDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return "", fmt.Errorf("failed to resolve user home directory: %w", err)
+	}
+	return filepath.Join(home, ".codeagent", "detached"), nil
+}
+
+// detachedChildArgs returns argv for the background run: the original
+// arguments without --detach, plus --output when the user did not pass one.
+func detachedChildArgs(argv []string, outputPath string, hasOutput bool) []string {
+	args := make([]string, 0, len(argv)+2)
+	for _, arg := range argv {
+		if arg == "--detach" || strings.HasPrefix(arg, "--detach=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	if !hasOutput {
+		args = append([]string{"--output", outputPath}, args...)
+	}
+	return args
+}
+
+// runDetached implements --detach: it restarts the wrapper in the background
+// with the same arguments, records its PID, console log and result file in a
+// handle file, prints the handle path and returns without waiting.
+func runDetached(cfg *Config, argv []string) int {
+	if cfg.ExplicitStdin {
+		logError("--detach cannot read the task from stdin; pass it as an argument or with --task-json")
+		return 1
+	}
+
+	baseDir, err := detachHandleDirFn()
+	if err != nil {
+		logError(err.Error())
+		return 1
+	}
+	if err := os.MkdirAll(baseDir, 0o700); err != nil {
+		logError(fmt.Sprintf("failed to create %s: %v", baseDir, err))
+		return 1
+	}
+	dir, err := os.MkdirTemp(baseDir, time.Now().Format("20060102-150405")+"-")
+	if err != nil {
+		logError(fmt.Sprintf("failed to create detach directory: %v", err))
+		return 1
+	}
+
+	outputPath := cfg.OutputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(dir, "result.json")
+	}
+	if abs, err := filepath.Abs(outputPath); err == nil {
+		outputPath = abs
+	}
+	logPath := filepath.Join(dir, "console.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		logError(fmt.Sprintf("failed to create %s: %v", logPath, err))
+		return 1
+	}
+	defer logFile.Close()
+
+	exe, err := detachExecutableFn()
+	if err != nil {
+		logError(fmt.Sprintf("failed to locate the wrapper executable: %v", err))
+		return 1
+	}
+	cmd := detachCommandFn(exe, detachedChildArgs(argv, outputPath, cfg.OutputPath != "")...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Stdin = nil
+	detachProcAttr(cmd)
+	if err := cmd.Start(); err != nil {
+		logError(fmt.Sprintf("failed to start detached run: %v", err))
+		return 1
+	}
+	// Reap the child if this process outlives it (tests); normally we exit
+	// first and the child is re-parented.
+	go func() { _ = cmd.Wait() }()
+
+	workdir := cfg.WorkDir
+	if abs, err := filepath.Abs(workdir); err == nil {
+		workdir = abs
+	}
+	handle := detachHandle{
+		PID:       cmd.Process.Pid,
+		Log:       logPath,
+		Output:    outputPath,
+		WorkDir:   workdir,
+		StartedAt: time.Now(),
+	}
+	handlePath := filepath.Join(dir, "handle.json")
+	data, err := json.MarshalIndent(handle, "", "  ")
+	if err == nil {
+		err = os.WriteFile(handlePath, append(data, '\n'), 0o600)
+	}
+	if err != nil {
+		logError(fmt.Sprintf("failed to write handle file: %v", err))
+		return 1
+	}
+
+	logInfo(fmt.Sprintf("Detached run started: pid=%d handle=%s", handle.PID, handlePath))
+	fmt.Printf("DETACHED: %s\n", handlePath)
+	fmt.Printf("PID: %d\n", handle.PID)
+	fmt.Printf("Log: %s\n", logPath)
+	return 0
+}
+
+func readDetachHandle(path string) (detachHandle, error) {
+	var handle detachHandle
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "handle.json")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return handle, fmt.Errorf("--attach: %w", err)
+	}
+	if err := json.Unmarshal(data, &handle); err != nil {
+		return handle, fmt.Errorf("--attach: invalid handle file %s: %w", path, err)
+	}
+	if handle.PID <= 0 || handle.Output == "" {
+		return handle, fmt.Errorf("--attach: handle file %s is missing pid or output", path)
+	}
+	return handle, nil
+}
+
+func readDetachedResult(path string) (TaskResult, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TaskResult{}, false
+	}
+	var payload outputPayload
+	if err := json.Unmarshal(data, &payload); err != nil || len(payload.Results) == 0 {
+		return TaskResult{}, false
+	}
+	return payload.Results[0], true
+}
+
+// runAttachMode implements --attach: it streams the detached run's console
+// log to stderr until the run finishes, then prints its result like a
+// foreground run and exits with its exit code.
+func runAttachMode(handlePath string) int {
+	handle, err := readDetachHandle(strings.TrimSpace(handlePath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	stream := attachStreamOutput
+	if stream == nil {
+		stream = os.Stderr
+	}
+	var logFile *os.File
+	if handle.Log != "" {
+		if f, err := os.Open(handle.Log); err == nil {
+			logFile = f
+			defer f.Close()
+		}
+	}
+	drainLog := func() {
+		if logFile != nil {
+			_, _ = io.Copy(stream, logFile)
+		}
+	}
+
+	for processAliveFn(handle.PID) {
+		drainLog()
+		time.Sleep(attachPollInterval)
+	}
+	drainLog()
+
+	result, ok := readDetachedResult(handle.Output)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ERROR: detached run %d exited without writing a result to %s; see %s\n", handle.PID, handle.Output, handle.Log)
+		return 1
+	}
+
+	if strings.TrimSpace(result.Message) != "" {
+		fmt.Println(result.Message)
+	} else if result.Error != "" {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", result.Error)
+	}
+	if result.SessionID != "" {
+		fmt.Printf("\n---\nSESSION_ID: %s\n", result.SessionID)
+	}
+	if result.ExitCode == 0 && strings.TrimSpace(result.Message) == "" {
+		return 1
+	}
+	return result.ExitCode
+}
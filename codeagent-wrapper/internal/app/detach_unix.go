@@ -0,0 +1,24 @@
+//go:build unix || darwin || linux
+// +build unix darwin linux
+
+package wrapper
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcAttr starts the detached run in its own session so it survives
+// the terminal that launched it.
+func detachProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether pid still exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package wrapper
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const (
+	detachedProcess = 0x00000008 // DETACHED_PROCESS
+	stillActive     = 259        // STILL_ACTIVE
+)
+
+// detachProcAttr starts the detached run without a console of its own so it
+// survives the console that launched it.
+func detachProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+	}
+}
+
+// processAlive reports whether pid still exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
@@ -0,0 +1,106 @@
+//go:build unix || darwin || linux
+// +build unix darwin linux
+
+package wrapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const drainHelperEnv = "CODEAGENT_TEST_DRAIN_HELPER"
+
+// TestDrainHelperProcess is not a real test: TestDrainOnInterrupt_ProcessGroupSIGINT
+// re-executes the test binary with drainHelperEnv set so the drain runs in a
+// process group that a terminal-style SIGINT can be sent to.
+func TestDrainHelperProcess(t *testing.T) {
+	script := os.Getenv(drainHelperEnv)
+	if script == "" {
+		t.Skip("helper process for TestDrainOnInterrupt_ProcessGroupSIGINT")
+	}
+	os.Stderr, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	codexCommand = script
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return nil }
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		return runCodexTaskWithContext(task.Context, task, nil, nil, false, true, timeout)
+	}
+
+	layers := [][]TaskSpec{{{ID: "a", Task: "task"}}}
+	results := executeConcurrentWithOptions(context.Background(), layers, ConcurrentOptions{Timeout: 30, MaxWorkers: 1, DrainOnInterrupt: true})
+	for _, res := range results {
+		if res.LogPath != "" {
+			_ = os.Remove(res.LogPath)
+		}
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(results)
+	os.Exit(0)
+}
+
+func TestDrainOnInterrupt_ProcessGroupSIGINT(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "started")
+	script := filepath.Join(dir, "backend.sh")
+	body := fmt.Sprintf(`#!/bin/sh
+touch %q
+sleep 1
+echo '{"type":"thread.started","thread_id":"t-1"}'
+echo '{"type":"item.completed","item":{"type":"agent_message","text":"done"}}'
+sleep 0.05
+`, marker)
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to locate test binary: %v", err)
+	}
+	cmd := exec.Command(self, "-test.run=^TestDrainHelperProcess$")
+	cmd.Env = append(os.Environ(), drainHelperEnv+"="+script, "CODEAGENT_KILL_GRACE=10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start helper: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(marker); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			_ = cmd.Wait()
+			t.Fatal("backend never started")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Deliver SIGINT the way a terminal does: to the whole foreground group.
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGINT); err != nil {
+		t.Fatalf("failed to signal process group: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("helper failed: %v\n%s", err, stdout.String())
+	}
+
+	var results []TaskResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("failed to decode helper output %q: %v", stdout.String(), err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("results = %+v, want one", results)
+	}
+	if res := results[0]; res.ExitCode != 0 || res.Message != "done" {
+		t.Fatalf("in-flight task = %+v, want it to finish despite the interrupt", res)
+	}
+}
@@ -0,0 +1,32 @@
+package wrapper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// printDryRun implements --dry-run: it prints what a real run would launch.
+func printDryRun(cfg *Config, args []string, useStdin bool, reasons []string) {
+	fmt.Printf("Backend: %s\n", cfg.Backend)
+	fmt.Printf("Command: %s\n", strings.TrimSpace(resolveBackendCommand(codexCommand)+" "+strings.Join(args, " ")))
+
+	workdir := cfg.WorkDir
+	if abs, err := filepath.Abs(workdir); err == nil {
+		workdir = abs
+	}
+	if cfg.Worktree {
+		fmt.Printf("Workdir: new git worktree of %s\n", workdir)
+	} else {
+		fmt.Printf("Workdir: %s\n", workdir)
+	}
+
+	switch {
+	case !useStdin:
+		fmt.Println("Stdin: no (task passed as an argument)")
+	case len(reasons) > 0:
+		fmt.Printf("Stdin: yes (%s)\n", strings.Join(reasons, ", "))
+	default:
+		fmt.Println("Stdin: yes")
+	}
+}
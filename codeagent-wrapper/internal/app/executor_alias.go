@@ -28,6 +28,35 @@ func executeConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 	return executor.ExecuteConcurrentWithContext(parentCtx, layers, timeout, maxWorkers, runCodexTaskFn)
 }
 
+func executeConcurrentWithOptions(parentCtx context.Context, layers [][]TaskSpec, opts ConcurrentOptions) []TaskResult {
+	if opts.RunTask == nil {
+		opts.RunTask = runCodexTaskFn
+	}
+	return executor.ExecuteConcurrentWithOptions(parentCtx, layers, opts)
+}
+
+func stdinThreshold() int { return executor.StdinThreshold() }
+
+func validateTaskOrder(order string) (string, error) {
+	return executor.ValidateTaskOrder(order)
+}
+
+func runTaskWithRetries(task TaskSpec, timeout int, retries int, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	return executor.RunTaskWithRetries(task, timeout, retries, runTask)
+}
+
+func runTaskWithBackoff(task TaskSpec, timeout int, retries int, backoff RetryBackoff, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	return executor.RunTaskWithBackoff(task, timeout, retries, backoff, runTask)
+}
+
+func runWithResultCache(task TaskSpec, run func(TaskSpec) TaskResult) TaskResult {
+	return executor.RunWithResultCache(task, run)
+}
+
+func validateRetryBackoff(curve string) (string, error) {
+	return executor.ValidateRetryBackoff(curve)
+}
+
 func generateFinalOutput(results []TaskResult) string {
 	return executor.GenerateFinalOutput(results)
 }
@@ -57,6 +86,10 @@ func detectProjectSkills(workDir string) []string {
 	return executor.DetectProjectSkills(workDir)
 }
 
+func buildWorkdirContext(workDir string) string {
+	return executor.BuildWorkdirContext(workDir)
+}
+
 func resolveSkillContent(skills []string, maxBudget int) string {
 	return executor.ResolveSkillContent(skills, maxBudget)
 }
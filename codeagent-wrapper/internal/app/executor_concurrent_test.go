@@ -4,17 +4,20 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -466,6 +469,16 @@ func TestExecutorRunCodexTaskWithContext(t *testing.T) {
 		if res.ExitCode == 0 {
 			t.Fatalf("expected failure when no agent_message returned")
 		}
+		if res.BackendExitCode == nil || *res.BackendExitCode != 0 {
+			t.Fatalf("BackendExitCode = %v, want 0: the backend itself exited cleanly", res.BackendExitCode)
+		}
+		data, err := json.Marshal(res)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if !strings.Contains(string(data), `"exit_code":1`) || !strings.Contains(string(data), `"backend_exit_code":0`) {
+			t.Fatalf("JSON = %s, want both exit codes", data)
+		}
 	})
 }
 
@@ -1072,6 +1085,140 @@ func TestExecutorExecuteConcurrentWithContextBranches(t *testing.T) {
 	})
 }
 
+func TestExecutorExecuteConcurrentInterruptFile(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = devNull
+	t.Cleanup(func() {
+		os.Stderr = oldStderr
+		_ = devNull.Close()
+	})
+	t.Cleanup(executor.SetInterruptPollInterval(10 * time.Millisecond))
+
+	interruptFile := filepath.Join(t.TempDir(), "STOP")
+	running := nextExecutorTestTaskID("running")
+	pending := nextExecutorTestTaskID("pending")
+
+	orig := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		if task.ID != running {
+			return TaskResult{TaskID: task.ID, ExitCode: 0, Message: "should not run"}
+		}
+		// Trigger the interrupt mid-run and wait to be cancelled.
+		if err := os.WriteFile(interruptFile, nil, 0o600); err != nil {
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: err.Error()}
+		}
+		select {
+		case <-task.Context.Done():
+			return TaskResult{TaskID: task.ID, ExitCode: 130, Error: "execution cancelled"}
+		case <-time.After(5 * time.Second):
+			return TaskResult{TaskID: task.ID, ExitCode: 0, Message: "not interrupted"}
+		}
+	}
+	t.Cleanup(func() { runCodexTaskFn = orig })
+
+	start := time.Now()
+	results := executeConcurrentWithOptions(context.Background(), [][]TaskSpec{
+		{{ID: running}},
+		{{ID: pending}},
+	}, ConcurrentOptions{Timeout: 10, InterruptFile: interruptFile})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("interrupt took too long: %v", elapsed)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d: %+v", len(results), results)
+	}
+	for _, res := range results {
+		if res.LogPath != "" {
+			_ = os.Remove(res.LogPath)
+		}
+		switch res.TaskID {
+		case running:
+			if res.ExitCode != 130 {
+				t.Fatalf("expected in-flight task to be cancelled, got %+v", res)
+			}
+		case pending:
+			if res.ExitCode != 130 || !strings.Contains(res.Error, "interrupted by "+interruptFile) {
+				t.Fatalf("expected pending task to be skipped by interrupt, got %+v", res)
+			}
+		default:
+			t.Fatalf("unexpected result %+v", res)
+		}
+	}
+}
+
+func TestExecutorExecuteConcurrentTaskOrder(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = devNull
+	t.Cleanup(func() {
+		os.Stderr = oldStderr
+		_ = devNull.Close()
+	})
+
+	// Declared order: c, a, b. "a" has two dependents, "b" has one.
+	tasks := []TaskSpec{
+		{ID: "c"},
+		{ID: "a"},
+		{ID: "b"},
+		{ID: "x", Dependencies: []string{"a", "b"}},
+		{ID: "y", Dependencies: []string{"a"}},
+	}
+	layers, err := topologicalSort(tasks)
+	if err != nil {
+		t.Fatalf("topologicalSort() error: %v", err)
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{order: "declared", want: []string{"c", "a", "b", "x", "y"}},
+		{order: "id", want: []string{"a", "b", "c", "x", "y"}},
+		{order: "dependency", want: []string{"a", "b", "c", "x", "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			var mu sync.Mutex
+			var started []string
+			orig := runCodexTaskFn
+			runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+				mu.Lock()
+				started = append(started, task.ID)
+				mu.Unlock()
+				return TaskResult{TaskID: task.ID}
+			}
+			t.Cleanup(func() { runCodexTaskFn = orig })
+
+			order, err := validateTaskOrder(tt.order)
+			if err != nil {
+				t.Fatalf("validateTaskOrder(%q) error: %v", tt.order, err)
+			}
+			results := executeConcurrentWithOptions(context.Background(), layers, ConcurrentOptions{Timeout: 5, MaxWorkers: 1, TaskOrder: order})
+			for _, res := range results {
+				if res.LogPath != "" {
+					_ = os.Remove(res.LogPath)
+				}
+			}
+			if !slices.Equal(started, tt.want) {
+				t.Fatalf("start order = %v, want %v", started, tt.want)
+			}
+		})
+	}
+
+	if _, err := validateTaskOrder("random"); err == nil {
+		t.Fatalf("expected invalid task order to be rejected")
+	}
+}
+
 func TestExecutorSharedLogFalseWhenCustomLogPath(t *testing.T) {
 	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
 	if err != nil {
@@ -1136,3 +1283,384 @@ func TestExecutorSharedLogFalseWhenCustomLogPath(t *testing.T) {
 		t.Fatalf("expected custom LogPath %s, got %s", customLogPath, res.LogPath)
 	}
 }
+
+func TestExecutorExecuteConcurrentAdaptiveConcurrency(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = devNull
+	t.Cleanup(func() {
+		os.Stderr = oldStderr
+		_ = devNull.Close()
+	})
+
+	const (
+		workers   = 4
+		burst     = 4
+		taskCount = 24
+	)
+	var layer []TaskSpec
+	for i := 0; i < taskCount; i++ {
+		layer = append(layer, TaskSpec{ID: fmt.Sprintf("t%02d", i)})
+	}
+
+	var (
+		mu          sync.Mutex
+		active      int
+		concurrency = make(map[string]int, taskCount)
+	)
+	orig := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		mu.Lock()
+		active++
+		concurrency[task.ID] = active
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		var idx int
+		fmt.Sscanf(task.ID, "t%d", &idx)
+		if idx < burst {
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "codex exited with status 1; stderr: 429 Too Many Requests"}
+		}
+		return TaskResult{TaskID: task.ID, Message: "ok"}
+	}
+	t.Cleanup(func() { runCodexTaskFn = orig })
+
+	results := executeConcurrentWithOptions(context.Background(), [][]TaskSpec{layer}, ConcurrentOptions{
+		Timeout:             5,
+		MaxWorkers:          workers,
+		AdaptiveConcurrency: true,
+	})
+	for _, res := range results {
+		if res.LogPath != "" {
+			_ = os.Remove(res.LogPath)
+		}
+	}
+	if len(results) != taskCount {
+		t.Fatalf("got %d results, want %d", len(results), taskCount)
+	}
+
+	// The failure burst halves the limit down to 1, so the next task runs alone.
+	if got := concurrency[fmt.Sprintf("t%02d", burst)]; got != 1 {
+		t.Fatalf("concurrency after failure burst = %d, want 1", got)
+	}
+	// Successes ramp the limit back up to the ceiling.
+	recovered := 0
+	for i := taskCount - 8; i < taskCount; i++ {
+		if c := concurrency[fmt.Sprintf("t%02d", i)]; c > recovered {
+			recovered = c
+		}
+	}
+	if recovered != workers {
+		t.Fatalf("concurrency after recovery = %d, want %d (per task: %v)", recovered, workers, concurrency)
+	}
+}
+
+func TestExecutorExecuteConcurrentPriority(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = devNull
+	t.Cleanup(func() {
+		os.Stderr = oldStderr
+		_ = devNull.Close()
+	})
+
+	cfg, err := parseParallelConfig([]byte(`---TASK---
+id: low
+priority: -1
+---CONTENT---
+low
+---TASK---
+id: plain-a
+---CONTENT---
+a
+---TASK---
+id: critical
+priority: 10
+---CONTENT---
+c
+---TASK---
+id: plain-b
+---CONTENT---
+b
+---TASK---
+id: high
+priority: 5
+---CONTENT---
+h
+---TASK---
+id: after
+dependencies: low, critical
+priority: 1
+---CONTENT---
+x`))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() error: %v", err)
+	}
+	layers, err := topologicalSort(cfg.Tasks)
+	if err != nil {
+		t.Fatalf("topologicalSort() error: %v", err)
+	}
+
+	var mu sync.Mutex
+	var started []string
+	orig := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		mu.Lock()
+		started = append(started, task.ID)
+		mu.Unlock()
+		return TaskResult{TaskID: task.ID}
+	}
+	t.Cleanup(func() { runCodexTaskFn = orig })
+
+	results := executeConcurrentWithOptions(context.Background(), layers, ConcurrentOptions{Timeout: 5, MaxWorkers: 1})
+	for _, res := range results {
+		if res.LogPath != "" {
+			_ = os.Remove(res.LogPath)
+		}
+	}
+
+	want := []string{"critical", "high", "plain-a", "plain-b", "low", "after"}
+	if !slices.Equal(started, want) {
+		t.Fatalf("start order = %v, want %v", started, want)
+	}
+
+	if _, err := parseParallelConfig([]byte("---TASK---\nid: a\npriority: urgent\n---CONTENT---\nx")); err == nil || !strings.Contains(err.Error(), "invalid priority") {
+		t.Fatalf("expected invalid priority error, got %v", err)
+	}
+}
+
+func TestExecutorExecuteConcurrentFailFast(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = devNull
+	t.Cleanup(func() {
+		os.Stderr = oldStderr
+		_ = devNull.Close()
+	})
+
+	orig := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		switch task.ID {
+		case "bad":
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+		case "slow":
+			select {
+			case <-task.Context.Done():
+				return TaskResult{TaskID: task.ID, ExitCode: 130, Error: "execution cancelled"}
+			case <-time.After(5 * time.Second):
+			}
+		}
+		return TaskResult{TaskID: task.ID, Message: "ok"}
+	}
+	t.Cleanup(func() { runCodexTaskFn = orig })
+
+	layers := func() [][]TaskSpec {
+		return [][]TaskSpec{
+			{{ID: "bad"}, {ID: "slow"}},
+			{{ID: "next"}},
+		}
+	}
+	byID := func(results []TaskResult) map[string]TaskResult {
+		m := make(map[string]TaskResult, len(results))
+		for _, res := range results {
+			if res.LogPath != "" {
+				_ = os.Remove(res.LogPath)
+			}
+			m[res.TaskID] = res
+		}
+		return m
+	}
+
+	start := time.Now()
+	got := byID(executeConcurrentWithOptions(context.Background(), layers(), ConcurrentOptions{Timeout: 10, FailFast: true}))
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("fail-fast run took %v; in-flight task was not cancelled", elapsed)
+	}
+	if got["slow"].ExitCode != 130 {
+		t.Fatalf("in-flight task result = %+v, want cancelled", got["slow"])
+	}
+	if next := got["next"]; next.ExitCode == 0 || !strings.Contains(next.Error, "fail-fast after task bad failed") {
+		t.Fatalf("later layer result = %+v, want fail-fast skip", next)
+	}
+
+	// Without FailFast an independent task in a later layer still runs.
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		if task.ID == "bad" {
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+		}
+		return TaskResult{TaskID: task.ID, Message: "ok"}
+	}
+	got = byID(executeConcurrentWithOptions(context.Background(), layers(), ConcurrentOptions{Timeout: 10}))
+	if got["slow"].ExitCode != 0 || got["next"].ExitCode != 0 {
+		t.Fatalf("continue-on-failure results = %+v", got)
+	}
+}
+
+func TestExecutorExecuteConcurrentSystemicFailure(t *testing.T) {
+	var stderr bytes.Buffer
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = w
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&stderr, r)
+		close(done)
+	}()
+
+	orig := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		if strings.HasPrefix(task.ID, "a") {
+			return TaskResult{TaskID: task.ID, ExitCode: 127, Error: "codex command not found in PATH"}
+		}
+		return TaskResult{TaskID: task.ID, Message: "ok"}
+	}
+	t.Cleanup(func() { runCodexTaskFn = orig })
+
+	layers := [][]TaskSpec{
+		{{ID: "a1"}, {ID: "a2"}, {ID: "a3"}, {ID: "b1"}},
+		{{ID: "next"}},
+	}
+	t.Setenv("CODEAGENT_SYSTEMIC_FAILURE_ABORT", "1")
+	results := executeConcurrentWithOptions(context.Background(), layers, ConcurrentOptions{Timeout: 10})
+
+	os.Stderr = oldStderr
+	_ = w.Close()
+	<-done
+
+	var next TaskResult
+	for _, res := range results {
+		if res.LogPath != "" {
+			_ = os.Remove(res.LogPath)
+		}
+		if res.TaskID == "next" {
+			next = res
+		}
+	}
+	if !strings.Contains(stderr.String(), "systemic failure: 3 of 4 tasks in layer 1 exited 127") {
+		t.Fatalf("stderr = %q, want the systemic failure diagnostic", stderr.String())
+	}
+	if next.ExitCode == 0 || !strings.Contains(next.Error, "layer 1 failed systemically (exit 127)") {
+		t.Fatalf("later layer result = %+v, want it skipped", next)
+	}
+}
+
+func TestExecutorExecuteConcurrentDrainOnInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signal-based test is not supported on Windows")
+	}
+	defer signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = devNull
+	t.Cleanup(func() {
+		os.Stderr = oldStderr
+		_ = devNull.Close()
+	})
+
+	interrupt := func() {
+		if proc, err := os.FindProcess(os.Getpid()); err == nil && proc != nil {
+			_ = proc.Signal(syscall.SIGINT)
+		}
+	}
+	byID := func(results []TaskResult) map[string]TaskResult {
+		m := make(map[string]TaskResult, len(results))
+		for _, res := range results {
+			if res.LogPath != "" {
+				_ = os.Remove(res.LogPath)
+			}
+			m[res.TaskID] = res
+		}
+		return m
+	}
+	layers := [][]TaskSpec{{{ID: "a"}, {ID: "b"}}, {{ID: "c"}}}
+
+	t.Run("running task finishes", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		orig := runCodexTaskFn
+		runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+			if task.ID == "a" {
+				close(started)
+				select {
+				case <-release:
+				case <-task.Context.Done():
+					return TaskResult{TaskID: task.ID, ExitCode: 130, Error: "execution cancelled"}
+				}
+			}
+			return TaskResult{TaskID: task.ID, Message: "ok"}
+		}
+		t.Cleanup(func() { runCodexTaskFn = orig })
+
+		go func() {
+			<-started
+			interrupt()
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+		}()
+		got := byID(executeConcurrentWithOptions(context.Background(), layers, ConcurrentOptions{Timeout: 10, MaxWorkers: 1, DrainOnInterrupt: true}))
+
+		if res := got["a"]; res.ExitCode != 0 || res.Interrupted {
+			t.Fatalf("in-flight task = %+v, want it to finish normally", res)
+		}
+		for _, id := range []string{"b", "c"} {
+			if res := got[id]; !res.Interrupted || res.ExitCode != 130 {
+				t.Fatalf("task %s = %+v, want interrupted with exit 130", id, res)
+			}
+		}
+		if out := generateFinalOutputWithMode([]TaskResult{got["b"]}, true); !strings.Contains(out, "NOT STARTED (interrupted)") {
+			t.Fatalf("report missing interrupted status:\n%s", out)
+		}
+	})
+
+	t.Run("grace expires", func(t *testing.T) {
+		t.Setenv("CODEAGENT_KILL_GRACE", "1")
+		started := make(chan struct{})
+		orig := runCodexTaskFn
+		runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+			if task.ID == "a" {
+				close(started)
+				select {
+				case <-task.Context.Done():
+					return TaskResult{TaskID: task.ID, ExitCode: 130, Error: "execution cancelled"}
+				case <-time.After(10 * time.Second):
+				}
+			}
+			return TaskResult{TaskID: task.ID, Message: "ok"}
+		}
+		t.Cleanup(func() { runCodexTaskFn = orig })
+
+		go func() {
+			<-started
+			interrupt()
+		}()
+		start := time.Now()
+		got := byID(executeConcurrentWithOptions(context.Background(), layers, ConcurrentOptions{Timeout: 30, MaxWorkers: 1, DrainOnInterrupt: true}))
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("drain took %v, want the running task cancelled after the kill grace", elapsed)
+		}
+		if res := got["a"]; res.ExitCode != 130 || res.Interrupted {
+			t.Fatalf("in-flight task = %+v, want it cancelled", res)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package wrapper
+
+import (
+	"fmt"
+	"strings"
+
+	"codeagent-wrapper/internal/worktree"
+)
+
+// maxDirtyEntriesShown bounds how many `git status` entries the
+// --workdir-git-check message lists.
+const maxDirtyEntriesShown = 10
+
+// checkWorkdirClean implements --workdir-git-check and --require-clean. A
+// dirty tree only warns unless requireClean is set; non-git workdirs are not
+// checked.
+func checkWorkdirClean(workdir string, requireClean bool) error {
+	changes, isRepo, err := worktree.UncommittedChanges(workdir)
+	if err != nil {
+		if requireClean {
+			return fmt.Errorf("--require-clean: %w", err)
+		}
+		logWarn(fmt.Sprintf("Workdir git check skipped: %v", err))
+		return nil
+	}
+	if !isRepo {
+		logInfo(fmt.Sprintf("Workdir git check skipped: %s is not a git repository", workdir))
+		return nil
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	shown := changes
+	if len(shown) > maxDirtyEntriesShown {
+		shown = shown[:maxDirtyEntriesShown]
+	}
+	summary := strings.Join(shown, "; ")
+	if extra := len(changes) - len(shown); extra > 0 {
+		summary += fmt.Sprintf("; ... and %d more", extra)
+	}
+	msg := fmt.Sprintf("workdir %s has %d uncommitted change(s): %s", workdir, len(changes), summary)
+	if requireClean {
+		return fmt.Errorf("--require-clean: %s", msg)
+	}
+	logWarn(msg + "; agent edits will be mixed with them")
+	return nil
+}
@@ -0,0 +1,53 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	backend "codeagent-wrapper/internal/backend"
+)
+
+// listBackendsTask stands in for the task in the sample argument lists.
+const listBackendsTask = "<task>"
+
+// printBackends implements --list-backends: every registered backend with the
+// command it launches and the arguments a new task in "." would get.
+func printBackends(w io.Writer) {
+	registry := backend.Registry()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		b := registry[name]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		command := b.Command()
+		if resolved := resolveBackendCommand(command); resolved != command {
+			command = fmt.Sprintf("%s -> %s", command, resolved)
+		}
+		cfg := &Config{Mode: "new", Backend: b.Name(), WorkDir: defaultWorkdir}
+		fmt.Fprintln(w, b.Name())
+		fmt.Fprintf(w, "  Command: %s\n", command)
+		fmt.Fprintf(w, "  Args: %s\n", formatSampleArgs(b.BuildArgs(cfg, listBackendsTask)))
+	}
+}
+
+// formatSampleArgs quotes empty or space-containing arguments so the printed
+// list can be read back unambiguously.
+func formatSampleArgs(args []string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n") {
+			arg = strconv.Quote(arg)
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,6 +1,10 @@
 package wrapper
 
-import ilogger "codeagent-wrapper/internal/logger"
+import (
+	"os"
+
+	ilogger "codeagent-wrapper/internal/logger"
+)
 
 type Logger = ilogger.Logger
 type CleanupStats = ilogger.CleanupStats
@@ -9,12 +13,16 @@ func NewLogger() (*Logger, error) { return ilogger.NewLogger() }
 
 func NewLoggerWithSuffix(suffix string) (*Logger, error) { return ilogger.NewLoggerWithSuffix(suffix) }
 
+func newFallbackLogger() *Logger { return ilogger.NewFallbackLogger(os.Stderr) }
+
 func setLogger(l *Logger) { ilogger.SetLogger(l) }
 
 func closeLogger() error { return ilogger.CloseLogger() }
 
 func activeLogger() *Logger { return ilogger.ActiveLogger() }
 
+func logDebug(msg string) { ilogger.LogDebug(msg) }
+
 func logInfo(msg string) { ilogger.LogInfo(msg) }
 
 func logWarn(msg string) { ilogger.LogWarn(msg) }
@@ -23,4 +31,8 @@ func logError(msg string) { ilogger.LogError(msg) }
 
 func cleanupOldLogs() (CleanupStats, error) { return ilogger.CleanupOldLogs() }
 
+func trimLogsToLimit(maxLogs int) (CleanupStats, error) { return ilogger.TrimLogsToLimit(maxLogs) }
+
+func latestLogPath() (string, error) { return ilogger.LatestLogPath() }
+
 func sanitizeLogSuffix(raw string) string { return ilogger.SanitizeLogSuffix(raw) }
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -370,8 +373,11 @@ b`
 	os.Args = []string{"codeagent-wrapper", "--parallel"}
 
 	exitCode := 0
-	output := captureStdout(t, func() {
-		exitCode = run()
+	var output string
+	errOutput := captureStderr(t, func() {
+		output = captureStdout(t, func() {
+			exitCode = run()
+		})
 	})
 
 	if exitCode == 0 {
@@ -380,6 +386,9 @@ b`
 	if strings.TrimSpace(output) != "" {
 		t.Fatalf("expected no JSON output on cycle, got %q", output)
 	}
+	if !strings.Contains(errOutput, "cycle detected: A -> B -> A") {
+		t.Fatalf("stderr should name the cycle, got %q", errOutput)
+	}
 }
 
 func TestRunParallelOutputsIncludeLogPaths(t *testing.T) {
@@ -660,6 +669,94 @@ ok-e`
 	}
 }
 
+func TestRunParallelPerTaskBackend(t *testing.T) {
+	defer resetTestHooks()
+	origRun := runCodexTaskFn
+	t.Cleanup(func() {
+		runCodexTaskFn = origRun
+		resetTestHooks()
+	})
+
+	var mu sync.Mutex
+	backends := make(map[string]string)
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		mu.Lock()
+		backends[task.ID] = task.Backend
+		mu.Unlock()
+		return TaskResult{TaskID: task.ID, ExitCode: 0, Message: task.Task}
+	}
+
+	input := `---TASK---
+id: explore
+backend: opencode
+---CONTENT---
+map the repo
+---TASK---
+id: develop
+dependencies: explore
+---CONTENT---
+implement it`
+	stdinReader = bytes.NewReader([]byte(input))
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--backend", "codex"}
+
+	var exitCode int
+	_ = captureStdout(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 0 {
+		t.Fatalf("exit code = %d, want 0", exitCode)
+	}
+	if backends["explore"] != "opencode" || backends["develop"] != "codex" {
+		t.Fatalf("task backends = %v, want explore=opencode develop=codex", backends)
+	}
+}
+
+func TestRunResumeFile(t *testing.T) {
+	defer resetTestHooks()
+	origRun := runCodexTaskFn
+	t.Cleanup(func() {
+		runCodexTaskFn = origRun
+		resetTestHooks()
+	})
+
+	var mu sync.Mutex
+	resumed := make(map[string]string)
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		mu.Lock()
+		resumed[task.SessionID] = task.Mode + ":" + task.Task
+		mu.Unlock()
+		if task.SessionID == "ses_b" {
+			return TaskResult{TaskID: task.ID, SessionID: task.SessionID, ExitCode: 1, Error: "boom"}
+		}
+		return TaskResult{TaskID: task.ID, SessionID: task.SessionID, ExitCode: 0, Message: "done"}
+	}
+
+	path := filepath.Join(t.TempDir(), "sessions.tsv")
+	if err := os.WriteFile(path, []byte("ses_a\tcontinue a\nses_b\tcontinue b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Args = []string{"codeagent-wrapper", "--resume-file", path}
+
+	var exitCode int
+	output := captureStdout(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 1 {
+		t.Fatalf("exit code = %d, want 1", exitCode)
+	}
+	if resumed["ses_a"] != "resume:continue a" || resumed["ses_b"] != "resume:continue b" {
+		t.Fatalf("resumed = %v", resumed)
+	}
+	if !strings.Contains(output, "ses_a") || !strings.Contains(output, "ses_b") {
+		t.Fatalf("report missing per-session results:\n%s", output)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--resume-file", path}
+	if code := run(); code == 0 {
+		t.Fatalf("--parallel with --resume-file should fail")
+	}
+}
+
 func TestRunParallelTimeoutPropagation(t *testing.T) {
 	defer resetTestHooks()
 	origRun := runCodexTaskFn
@@ -703,6 +800,84 @@ slow`
 	}
 }
 
+func TestRunParallelRunTimeout(t *testing.T) {
+	defer resetTestHooks()
+	origRun := runCodexTaskFn
+	t.Cleanup(func() {
+		runCodexTaskFn = origRun
+		resetTestHooks()
+	})
+
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		<-task.Context.Done()
+		return TaskResult{TaskID: task.ID, ExitCode: 124, Error: "codex execution timeout"}
+	}
+
+	t.Setenv("CODEAGENT_RUN_TIMEOUT", "1")
+	input := `---TASK---
+id: slow
+---CONTENT---
+never finishes
+---TASK---
+id: queued
+---CONTENT---
+waits for a worker slot`
+	stdinReader = bytes.NewReader([]byte(input))
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--max-parallel", "1"}
+
+	exitCode := 0
+	output := captureStdout(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 124 {
+		t.Fatalf("exit code = %d, want 124", exitCode)
+	}
+	payload := parseIntegrationOutput(t, output)
+	if res := findResultByID(t, payload, "slow"); res.ExitCode != 124 {
+		t.Fatalf("in-flight task = %+v, want it terminated with 124", res)
+	}
+	if res := findResultByID(t, payload, "queued"); res.ExitCode != 124 || !strings.Contains(res.Error, "run timeout") {
+		t.Fatalf("queued task = %+v, want it marked as timed out", res)
+	}
+}
+
+func TestRunParallelDrainExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signal-based test is not supported on Windows")
+	}
+	defer resetTestHooks()
+	defer signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+	origRun := runCodexTaskFn
+	t.Cleanup(func() {
+		runCodexTaskFn = origRun
+		resetTestHooks()
+	})
+
+	// The interrupt arrives during the last layer and the task still
+	// finishes within the grace: nothing is marked interrupted.
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		if proc, err := os.FindProcess(os.Getpid()); err == nil {
+			_ = proc.Signal(syscall.SIGINT)
+		}
+		time.Sleep(100 * time.Millisecond)
+		return TaskResult{TaskID: task.ID, Message: "done"}
+	}
+
+	stdinReader = bytes.NewReader([]byte("---TASK---\nid: last\n---CONTENT---\nfinishes in the grace"))
+	os.Args = []string{"codeagent-wrapper", "--parallel"}
+
+	exitCode := 0
+	output := captureStdout(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 130 {
+		t.Fatalf("exit code = %d, want 130 after a drain", exitCode)
+	}
+	if res := findResultByID(t, parseIntegrationOutput(t, output), "last"); res.ExitCode != 0 {
+		t.Fatalf("drained task = %+v, want it to finish", res)
+	}
+}
+
 func TestRunConcurrentSpeedupBenchmark(t *testing.T) {
 	defer resetTestHooks()
 	origRun := runCodexTaskFn
@@ -948,3 +1123,83 @@ func TestRunCleanupFlagEndToEnd_FailureDoesNotAffectStartup(t *testing.T) {
 		t.Fatalf("normal run output = %q, want codex output", normalOutput)
 	}
 }
+
+func TestRunParallelConfigErrorsUseConfigExitCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "cycle",
+			input: `---TASK---
+id: A
+dependencies: B
+---CONTENT---
+a
+---TASK---
+id: B
+dependencies: A
+---CONTENT---
+b`,
+		},
+		{
+			name: "missing dependency",
+			input: `---TASK---
+id: A
+dependencies: ghost
+---CONTENT---
+a`,
+		},
+		{
+			name: "duplicate id",
+			input: `---TASK---
+id: A
+---CONTENT---
+a
+---TASK---
+id: A
+---CONTENT---
+b`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetTestHooks()
+			origRun := runCodexTaskFn
+			runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+				t.Errorf("task %s should not execute on config error", task.ID)
+				return TaskResult{TaskID: task.ID}
+			}
+			t.Cleanup(func() { runCodexTaskFn = origRun })
+
+			stdinReader = strings.NewReader(tt.input)
+			os.Args = []string{"codeagent-wrapper", "--parallel"}
+
+			exitCode := 0
+			_ = captureStdout(t, func() {
+				exitCode = run()
+			})
+			if exitCode != exitConfigError {
+				t.Fatalf("exit = %d, want %d", exitCode, exitConfigError)
+			}
+		})
+	}
+
+	// A task that runs and fails keeps the ordinary failure code.
+	defer resetTestHooks()
+	origRun := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+	}
+	t.Cleanup(func() { runCodexTaskFn = origRun })
+	stdinReader = strings.NewReader("---TASK---\nid: A\n---CONTENT---\na")
+	os.Args = []string{"codeagent-wrapper", "--parallel"}
+	exitCode := 0
+	_ = captureStdout(t, func() {
+		exitCode = run()
+	})
+	if exitCode == 0 || exitCode == exitConfigError {
+		t.Fatalf("task failure exit = %d, want a non-config failure code", exitCode)
+	}
+}
@@ -11,7 +11,10 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -21,10 +24,26 @@ import (
 
 	config "codeagent-wrapper/internal/config"
 	executor "codeagent-wrapper/internal/executor"
+	worktree "codeagent-wrapper/internal/worktree"
 
 	"github.com/goccy/go-json"
 )
 
+// TestMain points HOME at a scratch directory so successful runs do not write
+// to the real session registry (~/.codeagent/sessions.json).
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "codeagent-wrapper-home-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create test home: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("USERPROFILE", home)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 // Helper to reset test hooks
 func resetTestHooks() {
 	stdinReader = os.Stdin
@@ -32,6 +51,19 @@ func resetTestHooks() {
 	codexCommand = "codex"
 	cleanupHook = nil
 	cleanupLogsFn = cleanupOldLogs
+	trimLogsFn = trimLogsToLimit
+	latestLogFn = latestLogPath
+	clipboardLookPathFn = exec.LookPath
+	clipboardCommandsFn = defaultClipboardCommands
+	detachExecutableFn = os.Executable
+	detachCommandFn = exec.Command
+	detachHandleDirFn = defaultDetachHandleDir
+	processAliveFn = processAlive
+	attachPollInterval = 200 * time.Millisecond
+	attachStreamOutput = nil
+	maxRetainedLogs = 0
+	startupProfiler = nil
+	logAlsoStderr = false
 	startupCleanupAsync = false
 	config.ResetModelsConfigCacheForTest()
 	_ = executor.SetSelectBackendFn(nil)
@@ -787,6 +819,45 @@ func TestFakeCmdInfra(t *testing.T) {
 	})
 }
 
+type postProcessBackend struct {
+	testBackend
+}
+
+func (postProcessBackend) PostProcess(msg string) string { return "processed:" + msg }
+
+func TestRunCodexTask_AppliesBackendPostProcess(t *testing.T) {
+	defer resetTestHooks()
+
+	newFake := func() *fakeCmd {
+		return newFakeCmd(fakeCmdConfig{
+			StdoutPlan: []fakeStdoutEvent{
+				{Data: `{"type":"thread.started","thread_id":"pp-thread"}` + "\n"},
+				{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"raw-msg"}}` + "\n"},
+			},
+			WaitDelay: 5 * time.Millisecond,
+		})
+	}
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return newFake() })
+
+	withHook := postProcessBackend{testBackend{name: "pp", command: "fake-cmd"}}
+	res := runCodexTaskWithContext(context.Background(), TaskSpec{Task: "task"}, withHook, nil, false, true, 2)
+	if res.ExitCode != 0 {
+		t.Fatalf("exit = %d, want 0 (%s)", res.ExitCode, res.Error)
+	}
+	if res.Message != "processed:raw-msg" {
+		t.Fatalf("message = %q, want processed:raw-msg", res.Message)
+	}
+
+	plain := testBackend{name: "plain", command: "fake-cmd"}
+	res = runCodexTaskWithContext(context.Background(), TaskSpec{Task: "task"}, plain, nil, false, true, 2)
+	if res.ExitCode != 0 {
+		t.Fatalf("exit = %d, want 0 (%s)", res.ExitCode, res.Error)
+	}
+	if res.Message != "raw-msg" {
+		t.Fatalf("message = %q, want raw-msg", res.Message)
+	}
+}
+
 func TestRunCodexTask_WaitBeforeParse(t *testing.T) {
 	defer resetTestHooks()
 
@@ -955,6 +1026,81 @@ func TestRunCodexTask_ContextTimeout(t *testing.T) {
 	}
 }
 
+func TestRunCodexTask_KillGraceEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGTERM grace does not apply on Windows")
+	}
+	defer resetTestHooks()
+	_ = executor.SetForceKillDelay(0)
+	// Values below one second are clamped to the minimum grace.
+	t.Setenv("CODEAGENT_KILL_GRACE", "0")
+
+	fake := newFakeCmd(fakeCmdConfig{
+		KeepStdoutOpen:    true,
+		BlockWait:         true,
+		ReleaseWaitOnKill: true,
+	})
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return fake })
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string {
+		return []string{targetArg}
+	}
+	codexCommand = "fake-cmd"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := runCodexTaskWithContext(ctx, TaskSpec{Task: "kill-grace", WorkDir: defaultWorkdir}, nil, nil, false, false, 60)
+	elapsed := time.Since(start)
+
+	if result.ExitCode != 124 {
+		t.Fatalf("exit code = %d, want 124 (%s)", result.ExitCode, result.Error)
+	}
+	if fake.process.SignalCount() == 0 || fake.process.KillCount() == 0 {
+		t.Fatalf("expected SIGTERM then Kill, got signals=%d kills=%d", fake.process.SignalCount(), fake.process.KillCount())
+	}
+	if elapsed < time.Second {
+		t.Fatalf("process was killed after %s, want at least the 1s minimum grace", elapsed)
+	}
+}
+
+func TestRunCodexTask_AbortsAfterTooManyReconnects(t *testing.T) {
+	defer resetTestHooks()
+	_ = executor.SetForceKillDelay(0)
+	t.Setenv("CODEAGENT_MAX_RECONNECTS", "2")
+
+	fake := newFakeCmd(fakeCmdConfig{
+		StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"thread.started","thread_id":"rc-thread"}` + "\n"},
+			{Data: `{"type":"error","message":"Reconnecting... 1/5"}` + "\n"},
+			{Data: `{"type":"error","message":"Reconnecting... 2/5"}` + "\n"},
+			{Data: `{"type":"error","message":"Reconnecting... 3/5"}` + "\n"},
+		},
+		KeepStdoutOpen:      true,
+		BlockWait:           true,
+		ReleaseWaitOnKill:   true,
+		ReleaseWaitOnSignal: true,
+	})
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner { return fake })
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	codexCommand = "fake-cmd"
+
+	start := time.Now()
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 60)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("run took %s, want an early abort", elapsed)
+	}
+	if res.ExitCode != 75 {
+		t.Fatalf("exit code = %d, want 75 (%s)", res.ExitCode, res.Error)
+	}
+	if !strings.Contains(res.Error, "3 reconnects") || !strings.Contains(res.Error, "Reconnecting... 3/5") {
+		t.Fatalf("error = %q, want the reconnect count and last message", res.Error)
+	}
+	if res.SessionID != "rc-thread" {
+		t.Fatalf("session id = %q, want it preserved", res.SessionID)
+	}
+}
+
 func TestRunCodexTask_ForcesStopAfterCompletion(t *testing.T) {
 	defer resetTestHooks()
 	_ = executor.SetForceKillDelay(0)
@@ -1164,6 +1310,15 @@ func TestBackendParseArgs_ResumeMode(t *testing.T) {
 		{name: "resume empty session_id", args: []string{"codeagent-wrapper", "resume", "", "task"}, wantErr: true},
 		{name: "resume whitespace session_id", args: []string{"codeagent-wrapper", "resume", "   ", "task"}, wantErr: true},
 		{name: "resume with dash workdir rejected", args: []string{"codeagent-wrapper", "resume", "session-123", "task", "-"}, wantErr: true},
+		{
+			name: "resume strips quotes from session_id",
+			args: []string{"codeagent-wrapper", "resume", `"019a7247-ac9d-71f3"`, "-"},
+			want: &Config{Mode: "resume", SessionID: "019a7247-ac9d-71f3", Task: "-", WorkDir: ".", ExplicitStdin: true, Backend: defaultBackendName},
+		},
+		{name: "resume session_id with whitespace", args: []string{"codeagent-wrapper", "resume", "abc def", "task"}, wantErr: true},
+		{name: "resume session_id with shell metacharacters", args: []string{"codeagent-wrapper", "resume", "abc;rm", "task"}, wantErr: true},
+		{name: "resume session_id that looks like a flag", args: []string{"codeagent-wrapper", "resume", "--last", "task"}, wantErr: true},
+		{name: "resume quoted empty session_id", args: []string{"codeagent-wrapper", "resume", `''`, "task"}, wantErr: true},
 	}
 
 	for _, tt := range tests {
@@ -1302,6 +1457,238 @@ func TestBackendParseArgs_ModelFlag(t *testing.T) {
 	}
 }
 
+func TestBackendParseArgs_ClaudeAllowFlag(t *testing.T) {
+	defer resetTestHooks()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	allowFile := filepath.Join(t.TempDir(), "allow.json")
+	if err := os.WriteFile(allowFile, []byte(`{"permissions":{"allow":["Bash(go test:*)"]}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--backend", "claude", "--claude-allow", allowFile, "task"}
+	cfg, err := parseArgs()
+	if err != nil {
+		t.Fatalf("parseArgs() unexpected error: %v", err)
+	}
+	if cfg.ClaudeAllowFile != allowFile {
+		t.Fatalf("ClaudeAllowFile = %q, want %q", cfg.ClaudeAllowFile, allowFile)
+	}
+
+	args := ClaudeBackend{}.BuildArgs(cfg, "task")
+	hasPair := func(flag, value string) bool {
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == flag && args[i+1] == value {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasPair("--settings", allowFile) {
+		t.Fatalf("claude args missing --settings %s: %v", allowFile, args)
+	}
+	if !hasPair("--setting-sources", "") {
+		t.Fatalf("claude args lost recursion prevention: %v", args)
+	}
+
+	for _, bad := range [][]string{
+		{"codeagent-wrapper", "--claude-allow", filepath.Join(t.TempDir(), "missing.json"), "task"},
+		{"codeagent-wrapper", "--claude-allow", t.TempDir(), "task"},
+		{"codeagent-wrapper", "--claude-allow=", "task"},
+	} {
+		os.Args = bad
+		if _, err := parseArgs(); err == nil {
+			t.Fatalf("parseArgs(%v) expected error, got nil", bad[1:])
+		}
+	}
+}
+
+func TestParallelRejectsClaudeAllow(t *testing.T) {
+	defer resetTestHooks()
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--claude-allow", "allow.json"}
+	stdinReader = strings.NewReader("---TASK---\nid: a\n---CONTENT---\nx")
+	if code := run(); code == 0 {
+		t.Fatalf("expected non-zero exit for --claude-allow in parallel mode")
+	}
+}
+
+func TestParallelRejectedFlagsMessage(t *testing.T) {
+	defer resetTestHooks()
+	cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--quiet"}
+	stdinReader = strings.NewReader("---TASK---\nid: a\n---CONTENT---\nx")
+
+	var code int
+	stderr := captureStderr(t, func() { code = run() })
+	if code != 1 {
+		t.Fatalf("run exit = %d, want 1", code)
+	}
+	if !strings.Contains(stderr, "--quiet is not supported") {
+		t.Fatalf("stderr = %q, want it to name the rejected flag", stderr)
+	}
+	allowed := stderr[strings.Index(stderr, "Allowed flags:"):]
+	for _, flag := range []string{"--timeout", "--max-parallel", "--backend"} {
+		if !strings.Contains(allowed, flag) {
+			t.Fatalf("allowed list %q missing %s", allowed, flag)
+		}
+	}
+	for _, flag := range append([]string{"parallel", "resume-file"}, parallelRejectedFlags...) {
+		if strings.Contains(allowed, "--"+flag+",") || strings.Contains(allowed, "--"+flag+".") {
+			t.Fatalf("allowed list %q advertises --%s", allowed, flag)
+		}
+	}
+}
+
+func TestParallelRejectsSingleModeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"resume", []string{"--parallel", "resume", "sid-1", "task"}, "cannot be combined with resume"},
+		{"positional task", []string{"--parallel", "do the thing"}, `positional task ("do the thing")`},
+		{"task and workdir", []string{"--parallel", "task", "/tmp"}, "no positional arguments are allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetTestHooks()
+			cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+			oldArgs := os.Args
+			t.Cleanup(func() { os.Args = oldArgs })
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+			stdinReader = strings.NewReader("---TASK---\nid: a\n---CONTENT---\nx")
+
+			var code int
+			stderr := captureStderr(t, func() { code = run() })
+			if code != 1 {
+				t.Fatalf("run exit = %d, want 1", code)
+			}
+			if !strings.Contains(stderr, tt.want) {
+				t.Fatalf("stderr = %q, want it to contain %q", stderr, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendParseArgs_TaskJSON(t *testing.T) {
+	defer resetTestHooks()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	taskFile := filepath.Join(t.TempDir(), "task.json")
+	doc := `{"id":"T-1","prompt":"top level","task":{"input":{"prompt":"nested prompt\nsecond line"},"steps":[{"prompt":"step zero"}]}}`
+	if err := os.WriteFile(taskFile, []byte(doc), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		workdir string
+		mode    string
+	}{
+		{name: "default field", args: []string{"--task-json", taskFile}, want: "top level", workdir: defaultWorkdir, mode: "new"},
+		{name: "nested field", args: []string{"--task-json", taskFile, "--task-field", "task.input.prompt", "/tmp/work"}, want: "nested prompt\nsecond line", workdir: "/tmp/work", mode: "new"},
+		{name: "array index", args: []string{"--task-json", taskFile, "--task-field", "task.steps.0.prompt"}, want: "step zero", workdir: defaultWorkdir, mode: "new"},
+		{name: "resume", args: []string{"--task-json", taskFile, "resume", "sid-1", "/tmp/work"}, want: "top level", workdir: "/tmp/work", mode: "resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+			cfg, err := parseArgs()
+			if err != nil {
+				t.Fatalf("parseArgs() unexpected error: %v", err)
+			}
+			if cfg.Task != tt.want || cfg.WorkDir != tt.workdir || cfg.Mode != tt.mode || cfg.ExplicitStdin {
+				t.Fatalf("cfg = {Task:%q WorkDir:%q Mode:%q ExplicitStdin:%v}, want task %q workdir %q mode %q", cfg.Task, cfg.WorkDir, cfg.Mode, cfg.ExplicitStdin, tt.want, tt.workdir, tt.mode)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing field", args: []string{"--task-json", taskFile, "--task-field", "task.input.missing"}, wantErr: `field "task.input.missing" not found`},
+		{name: "non-string field", args: []string{"--task-json", taskFile, "--task-field", "task.input"}, wantErr: "is not a string"},
+		{name: "field without json", args: []string{"--task-field", "prompt", "task"}, wantErr: "--task-field requires --task-json"},
+		{name: "missing file", args: []string{"--task-json", filepath.Join(t.TempDir(), "missing.json")}, wantErr: "--task-json"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+			_, err := parseArgs()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("parseArgs() err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackendParseArgs_TaskFromTemplate(t *testing.T) {
+	defer resetTestHooks()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "task.tmpl")
+	if err := os.WriteFile(tmpl, []byte("Review {{name}} in {{ dir }}.\nFocus on \"{{name}}\" edge cases; keep {{literal braces}}."), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		workdir string
+		mode    string
+	}{
+		{name: "substitutes vars", args: []string{"--task-from-template", tmpl, "--var", "name=parser", "--var", "dir=internal/parser"}, want: "Review parser in internal/parser.\nFocus on \"parser\" edge cases; keep {{literal braces}}.", workdir: defaultWorkdir, mode: "new"},
+		{name: "value with equals and workdir", args: []string{"--task-from-template", tmpl, "--var", "name=a=b", "--var", "dir=x", "/tmp/work"}, want: "Review a=b in x.\nFocus on \"a=b\" edge cases; keep {{literal braces}}.", workdir: "/tmp/work", mode: "new"},
+		{name: "later var wins", args: []string{"--task-from-template", tmpl, "--var", "name=old", "--var", "name=new", "--var", "dir=d"}, want: "Review new in d.\nFocus on \"new\" edge cases; keep {{literal braces}}.", workdir: defaultWorkdir, mode: "new"},
+		{name: "resume", args: []string{"--task-from-template", tmpl, "--var", "name=n", "--var", "dir=d", "resume", "sid-1"}, want: "Review n in d.\nFocus on \"n\" edge cases; keep {{literal braces}}.", workdir: defaultWorkdir, mode: "resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+			cfg, err := parseArgs()
+			if err != nil {
+				t.Fatalf("parseArgs() unexpected error: %v", err)
+			}
+			if cfg.Task != tt.want || cfg.WorkDir != tt.workdir || cfg.Mode != tt.mode || cfg.ExplicitStdin {
+				t.Fatalf("cfg = {Task:%q WorkDir:%q Mode:%q ExplicitStdin:%v}, want task %q workdir %q mode %q", cfg.Task, cfg.WorkDir, cfg.Mode, cfg.ExplicitStdin, tt.want, tt.workdir, tt.mode)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing vars", args: []string{"--task-from-template", tmpl}, wantErr: "missing template variables in " + tmpl + ": dir, name"},
+		{name: "one missing var", args: []string{"--task-from-template", tmpl, "--var", "name=x"}, wantErr: ": dir (pass --var name=value)"},
+		{name: "malformed var", args: []string{"--task-from-template", tmpl, "--var", "name"}, wantErr: `invalid --var "name"`},
+		{name: "var without template", args: []string{"--var", "name=x", "task"}, wantErr: "--var requires --task-from-template"},
+		{name: "with task-json", args: []string{"--task-from-template", tmpl, "--task-json", tmpl}, wantErr: "mutually exclusive"},
+		{name: "missing file", args: []string{"--task-from-template", filepath.Join(dir, "missing.tmpl")}, wantErr: "--task-from-template"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+			_, err := parseArgs()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("parseArgs() err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestBackendParseArgs_ReasoningEffortFlag(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1702,6 +2089,59 @@ do something`
 	}
 }
 
+func TestParallelParseConfig_ResumeWithWorktreeRejected(t *testing.T) {
+	input := `---TASK---
+id: task-1
+session_id: ses_1
+worktree: true
+---CONTENT---
+continue`
+	_, err := parseParallelConfig([]byte(input))
+	if err == nil || !strings.Contains(err.Error(), "cannot combine session_id with worktree") {
+		t.Fatalf("expected resume+worktree error, got %v", err)
+	}
+
+	if _, err := parseParallelConfig([]byte(`[{"id": "t", "task": "continue", "session_id": "ses_1", "worktree": true}]`)); err == nil {
+		t.Fatalf("expected resume+worktree error for JSON config, got nil")
+	}
+
+	defer resetTestHooks()
+	os.Args = []string{"codeagent-wrapper", "--worktree", "resume", "ses_1", "continue"}
+	if _, err := parseArgs(); err == nil || !strings.Contains(err.Error(), "--worktree cannot be combined with resume mode") {
+		t.Fatalf("expected single-mode resume+worktree error, got %v", err)
+	}
+}
+
+func TestParseArgs_WorkdirFlag(t *testing.T) {
+	defer resetTestHooks()
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"long flag", []string{"--workdir", "/repo", "task"}, "/repo"},
+		{"shorthand", []string{"-C", "/repo", "--backend", "claude", "task"}, "/repo"},
+		{"overrides positional", []string{"-C", "/repo", "task", "/elsewhere"}, "/repo"},
+		{"positional still works", []string{"task", "/elsewhere"}, "/elsewhere"},
+		{"resume", []string{"resume", "ses_1", "continue", "-C", "/repo"}, "/repo"},
+	}
+	for _, tt := range tests {
+		os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+		cfg, err := parseArgs()
+		if err != nil {
+			t.Fatalf("%s: parseArgs() error: %v", tt.name, err)
+		}
+		if cfg.WorkDir != tt.want {
+			t.Errorf("%s: WorkDir = %q, want %q", tt.name, cfg.WorkDir, tt.want)
+		}
+	}
+
+	os.Args = []string{"codeagent-wrapper", "-C", "-", "task"}
+	if _, err := parseArgs(); err == nil {
+		t.Fatalf("expected error for --workdir -")
+	}
+}
+
 func TestParallelParseConfig_WorktreeBooleanValue(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -1748,43 +2188,142 @@ do something`
 	}
 }
 
-func TestParallelParseConfig_InvalidFormat(t *testing.T) {
-	if _, err := parseParallelConfig([]byte("invalid format")); err == nil {
-		t.Fatalf("expected error for invalid format, got nil")
-	}
-}
-
-func TestParallelParseConfig_EmptyTasks(t *testing.T) {
+func TestParallelParseConfig_InvalidSessionID(t *testing.T) {
 	input := `---TASK---
-id: empty
+id: task-1
+session_id: 'ses_123'
 ---CONTENT---
-`
-	if _, err := parseParallelConfig([]byte(input)); err == nil {
-		t.Fatalf("expected error for empty tasks array, got nil")
+continue
+---TASK---
+id: task-2
+session_id: ses_1 $(whoami)
+---CONTENT---
+continue`
+
+	_, err := parseParallelConfig([]byte(input))
+	if err == nil || !strings.Contains(err.Error(), "invalid session id") || !strings.Contains(err.Error(), "task-2") {
+		t.Fatalf("expected invalid session id error for task-2, got %v", err)
 	}
-}
 
-func TestParallelParseConfig_MissingID(t *testing.T) {
-	input := `---TASK---
----CONTENT---
-do something`
-	if _, err := parseParallelConfig([]byte(input)); err == nil {
-		t.Fatalf("expected error for missing id, got nil")
+	cfg, err := parseParallelConfig([]byte(strings.SplitN(input, "---TASK---\nid: task-2", 2)[0]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Tasks[0].SessionID; got != "ses_123" {
+		t.Fatalf("SessionID = %q, want quotes stripped", got)
 	}
 }
 
-func TestParallelParseConfig_MissingTask(t *testing.T) {
-	input := `---TASK---
-id: task-1
----CONTENT---
-`
-	if _, err := parseParallelConfig([]byte(input)); err == nil {
-		t.Fatalf("expected error for missing task, got nil")
+func TestParseResumeList(t *testing.T) {
+	cfg, err := parseResumeList([]byte("# nightly follow-ups\nses_1\tfix the flaky test\n\n'ses_2'\tadd docs\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Tasks) != 2 {
+		t.Fatalf("tasks = %d, want 2", len(cfg.Tasks))
+	}
+	second := cfg.Tasks[1]
+	if second.ID != "ses_2" || second.SessionID != "ses_2" || second.Mode != "resume" || second.Task != "add docs" || second.WorkDir != defaultWorkdir {
+		t.Fatalf("unexpected task: %+v", second)
 	}
-}
 
-func TestParallelParseConfig_DuplicateID(t *testing.T) {
-	input := `---TASK---
+	cfg, err = parseResumeList([]byte(`[{"session_id": "ses_3", "task": "continue", "workdir": "/repo"}]`))
+	if err != nil {
+		t.Fatalf("unexpected JSON error: %v", err)
+	}
+	if got := cfg.Tasks[0]; got.ID != "ses_3" || got.Mode != "resume" || got.WorkDir != "/repo" {
+		t.Fatalf("unexpected JSON task: %+v", got)
+	}
+
+	for name, input := range map[string]string{
+		"missing tab":   "ses_1 fix it",
+		"bad id":        "ses_1 $(whoami)\tfix it",
+		"empty task":    "ses_1\t  ",
+		"duplicate":     "ses_1\ta\nses_1\tb",
+		"unknown field": `[{"session_id": "ses_1", "task": "a", "agent": "x"}]`,
+		"empty":         "  \n# only comments\n",
+	} {
+		if _, err := parseResumeList([]byte(input)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestParallelParseConfig_CustomDelimiters(t *testing.T) {
+	input := `---DELIMITER: @@@TASK@@@ @@@CONTENT@@@---
+@@@TASK@@@
+id: gen
+@@@CONTENT@@@
+Write a config that uses ---TASK--- and ---CONTENT--- blocks.
+@@@TASK@@@
+id: review
+dependencies: gen
+@@@CONTENT@@@
+Review it.`
+
+	cfg, err := parseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Tasks) != 2 {
+		t.Fatalf("tasks = %d, want 2", len(cfg.Tasks))
+	}
+	if want := "Write a config that uses ---TASK--- and ---CONTENT--- blocks."; cfg.Tasks[0].Task != want {
+		t.Fatalf("task content = %q, want %q", cfg.Tasks[0].Task, want)
+	}
+	if deps := cfg.Tasks[1].Dependencies; len(deps) != 1 || deps[0] != "gen" {
+		t.Fatalf("dependencies = %v, want [gen]", deps)
+	}
+
+	for name, header := range map[string]string{
+		"one token":       "---DELIMITER: @@@TASK@@@---",
+		"same tokens":     "---DELIMITER: @@ @@---",
+		"missing closing": "---DELIMITER: @@T @@C",
+		"empty":           "---DELIMITER:---",
+	} {
+		if _, err := parseParallelConfig([]byte(header + "\n@@T\nid: a\n@@C\ndo")); err == nil || !strings.Contains(err.Error(), "invalid delimiter header") {
+			t.Errorf("%s: expected delimiter header error, got %v", name, err)
+		}
+	}
+}
+
+func TestParallelParseConfig_InvalidFormat(t *testing.T) {
+	if _, err := parseParallelConfig([]byte("invalid format")); err == nil {
+		t.Fatalf("expected error for invalid format, got nil")
+	}
+}
+
+func TestParallelParseConfig_EmptyTasks(t *testing.T) {
+	input := `---TASK---
+id: empty
+---CONTENT---
+`
+	if _, err := parseParallelConfig([]byte(input)); err == nil {
+		t.Fatalf("expected error for empty tasks array, got nil")
+	}
+}
+
+func TestParallelParseConfig_MissingID(t *testing.T) {
+	input := `---TASK---
+---CONTENT---
+do something`
+	if _, err := parseParallelConfig([]byte(input)); err == nil {
+		t.Fatalf("expected error for missing id, got nil")
+	}
+}
+
+func TestParallelParseConfig_MissingTask(t *testing.T) {
+	input := `---TASK---
+id: task-1
+---CONTENT---
+`
+	if _, err := parseParallelConfig([]byte(input)); err == nil {
+		t.Fatalf("expected error for missing task, got nil")
+	}
+}
+
+func TestParallelParseConfig_DuplicateID(t *testing.T) {
+	input := `---TASK---
 id: dup
 ---CONTENT---
 one
@@ -1797,6 +2336,58 @@ two`
 	}
 }
 
+func TestParallelParseConfig_Dependencies(t *testing.T) {
+	input := `---TASK---
+id: a
+---CONTENT---
+one
+---TASK---
+id: b
+dependencies: a, a
+---CONTENT---
+two`
+	cfg, err := parseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() unexpected error: %v", err)
+	}
+	if deps := cfg.Tasks[1].Dependencies; !reflect.DeepEqual(deps, []string{"a"}) {
+		t.Fatalf("Dependencies = %v, want repeated entries collapsed to [a]", deps)
+	}
+
+	input = `---TASK---
+id: a
+dependencies: a
+---CONTENT---
+one`
+	_, err = parseParallelConfig([]byte(input))
+	if err == nil || !strings.Contains(err.Error(), `("a") depends on itself`) {
+		t.Fatalf("error = %v, want a self-dependency error naming the task", err)
+	}
+}
+
+func TestParallelParseConfig_Comments(t *testing.T) {
+	input := `---TASK---
+# backend: claude
+id: a
+  # model: should-not-apply
+model: gpt-test
+#dependencies: b
+---CONTENT---
+# content lines are kept as-is
+do something`
+	cfg, err := parseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() unexpected error: %v", err)
+	}
+	task := cfg.Tasks[0]
+	if task.ID != "a" || task.Model != "gpt-test" || task.Backend != "" || len(task.Dependencies) != 0 {
+		t.Fatalf("task = %+v, want comment lines ignored", task)
+	}
+	if task.Task != "# content lines are kept as-is\ndo something" {
+		t.Fatalf("Task = %q, want content untouched", task.Task)
+	}
+}
+
 func TestParallelParseConfig_DelimiterFormat(t *testing.T) {
 	input := `---TASK---
 id: T1
@@ -1818,6 +2409,61 @@ code with special chars: $var "quotes"`
 	}
 }
 
+func TestParallelParseConfig_JSONFormat(t *testing.T) {
+	t.Run("array", func(t *testing.T) {
+		input := `[
+  {"id": "T1", "task": "echo 'test'", "workdir": "/tmp", "priority": 2},
+  {"id": "T2", "task": "resume it", "dependencies": ["T1"], "session_id": "s-1", "backend": "claude"}
+]`
+		cfg, err := parseParallelConfig([]byte(input))
+		if err != nil {
+			t.Fatalf("parseParallelConfig() error = %v", err)
+		}
+		if len(cfg.Tasks) != 2 {
+			t.Fatalf("expected 2 tasks, got %d", len(cfg.Tasks))
+		}
+		t1, t2 := cfg.Tasks[0], cfg.Tasks[1]
+		if t1.WorkDir != "/tmp" || t1.Priority != 2 || t1.Mode != "new" {
+			t.Fatalf("unexpected T1: %+v", t1)
+		}
+		if t2.WorkDir != defaultWorkdir || t2.Mode != "resume" || t2.Backend != "claude" || len(t2.Dependencies) != 1 || t2.Dependencies[0] != "T1" {
+			t.Fatalf("unexpected T2: %+v", t2)
+		}
+	})
+
+	t.Run("object with global backend", func(t *testing.T) {
+		input := `{"backend": "gemini", "tasks": [{"id": "a", "task": "one"}, {"id": "b", "task": "two", "backend": "codex"}]}`
+		cfg, err := parseParallelConfig([]byte(input))
+		if err != nil {
+			t.Fatalf("parseParallelConfig() error = %v", err)
+		}
+		if cfg.Tasks[0].Backend != "gemini" || cfg.Tasks[1].Backend != "codex" {
+			t.Fatalf("backends = %q, %q; want gemini, codex", cfg.Tasks[0].Backend, cfg.Tasks[1].Backend)
+		}
+	})
+
+	for name, tc := range map[string]struct {
+		input string
+		want  string
+	}{
+		"missing id":      {`[{"task": "x"}]`, "task #1 missing id field"},
+		"missing task":    {`[{"id": "a", "task": "  "}]`, `task #1 ("a") missing task`},
+		"duplicate id":    {`[{"id": "a", "task": "x"}, {"id": "a", "task": "y"}]`, "task #2 has duplicate id: a"},
+		"dash workdir":    {`[{"id": "a", "task": "x", "workdir": "-"}]`, "task #1 has invalid workdir"},
+		"unknown field":   {`[{"id": "a", "task": "x", "depends_on": ["b"]}]`, `unknown field "depends_on"`},
+		"empty array":     {`[]`, "no tasks found"},
+		"malformed":       {`[{"id": "a",`, "invalid JSON parallel config"},
+		"trailing values": {`[{"id": "a", "task": "x"}] []`, "unexpected data after the config"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseParallelConfig([]byte(tc.input))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("parseParallelConfig() err = %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
 func TestClaudeModel_DefaultsFromSettings(t *testing.T) {
 	defer resetTestHooks()
 
@@ -1958,6 +2604,135 @@ func TestRunShouldUseStdin(t *testing.T) {
 	}
 }
 
+func TestNormalizeStdinEOL(t *testing.T) {
+	defer resetTestHooks()
+	input := "fix the parser\r\n```\r\nprintf(\"progress\\r\");\rdone\r\n```\r\n"
+
+	t.Setenv("CODEAGENT_NORMALIZE_EOL", "1")
+	want := "fix the parser\n```\nprintf(\"progress\\r\");\rdone\n```\n"
+	if got := normalizeStdinEOL(input); got != want {
+		t.Fatalf("normalizeStdinEOL() = %q, want %q", got, want)
+	}
+
+	isTerminalFn = func() bool { return false }
+	stdinReader = strings.NewReader(input)
+	got, err := readPipedTask()
+	if err != nil {
+		t.Fatalf("readPipedTask() error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("readPipedTask() = %q, want CRLF converted", got)
+	}
+	if !shouldUseStdin("line one\r\nline two", false) || !shouldUseStdin(normalizeStdinEOL("line one\r\nline two"), false) {
+		t.Fatalf("multi-line task should still go via stdin after normalization")
+	}
+
+	t.Setenv("CODEAGENT_NORMALIZE_EOL", "0")
+	if got := normalizeStdinEOL(input); got != input {
+		t.Fatalf("normalization disabled but text changed: %q", got)
+	}
+}
+
+func TestRunShouldUseStdin_Threshold(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		task string
+		want bool
+	}{
+		{"custom exactly at threshold", "2000", strings.Repeat("a", 2000), false},
+		{"custom one over threshold", "2000", strings.Repeat("a", 2001), true},
+		{"raised threshold keeps 801 on argv", "2000", strings.Repeat("a", 801), false},
+		{"lowered threshold", "100", strings.Repeat("a", 101), true},
+		{"clamped to minimum", "1", strings.Repeat("a", 64), false},
+		{"clamped minimum exceeded", "1", strings.Repeat("a", 65), true},
+		{"invalid falls back to 800", "lots", strings.Repeat("a", 800), false},
+		{"special chars stay unconditional", "2000", "price is $5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CODEAGENT_STDIN_THRESHOLD", tt.env)
+			if got := shouldUseStdin(tt.task, false); got != tt.want {
+				t.Errorf("shouldUseStdin(len=%d) with threshold %q = %v, want %v", len(tt.task), tt.env, got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("CODEAGENT_STDIN_THRESHOLD", "100")
+	if got := stdinReasons(strings.Repeat("a", 101), false, false); !slices.Equal(got, []string{"length>100"}) {
+		t.Errorf("stdinReasons() = %v, want [length>100]", got)
+	}
+}
+
+func TestRun_AgentPromptFile(t *testing.T) {
+	defer resetTestHooks()
+	cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+	config.ResetModelsConfigCacheForTest()
+
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "agents": {
+    "oracle": { "backend": "codex", "model": "gpt-test", "prompt_file": "~/.claude/oracle.md" }
+  }
+}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	selectBackendFn = func(name string) (Backend, error) {
+		return testBackend{
+			name:    name,
+			command: "echo",
+			argsFn: func(cfg *Config, targetArg string) []string {
+				return []string{targetArg}
+			},
+		}, nil
+	}
+	var got TaskSpec
+	runTaskFn = func(task TaskSpec, silent bool, timeout int) TaskResult {
+		got = task
+		return TaskResult{ExitCode: 0, Message: "ok"}
+	}
+	isTerminalFn = func() bool { return true }
+	stdinReader = strings.NewReader("")
+
+	// A missing persona file only warns; the raw task still runs.
+	os.Args = []string{"codeagent-wrapper", "--agent", "oracle", "do"}
+	if code := run(); code != 0 {
+		t.Fatalf("run() with missing agent prompt exit=%d, want 0", code)
+	}
+	if got.Task != "do" || got.UseStdin {
+		t.Fatalf("task = %q (stdin=%t), want the raw task as an argument", got.Task, got.UseStdin)
+	}
+
+	if err := os.MkdirAll(filepath.Join(home, ".claude"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".claude", "oracle.md"), []byte("You are the oracle.\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if code := run(); code != 0 {
+		t.Fatalf("run() exit=%d, want 0", code)
+	}
+	if want := "<agent-prompt>\nYou are the oracle.\n</agent-prompt>\n\ndo"; got.Task != want || !got.UseStdin {
+		t.Fatalf("task = %q (stdin=%t), want %q on stdin", got.Task, got.UseStdin, want)
+	}
+
+	// An explicit --prompt-file must exist.
+	os.Args = []string{"codeagent-wrapper", "--prompt-file", filepath.Join(home, ".claude", "missing.md"), "do"}
+	if code := run(); code != 1 {
+		t.Fatalf("run() with missing --prompt-file exit=%d, want 1", code)
+	}
+}
+
 func TestRun_PromptFilePrefixesTask(t *testing.T) {
 	t.Run("absolute path", func(t *testing.T) {
 		defer resetTestHooks()
@@ -2043,6 +2818,51 @@ func TestRun_PromptFilePrefixesTask(t *testing.T) {
 	})
 }
 
+func TestRun_SystemPromptRouting(t *testing.T) {
+	defer resetTestHooks()
+	cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+
+	selectBackendFn = func(name string) (Backend, error) {
+		return testBackend{
+			name:    name,
+			command: "echo",
+			argsFn: func(cfg *Config, targetArg string) []string {
+				return []string{targetArg}
+			},
+		}, nil
+	}
+	var got TaskSpec
+	runTaskFn = func(task TaskSpec, silent bool, timeout int) TaskResult {
+		got = task
+		return TaskResult{ExitCode: 0, Message: "ok"}
+	}
+	isTerminalFn = func() bool { return true }
+	stdinReader = strings.NewReader("")
+
+	promptPath := filepath.Join(t.TempDir(), "persona.md")
+	if err := os.WriteFile(promptPath, []byte("P\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// Claude gets the persona and --system-prompt as a system prompt.
+	os.Args = []string{"codeagent-wrapper", "--backend", "claude", "--prompt-file", promptPath, "--system-prompt", "Be terse.", "do"}
+	if code := run(); code != 0 {
+		t.Fatalf("run() exit=%d, want 0", code)
+	}
+	if got.Task != "do" || got.SystemPrompt != "P\n\nBe terse." {
+		t.Fatalf("claude task=%q system=%q, want the raw task and the joined system prompt", got.Task, got.SystemPrompt)
+	}
+
+	// Other backends keep the task preamble.
+	os.Args = []string{"codeagent-wrapper", "--backend", "codex", "--system-prompt", "Be terse.", "do"}
+	if code := run(); code != 0 {
+		t.Fatalf("run() exit=%d, want 0", code)
+	}
+	if want := "<agent-prompt>\nBe terse.\n</agent-prompt>\n\ndo"; got.Task != want || got.SystemPrompt != "" || !got.UseStdin {
+		t.Fatalf("codex task=%q system=%q stdin=%t, want %q on stdin", got.Task, got.SystemPrompt, got.UseStdin, want)
+	}
+}
+
 func TestRun_PassesReasoningEffortToTaskSpec(t *testing.T) {
 	defer resetTestHooks()
 	cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
@@ -2427,32 +3247,56 @@ func TestBackendNamesAndCommands(t *testing.T) {
 
 func TestRunResolveTimeout(t *testing.T) {
 	tests := []struct {
-		name   string
-		envVal string
-		want   int
+		name       string
+		envVal     string
+		flag       int
+		want       int
+		wantSource string
 	}{
-		{"empty env", "", 7200},
-		{"milliseconds", "7200000", 7200},
-		{"seconds", "3600", 3600},
-		{"invalid", "invalid", 7200},
-		{"negative", "-100", 7200},
-		{"zero", "0", 7200},
-		{"small milliseconds", "5000", 5000},
-		{"boundary", "10000", 10000},
-		{"above boundary", "10001", 10},
+		{"empty env", "", 0, 7200, "default"},
+		{"milliseconds", "7200000", 0, 7200, "CODEX_TIMEOUT"},
+		{"seconds", "3600", 0, 3600, "CODEX_TIMEOUT"},
+		{"invalid", "invalid", 0, 7200, "default"},
+		{"negative", "-100", 0, 7200, "default"},
+		{"zero", "0", 0, 7200, "default"},
+		{"small milliseconds", "5000", 0, 5000, "CODEX_TIMEOUT"},
+		{"boundary", "10000", 0, 10000, "CODEX_TIMEOUT"},
+		{"above boundary", "10001", 0, 10, "CODEX_TIMEOUT"},
+		{"flag overrides env", "3600", 60, 60, "--timeout"},
+		{"flag milliseconds", "", 90000, 90, "--timeout"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Setenv("CODEX_TIMEOUT", tt.envVal)
-			got := resolveTimeout()
-			if got != tt.want {
-				t.Errorf("resolveTimeout() with env=%q = %v, want %v", tt.envVal, got, tt.want)
+			got, source := resolveTimeoutSource(tt.flag)
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("resolveTimeoutSource(%d) with env=%q = %v, %q; want %v, %q", tt.flag, tt.envVal, got, source, tt.want, tt.wantSource)
 			}
 		})
 	}
 }
 
+func TestResolveRunTimeout(t *testing.T) {
+	tests := []struct {
+		envVal string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"900", 15 * time.Minute},
+		{"60000", time.Minute},
+		{"-5", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("CODEAGENT_RUN_TIMEOUT", tt.envVal)
+		if got := resolveRunTimeout(); got != tt.want {
+			t.Errorf("resolveRunTimeout() with %q = %v, want %v", tt.envVal, got, tt.want)
+		}
+	}
+}
+
 func TestRunNormalizeText(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -3054,31 +3898,110 @@ func TestRunCodexTask_CommandNotFound(t *testing.T) {
 	}
 }
 
-func TestRunCodexTask_StartError(t *testing.T) {
+func TestRunCodexTask_BackendBinaryMissing(t *testing.T) {
 	defer resetTestHooks()
-	tmpFile, err := os.CreateTemp("", "start-error")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
-	}
-	defer os.Remove(tmpFile.Name())
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+	t.Setenv("PATH", t.TempDir())
 
-	codexCommand = tmpFile.Name()
-	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+	codexCommand = "codex"
+	t.Setenv("CODEX_BIN", "")
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 10)
+	if res.ExitCode != 127 || res.Error != `backend "codex" not found in PATH; install it or set CODEX_BIN` {
+		t.Fatalf("missing codex = %d %q", res.ExitCode, res.Error)
+	}
+	if res.SpawnDuration != 0 {
+		t.Fatalf("backend should not have been started, spawn took %v", res.SpawnDuration)
+	}
 
-	res := runCodexTask(TaskSpec{Task: "task"}, false, 1)
-	if res.ExitCode != 1 || !strings.Contains(res.Error, "failed to start") {
-		t.Fatalf("unexpected result: %+v", res)
+	missing := filepath.Join(t.TempDir(), "codex-9")
+	t.Setenv("CODEX_BIN", missing)
+	res = runCodexTask(TaskSpec{Task: "task"}, true, 10)
+	want := fmt.Sprintf("backend %q binary %q (from CODEX_BIN) not found", "codex", missing)
+	if res.ExitCode != 127 || res.Error != want {
+		t.Fatalf("missing CODEX_BIN = %d %q, want 127 %q", res.ExitCode, res.Error, want)
 	}
 }
 
-func TestRunCodexTask_WithEcho(t *testing.T) {
+func TestRunCodexTask_MissingWorkdir(t *testing.T) {
 	defer resetTestHooks()
-	codexCommand = createFakeCodexScript(t, "test-session", "Test output")
-	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+	codexCommand = "echo"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string {
+		return []string{`{"type":"item.completed","item":{"type":"agent_message","text":"ran"}}`}
+	}
+	missing := filepath.Join(t.TempDir(), "does", "not", "exist")
 
-	res := runCodexTask(TaskSpec{Task: "ignored"}, false, 10)
-	if res.ExitCode != 0 || res.Message != "Test output" || res.SessionID != "test-session" {
-		t.Fatalf("unexpected result: %+v", res)
+	res := runCodexTask(TaskSpec{Task: "task", WorkDir: missing}, false, 10)
+	if res.ExitCode != 66 {
+		t.Fatalf("exitCode = %d, want 66 (%+v)", res.ExitCode, res)
+	}
+	if want := fmt.Sprintf("workdir %q does not exist", missing); !strings.Contains(res.Error, want) {
+		t.Fatalf("error = %q, want it to contain %q", res.Error, want)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Fatalf("workdir should not be created without CODEAGENT_MKDIR_WORKDIR, stat err = %v", err)
+	}
+
+	t.Setenv("CODEAGENT_MKDIR_WORKDIR", "1")
+	res = runCodexTask(TaskSpec{Task: "task", WorkDir: missing}, false, 10)
+	if res.ExitCode != 0 || res.Message != "ran" {
+		t.Fatalf("unexpected result with CODEAGENT_MKDIR_WORKDIR=1: %+v", res)
+	}
+	if info, err := os.Stat(missing); err != nil || !info.IsDir() {
+		t.Fatalf("workdir was not created: %v", err)
+	}
+}
+
+func TestRunCodexTask_ReportsUsage(t *testing.T) {
+	defer resetTestHooks()
+	codexCommand = "echo"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string {
+		return []string{`{"type":"result","subtype":"success","session_id":"s1","result":"ok","usage":{"input_tokens":10,"cache_read_input_tokens":90,"output_tokens":5}}`}
+	}
+
+	res := runCodexTask(TaskSpec{Task: "task"}, false, 10)
+	if res.ExitCode != 0 || res.Message != "ok" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Tokens != 105 || res.InputTokens != 100 || res.OutputTokens != 5 {
+		t.Fatalf("usage = %d (%d in / %d out), want 105 (100 in / 5 out)", res.Tokens, res.InputTokens, res.OutputTokens)
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, key := range []string{`"tokens":105`, `"input_tokens":100`, `"output_tokens":5`} {
+		if !strings.Contains(string(data), key) {
+			t.Fatalf("JSON result missing %s: %s", key, data)
+		}
+	}
+}
+
+func TestRunCodexTask_StartError(t *testing.T) {
+	defer resetTestHooks()
+	tmpFile, err := os.CreateTemp("", "start-error")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	codexCommand = tmpFile.Name()
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+
+	res := runCodexTask(TaskSpec{Task: "task"}, false, 1)
+	if res.ExitCode != 1 || !strings.Contains(res.Error, "failed to start") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRunCodexTask_WithEcho(t *testing.T) {
+	defer resetTestHooks()
+	codexCommand = createFakeCodexScript(t, "test-session", "Test output")
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+
+	res := runCodexTask(TaskSpec{Task: "ignored"}, false, 10)
+	if res.ExitCode != 0 || res.Message != "Test output" || res.SessionID != "test-session" {
+		t.Fatalf("unexpected result: %+v", res)
 	}
 }
 
@@ -3459,6 +4382,53 @@ func TestRunGenerateFinalOutput_LogPath(t *testing.T) {
 	}
 }
 
+func TestRunGenerateFinalOutput_Usage(t *testing.T) {
+	results := []TaskResult{
+		{TaskID: "a", ExitCode: 0, Message: "ok", DurationMs: 83400, Tokens: 10300, InputTokens: 9100, OutputTokens: 1200},
+		{TaskID: "b", ExitCode: 1, Error: "boom", DurationMs: 1500, Tokens: 700, InputTokens: 600, OutputTokens: 100},
+		{TaskID: "c", ExitCode: 0, Message: "ok"},
+	}
+	out := generateFinalOutput(results)
+	for _, want := range []string{
+		"Usage: 1m23.4s, 10.3k tokens (9.1k in / 1.2k out)",
+		"Usage: 1.5s, 700 tokens (600 in / 100 out)",
+		"- Tokens: 11.0k",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("summary output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "Usage:") != 2 {
+		t.Fatalf("tasks without usage should not get a Usage line:\n%s", out)
+	}
+	if out := generateFinalOutputWithMode(results, false); !strings.Contains(out, "Usage: 1m23.4s, 10.3k tokens") {
+		t.Fatalf("full output missing usage line:\n%s", out)
+	}
+}
+
+func TestRunGenerateFinalOutput_ChangedFiles(t *testing.T) {
+	results := []TaskResult{
+		{
+			TaskID:       "a",
+			Message:      "ok",
+			FilesChanged: []string{"mentioned.go"},
+			ChangedFiles: []executor.FileChange{{Path: "a.go", Kind: "add"}, {Path: "b.go", Kind: "update"}, {Path: "c.go"}},
+		},
+		{TaskID: "b", Message: "ok", FilesChanged: []string{"mentioned.go"}},
+	}
+	out := generateFinalOutput(results)
+	if !strings.Contains(out, "Files: a.go (add), b.go (update), c.go\n") {
+		t.Fatalf("summary should list the reported file changes:\n%s", out)
+	}
+	if !strings.Contains(out, "Files: mentioned.go\n") {
+		t.Fatalf("summary should fall back to files mentioned in the message:\n%s", out)
+	}
+	out = generateFinalOutputWithMode(results, false)
+	if strings.Count(out, "Files: ") != 1 || !strings.Contains(out, "Files: a.go (add), b.go (update), c.go\n") {
+		t.Fatalf("full output should list reported file changes only:\n%s", out)
+	}
+}
+
 func TestRunTopologicalSort_LinearChain(t *testing.T) {
 	tasks := []TaskSpec{{ID: "a"}, {ID: "b", Dependencies: []string{"a"}}, {ID: "c", Dependencies: []string{"b"}}}
 	layers, err := topologicalSort(tasks)
@@ -3494,15 +4464,35 @@ func TestParallelTopologicalSortTasks(t *testing.T) {
 
 func TestRunTopologicalSort_CycleDetection(t *testing.T) {
 	tasks := []TaskSpec{{ID: "a", Dependencies: []string{"b"}}, {ID: "b", Dependencies: []string{"a"}}}
-	if _, err := topologicalSort(tasks); err == nil || !strings.Contains(err.Error(), "cycle detected") {
-		t.Fatalf("expected cycle error, got %v", err)
+	if _, err := topologicalSort(tasks); err == nil || err.Error() != "cycle detected: a -> b -> a" {
+		t.Fatalf("expected cycle error naming a -> b -> a, got %v", err)
 	}
 }
 
 func TestRunTopologicalSort_IndirectCycle(t *testing.T) {
 	tasks := []TaskSpec{{ID: "a", Dependencies: []string{"c"}}, {ID: "b", Dependencies: []string{"a"}}, {ID: "c", Dependencies: []string{"b"}}}
-	if _, err := topologicalSort(tasks); err == nil || !strings.Contains(err.Error(), "cycle detected") {
-		t.Fatalf("expected cycle error, got %v", err)
+	if _, err := topologicalSort(tasks); err == nil || err.Error() != "cycle detected: a -> c -> b -> a" {
+		t.Fatalf("expected cycle error naming a -> c -> b -> a, got %v", err)
+	}
+}
+
+func TestRunTopologicalSort_CycleChainSkipsBlockedTasks(t *testing.T) {
+	// "report" and "deploy" are only blocked by the cycle; "lint" sorts fine.
+	tasks := []TaskSpec{
+		{ID: "report", Dependencies: []string{"deploy"}},
+		{ID: "lint"},
+		{ID: "deploy", Dependencies: []string{"lint", "test"}},
+		{ID: "build", Dependencies: []string{"test"}},
+		{ID: "test", Dependencies: []string{"build"}},
+		{ID: "self", Dependencies: []string{"self"}},
+	}
+	_, err := topologicalSort(tasks)
+	if err == nil || err.Error() != "cycle detected: build -> test -> build" {
+		t.Fatalf("expected cycle error naming build -> test -> build, got %v", err)
+	}
+
+	if _, err := topologicalSort([]TaskSpec{{ID: "self", Dependencies: []string{"self"}}}); err == nil || err.Error() != "cycle detected: self -> self" {
+		t.Fatalf("expected self-dependency cycle, got %v", err)
 	}
 }
 
@@ -3809,7 +4799,8 @@ func TestRunSingleWithOutputFile(t *testing.T) {
 	defer resetTestHooks()
 
 	tempDir := t.TempDir()
-	outputPath := filepath.Join(tempDir, "single-output.json")
+	// Missing parent directories are created.
+	outputPath := filepath.Join(tempDir, "nested", "results", "single-output.json")
 
 	oldArgs := os.Args
 	t.Cleanup(func() { os.Args = oldArgs })
@@ -3862,6 +4853,9 @@ func TestRunSingleWithOutputFile(t *testing.T) {
 	if payload.Results[0].Message != "single-result" {
 		t.Fatalf("result message = %q, want %q", payload.Results[0].Message, "single-result")
 	}
+	if payload.Results[0].SessionID != "sid-single" || payload.Results[0].ExitCode != 0 {
+		t.Fatalf("result session/exit = %q/%d, want sid-single/0", payload.Results[0].SessionID, payload.Results[0].ExitCode)
+	}
 }
 
 func TestRunSingleWithOutputFileOnFailureExitCode(t *testing.T) {
@@ -4044,6 +5038,348 @@ noop`)
 	}
 }
 
+func TestRunWithStatusFile(t *testing.T) {
+	readStatus := func(t *testing.T, path string) statusPayload {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read status file: %v", err)
+		}
+		var payload statusPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("failed to unmarshal status file %q: %v", string(data), err)
+		}
+		return payload
+	}
+
+	runSingle := func(t *testing.T, statusPath string, exitCode int) int {
+		t.Helper()
+		oldArgs := os.Args
+		t.Cleanup(func() { os.Args = oldArgs })
+		os.Args = []string{"codeagent-wrapper", "--status-file", statusPath, "task"}
+
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+
+		origRunTaskFn := runTaskFn
+		runTaskFn = func(taskSpec TaskSpec, silent bool, timeoutSec int) TaskResult {
+			return TaskResult{TaskID: "single-task", ExitCode: exitCode, Message: "done", SessionID: "sid"}
+		}
+		t.Cleanup(func() { runTaskFn = origRunTaskFn })
+		return run()
+	}
+
+	t.Run("success", func(t *testing.T) {
+		defer resetTestHooks()
+		statusPath := filepath.Join(t.TempDir(), "ci", "status.json")
+
+		if code := runSingle(t, statusPath, 0); code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		if got := readStatus(t, statusPath); got != (statusPayload{OK: true, Total: 1, Failed: 0}) {
+			t.Fatalf("status = %+v", got)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		defer resetTestHooks()
+		statusPath := filepath.Join(t.TempDir(), "status.json")
+
+		if code := runSingle(t, statusPath, 7); code != 7 {
+			t.Fatalf("run exit = %d, want 7", code)
+		}
+		if got := readStatus(t, statusPath); got != (statusPayload{OK: false, Total: 1, Failed: 1}) {
+			t.Fatalf("status = %+v", got)
+		}
+	})
+
+	t.Run("replaces existing file atomically", func(t *testing.T) {
+		defer resetTestHooks()
+		dir := t.TempDir()
+		statusPath := filepath.Join(dir, "status.json")
+		if err := os.WriteFile(statusPath, []byte("stale"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		before, err := os.Stat(statusPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code := runSingle(t, statusPath, 0); code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		after, err := os.Stat(statusPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if os.SameFile(before, after) {
+			t.Fatalf("status file was rewritten in place, want rename over the old file")
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("temporary files left behind: %v", entries)
+		}
+		if got := readStatus(t, statusPath); !got.OK {
+			t.Fatalf("status = %+v, want ok", got)
+		}
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		defer resetTestHooks()
+		cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+		statusPath := filepath.Join(t.TempDir(), "status.json")
+
+		oldArgs := os.Args
+		t.Cleanup(func() { os.Args = oldArgs })
+		os.Args = []string{"codeagent-wrapper", "--parallel", "--status-file", statusPath}
+
+		stdinReader = strings.NewReader(`---TASK---
+id: ok
+---CONTENT---
+noop
+---TASK---
+id: bad
+---CONTENT---
+noop`)
+		t.Cleanup(func() { stdinReader = os.Stdin })
+
+		origRunCodexTaskFn := runCodexTaskFn
+		runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+			if task.ID == "bad" {
+				return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+			}
+			return TaskResult{TaskID: task.ID, ExitCode: 0, Message: "ok"}
+		}
+		t.Cleanup(func() { runCodexTaskFn = origRunCodexTaskFn })
+
+		_ = captureOutput(t, func() {
+			if code := run(); code == 0 {
+				t.Fatalf("run exit = 0, want failure")
+			}
+		})
+		if got := readStatus(t, statusPath); got != (statusPayload{OK: false, Total: 2, Failed: 1}) {
+			t.Fatalf("status = %+v", got)
+		}
+	})
+}
+
+func TestRunParallelMaxParallel(t *testing.T) {
+	runWithCap := func(t *testing.T, args ...string) (int, int32) {
+		t.Helper()
+		defer resetTestHooks()
+		cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+
+		var config strings.Builder
+		for i := 0; i < 6; i++ {
+			fmt.Fprintf(&config, "---TASK---\nid: T%d\n---CONTENT---\nnoop\n", i)
+		}
+		stdinReader = strings.NewReader(config.String())
+		var active, peak int32
+		runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+			n := atomic.AddInt32(&active, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			return TaskResult{TaskID: task.ID, Message: "done"}
+		}
+		os.Args = append([]string{"codeagent-wrapper", "--parallel"}, args...)
+		var code int
+		_ = captureOutput(t, func() { code = run() })
+		return code, atomic.LoadInt32(&peak)
+	}
+
+	t.Run("flag", func(t *testing.T) {
+		if code, peak := runWithCap(t, "--max-parallel", "2"); code != 0 || peak > 2 {
+			t.Fatalf("exit = %d, peak concurrency = %d; want 0 and <= 2", code, peak)
+		}
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("CODEAGENT_MAX_PARALLEL", "1")
+		if code, peak := runWithCap(t); code != 0 || peak != 1 {
+			t.Fatalf("exit = %d, peak concurrency = %d; want 0 and 1", code, peak)
+		}
+	})
+
+	t.Run("unset stays unbounded", func(t *testing.T) {
+		if code, peak := runWithCap(t); code != 0 || peak < 2 {
+			t.Fatalf("exit = %d, peak concurrency = %d; want 0 and > 1", code, peak)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		var code int
+		errOut := captureStderr(t, func() { code, _ = runWithCap(t, "--max-parallel", "-1") })
+		if code != 1 || !strings.Contains(errOut, "--max-parallel must be >= 0") {
+			t.Fatalf("exit = %d, stderr = %q; want 1 and a --max-parallel error", code, errOut)
+		}
+	})
+}
+
+func TestRunParallelJSONOutput(t *testing.T) {
+	defer resetTestHooks()
+	cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--json"}
+
+	stdinReader = strings.NewReader(`---TASK---
+id: T1
+---CONTENT---
+noop
+---TASK---
+id: T2
+---CONTENT---
+noop`)
+	t.Cleanup(func() { stdinReader = os.Stdin })
+
+	origRunCodexTaskFn := runCodexTaskFn
+	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+		if task.ID == "T2" {
+			return TaskResult{TaskID: task.ID, ExitCode: 2, Error: "boom"}
+		}
+		return TaskResult{TaskID: task.ID, ExitCode: 0, Message: "done", SessionID: "sid-1"}
+	}
+	t.Cleanup(func() { runCodexTaskFn = origRunCodexTaskFn })
+
+	var code int
+	out := captureOutput(t, func() { code = run() })
+	if code != 2 {
+		t.Fatalf("run exit = %d, want 2", code)
+	}
+	if strings.Contains(out, "=== Execution Report ===") {
+		t.Fatalf("--json should replace the text report, got %q", out)
+	}
+
+	var payload struct {
+		Results []map[string]any `json:"results"`
+		Summary struct {
+			Total   int `json:"total"`
+			Success int `json:"success"`
+			Failed  int `json:"failed"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("stdout is not a single JSON document: %v\n%s", err, out)
+	}
+	if payload.Summary.Total != 2 || payload.Summary.Success != 1 || payload.Summary.Failed != 1 {
+		t.Fatalf("unexpected summary: %+v", payload.Summary)
+	}
+	if len(payload.Results) != 2 {
+		t.Fatalf("results length = %d, want 2", len(payload.Results))
+	}
+	for _, key := range []string{"task_id", "exit_code", "message", "session_id", "error"} {
+		if _, ok := payload.Results[0][key]; !ok {
+			t.Fatalf("result missing %q field: %v", key, payload.Results[0])
+		}
+	}
+	byID := map[string]map[string]any{}
+	for _, r := range payload.Results {
+		byID[r["task_id"].(string)] = r
+	}
+	if byID["T1"]["session_id"] != "sid-1" || byID["T2"]["error"] != "boom" || byID["T2"]["exit_code"] != float64(2) {
+		t.Fatalf("unexpected results: %v", payload.Results)
+	}
+}
+
+func TestRunSingleRejectsJSONFlag(t *testing.T) {
+	defer resetTestHooks()
+	os.Args = []string{"codeagent-wrapper", "--json", "task"}
+	if _, err := parseArgs(); err == nil || !strings.Contains(err.Error(), "--parallel") {
+		t.Fatalf("parseArgs() error = %v, want --json parallel-only error", err)
+	}
+}
+
+func TestRunMaxMessageLines(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 50; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	longMessage := strings.Join(lines, "\n")
+
+	readOutput := func(t *testing.T, path string) []TaskResult {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read output file: %v", err)
+		}
+		var payload outputPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("failed to unmarshal output json: %v", err)
+		}
+		return payload.Results
+	}
+
+	t.Run("single", func(t *testing.T) {
+		defer resetTestHooks()
+		outputPath := filepath.Join(t.TempDir(), "out.json")
+		oldArgs := os.Args
+		t.Cleanup(func() { os.Args = oldArgs })
+		os.Args = []string{"codeagent-wrapper", "--max-message-lines", "3", "--output", outputPath, "task"}
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+		runTaskFn = func(taskSpec TaskSpec, silent bool, timeoutSec int) TaskResult {
+			return TaskResult{TaskID: "t", Message: longMessage, SessionID: "sid"}
+		}
+
+		var code int
+		out := captureOutput(t, func() { code = run() })
+		if code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		if !strings.Contains(out, "line 1\nline 2\nline 3\n... (47 more lines, see "+outputPath+")") {
+			t.Fatalf("stdout not capped as expected: %q", out)
+		}
+		if strings.Contains(out, "line 4\n") {
+			t.Fatalf("stdout should stop after 3 lines: %q", out)
+		}
+		if !strings.Contains(out, "SESSION_ID: sid") {
+			t.Fatalf("session trailer missing: %q", out)
+		}
+		if results := readOutput(t, outputPath); len(results) != 1 || results[0].Message != longMessage {
+			t.Fatalf("output file should keep the full message, got %+v", results)
+		}
+	})
+
+	t.Run("parallel full output", func(t *testing.T) {
+		defer resetTestHooks()
+		cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
+		outputPath := filepath.Join(t.TempDir(), "out.json")
+		oldArgs := os.Args
+		t.Cleanup(func() { os.Args = oldArgs })
+		os.Args = []string{"codeagent-wrapper", "--parallel", "--full-output", "--max-message-lines", "2", "--output", outputPath}
+		stdinReader = strings.NewReader("---TASK---\nid: T1\n---CONTENT---\nnoop")
+		t.Cleanup(func() { stdinReader = os.Stdin })
+
+		origRunCodexTaskFn := runCodexTaskFn
+		runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+			return TaskResult{TaskID: task.ID, Message: longMessage}
+		}
+		t.Cleanup(func() { runCodexTaskFn = origRunCodexTaskFn })
+
+		var code int
+		out := captureOutput(t, func() { code = run() })
+		if code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		if !strings.Contains(out, "line 2\n... (48 more lines, see "+outputPath+")") || strings.Contains(out, "line 3\n") {
+			t.Fatalf("full-output message not capped: %q", out)
+		}
+		if results := readOutput(t, outputPath); len(results) != 1 || results[0].Message != longMessage {
+			t.Fatalf("output file should keep the full message, got %+v", results)
+		}
+	})
+}
+
 func TestParallelInvalidBackend(t *testing.T) {
 	defer resetTestHooks()
 	cleanupLogsFn = func() (CleanupStats, error) { return CleanupStats{}, nil }
@@ -4104,6 +5440,34 @@ func TestVersionFlag(t *testing.T) {
 	}
 }
 
+func TestVersionFlag_JSON(t *testing.T) {
+	defer resetTestHooks()
+	for _, args := range [][]string{{"--version", "--json"}, {"version", "--json"}} {
+		os.Args = append([]string{"codeagent-wrapper"}, args...)
+		output := captureOutput(t, func() {
+			if code := run(); code != 0 {
+				t.Errorf("%v: exit = %d, want 0", args, code)
+			}
+		})
+
+		var info struct {
+			Name     string   `json:"name"`
+			Version  string   `json:"version"`
+			Go       string   `json:"go"`
+			Backends []string `json:"backends"`
+		}
+		if err := json.Unmarshal([]byte(output), &info); err != nil {
+			t.Fatalf("%v: output %q is not JSON: %v", args, output, err)
+		}
+		if info.Name != "codeagent-wrapper" || info.Version != version || info.Go != runtime.Version() {
+			t.Fatalf("%v: info = %+v", args, info)
+		}
+		if want := []string{"claude", "codex", "gemini", "opencode"}; !reflect.DeepEqual(info.Backends, want) {
+			t.Fatalf("%v: backends = %v, want %v", args, info.Backends, want)
+		}
+	}
+}
+
 func TestVersionShortFlag(t *testing.T) {
 	defer resetTestHooks()
 	os.Args = []string{"codeagent-wrapper", "-v"}
@@ -4132,15 +5496,48 @@ func TestVersionLegacyAlias(t *testing.T) {
 	}
 }
 
-func TestRun_Help(t *testing.T) {
+func TestListBackendsFlag(t *testing.T) {
 	defer resetTestHooks()
-	os.Args = []string{"codeagent-wrapper", "--help"}
-	if code := run(); code != 0 {
-		t.Errorf("exit = %d, want 0", code)
+	t.Setenv("CODEX_BIN", "/opt/codex-1.2/bin/codex")
+	os.Args = []string{"codeagent-wrapper", "--list-backends"}
+	cleanupLogsFn = func() (CleanupStats, error) {
+		t.Fatalf("cleanup should not run for --list-backends")
+		return CleanupStats{}, nil
 	}
-}
 
-func TestRun_HelpShort(t *testing.T) {
+	output := captureOutput(t, func() {
+		if code := run(); code != 0 {
+			t.Errorf("exit = %d, want 0", code)
+		}
+	})
+	if activeLogger() != nil {
+		t.Fatalf("--list-backends should not initialize the logger")
+	}
+	for _, name := range []string{"claude", "codex", "gemini", "opencode"} {
+		if !strings.Contains(output, name+"\n  Command: ") {
+			t.Errorf("output missing backend %s: %q", name, output)
+		}
+	}
+	for _, want := range []string{
+		"Command: codex -> /opt/codex-1.2/bin/codex\n",
+		"Args: run --format json <task>\n",
+		`--setting-sources ""`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q: %q", want, output)
+		}
+	}
+}
+
+func TestRun_Help(t *testing.T) {
+	defer resetTestHooks()
+	os.Args = []string{"codeagent-wrapper", "--help"}
+	if code := run(); code != 0 {
+		t.Errorf("exit = %d, want 0", code)
+	}
+}
+
+func TestRun_HelpShort(t *testing.T) {
 	defer resetTestHooks()
 	os.Args = []string{"codeagent-wrapper", "-h"}
 	if code := run(); code != 0 {
@@ -4592,6 +5989,77 @@ func TestRun_CleanupFlag(t *testing.T) {
 	}
 }
 
+func TestRun_CleanupFlagWithMaxLogs(t *testing.T) {
+	defer resetTestHooks()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"codeagent-wrapper", "--cleanup", "--max-logs", "2"}
+
+	cleanupLogsFn = func() (CleanupStats, error) {
+		return CleanupStats{
+			Scanned:      4,
+			Deleted:      1,
+			Kept:         3,
+			DeletedFiles: []string{"codeagent-wrapper-111.log"},
+			KeptFiles:    []string{"codeagent-wrapper-222.log", "codeagent-wrapper-333.log", "codeagent-wrapper-444.log"},
+		}, nil
+	}
+	gotLimit := 0
+	trimLogsFn = func(maxLogs int) (CleanupStats, error) {
+		gotLimit = maxLogs
+		return CleanupStats{
+			Scanned:      3,
+			Deleted:      1,
+			Kept:         2,
+			DeletedFiles: []string{"codeagent-wrapper-222.log"},
+			KeptFiles:    []string{"codeagent-wrapper-333.log", "codeagent-wrapper-444.log"},
+		}, nil
+	}
+
+	var exitCode int
+	output := captureOutput(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 0 {
+		t.Fatalf("exit = %d, want 0", exitCode)
+	}
+	if gotLimit != 2 {
+		t.Fatalf("trim limit = %d, want 2", gotLimit)
+	}
+	want := "Cleanup completed\nFiles scanned: 4\nFiles deleted: 2\n  - codeagent-wrapper-111.log\n  - codeagent-wrapper-222.log\nFiles kept: 2\n  - codeagent-wrapper-333.log\n  - codeagent-wrapper-444.log\n"
+	if output != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+}
+
+func TestRun_MaxLogsRejectsNegative(t *testing.T) {
+	defer resetTestHooks()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"codeagent-wrapper", "--cleanup", "--max-logs", "-1"}
+	calls := 0
+	cleanupLogsFn = func() (CleanupStats, error) {
+		calls++
+		return CleanupStats{}, nil
+	}
+
+	var exitCode int
+	errOutput := captureStderr(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 1 {
+		t.Fatalf("exit = %d, want 1", exitCode)
+	}
+	if calls != 0 {
+		t.Fatalf("cleanup called %d times, want 0", calls)
+	}
+	if !strings.Contains(errOutput, "--max-logs") {
+		t.Fatalf("stderr = %q, want --max-logs error", errOutput)
+	}
+}
+
 func TestRun_NoArgs(t *testing.T) {
 	defer resetTestHooks()
 	os.Args = []string{"codeagent-wrapper"}
@@ -5242,3 +6710,2007 @@ func TestResolveMaxParallelWorkers(t *testing.T) {
 		})
 	}
 }
+
+func TestRun_JSONStreamPassthrough(t *testing.T) {
+	defer resetTestHooks()
+	stdout := captureStdoutPipe()
+
+	restore := withBackend(createFakeCodexScript(t, "tid-raw", "raw-ok"), buildCodexArgs)
+	defer restore()
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	os.Args = []string{"codeagent-wrapper", "--json-stream-passthrough", "task"}
+
+	exitCode := run()
+	restoreStdoutPipe(stdout)
+	if exitCode != 0 {
+		t.Fatalf("exit=%d, want 0", exitCode)
+	}
+
+	output := stdout.String()
+	wantLines := []string{
+		`{"type":"thread.started","thread_id":"tid-raw"}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"raw-ok"}}`,
+	}
+	for _, line := range wantLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Fatalf("stdout missing raw line %q, got %q", line, output)
+		}
+	}
+	if strings.Contains(output, "SESSION_ID:") {
+		t.Fatalf("passthrough stdout should not include the human trailer, got %q", output)
+	}
+}
+
+type slowLineWriter struct {
+	mu    sync.Mutex
+	delay time.Duration
+	lines []string
+}
+
+func (w *slowLineWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	w.lines = append(w.lines, string(p))
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func TestRunCodexTask_StreamPassthroughSlowConsumer(t *testing.T) {
+	defer resetTestHooks()
+
+	const events = 60
+	slow := &slowLineWriter{delay: 25 * time.Millisecond}
+	t.Cleanup(executor.SetStreamPassthroughOutput(slow, 4))
+
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		plan := []fakeStdoutEvent{{Data: `{"type":"thread.started","thread_id":"slow-thread"}` + "\n"}}
+		for i := 0; i < events; i++ {
+			plan = append(plan, fakeStdoutEvent{Data: fmt.Sprintf(`{"type":"item.completed","item":{"type":"reasoning","text":"step %d"}}`, i) + "\n"})
+		}
+		plan = append(plan, fakeStdoutEvent{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"final answer"}}` + "\n"})
+		return newFakeCmd(fakeCmdConfig{StdoutPlan: plan})
+	})
+	codexCommand = "fake-cmd"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	start := time.Now()
+	res := runCodexTask(TaskSpec{Task: "task", StreamPassthrough: true}, true, 5)
+	elapsed := time.Since(start)
+
+	if res.ExitCode != 0 || res.Message != "final answer" || res.SessionID != "slow-thread" {
+		t.Fatalf("result = %+v, want final answer from slow-thread", res)
+	}
+	// Writing every line synchronously would take events*delay (1.5s).
+	if elapsed > time.Second {
+		t.Fatalf("run took %s; slow stream consumer stalled the parser", elapsed)
+	}
+
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if len(slow.lines) == 0 || len(slow.lines) > events+2 {
+		t.Fatalf("consumer received %d lines", len(slow.lines))
+	}
+	for _, line := range slow.lines {
+		if !strings.HasSuffix(line, "\n") || !json.Valid([]byte(strings.TrimSpace(line))) {
+			t.Fatalf("consumer received a partial line %q", line)
+		}
+	}
+}
+
+func TestParallelRejectsJSONStreamPassthrough(t *testing.T) {
+	defer resetTestHooks()
+	os.Args = []string{"codeagent-wrapper", "--parallel", "--json-stream-passthrough"}
+	stdinReader = strings.NewReader("---TASK---\nid: a\n---CONTENT---\nx")
+	if code := run(); code == 0 {
+		t.Fatalf("expected non-zero exit for --json-stream-passthrough in parallel mode")
+	}
+}
+
+func TestRun_ProfileReportsPhases(t *testing.T) {
+	defer resetTestHooks()
+	stdout := captureStdoutPipe()
+
+	restore := withBackend(createFakeCodexScript(t, "tid-profile", "profile-ok"), buildCodexArgs)
+	defer restore()
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	os.Args = []string{"codeagent-wrapper", "--profile", "task"}
+
+	var exitCode int
+	errOutput := captureStderr(t, func() {
+		exitCode = run()
+	})
+	restoreStdoutPipe(stdout)
+	if exitCode != 0 {
+		t.Fatalf("exit=%d, want 0", exitCode)
+	}
+	if !strings.Contains(errOutput, "[profile]") {
+		t.Fatalf("stderr missing profile header, got %q", errOutput)
+	}
+	for _, phase := range []string{"logger init", "arg parse", "backend select", "backend spawn", "backend run", "total"} {
+		re := regexp.MustCompile(`(?m)^  ` + regexp.QuoteMeta(phase) + `:\s+[0-9]+\.[0-9]{3}ms$`)
+		if !re.MatchString(errOutput) {
+			t.Fatalf("profile output missing phase %q with duration, got %q", phase, errOutput)
+		}
+	}
+	if startupProfiler != nil {
+		t.Fatalf("profiler should be cleared after run")
+	}
+}
+
+func TestRun_NoProfileByDefault(t *testing.T) {
+	defer resetTestHooks()
+	stdout := captureStdoutPipe()
+
+	restore := withBackend(createFakeCodexScript(t, "tid-noprof", "ok"), buildCodexArgs)
+	defer restore()
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	os.Args = []string{"codeagent-wrapper", "task"}
+
+	errOutput := captureStderr(t, func() {
+		_ = run()
+	})
+	restoreStdoutPipe(stdout)
+	if strings.Contains(errOutput, "[profile]") {
+		t.Fatalf("profile output should be absent without --profile, got %q", errOutput)
+	}
+}
+
+func TestRunTaskWithRetries_OnlyLastAttemptMessage(t *testing.T) {
+	defer resetTestHooks()
+	t.Cleanup(executor.SetTaskRetryDelay(0))
+
+	var exitErr *exec.ExitError
+	if err := exec.Command("sh", "-c", "exit 3").Run(); !errors.As(err, &exitErr) {
+		t.Fatalf("failed to build exit error: %v", err)
+	}
+
+	var attempts atomic.Int32
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		if attempts.Add(1) == 1 {
+			return newFakeCmd(fakeCmdConfig{
+				StdoutPlan: []fakeStdoutEvent{
+					{Data: `{"type":"thread.started","thread_id":"attempt-1"}` + "\n"},
+					{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"partial from attempt one"}}` + "\n"},
+				},
+				WaitErr: exitErr,
+			})
+		}
+		return newFakeCmd(fakeCmdConfig{
+			StdoutPlan: []fakeStdoutEvent{
+				{Data: `{"type":"thread.started","thread_id":"attempt-2"}` + "\n"},
+				{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"final answer"}}` + "\n"},
+			},
+		})
+	})
+	codexCommand = "fake-cmd"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	res := runTaskWithRetries(TaskSpec{Task: "task"}, 5, 2, func(ts TaskSpec, timeout int) TaskResult {
+		return runCodexTask(ts, true, timeout)
+	})
+	if got := attempts.Load(); got != 2 {
+		t.Fatalf("attempts = %d, want 2", got)
+	}
+	if res.ExitCode != 0 || res.Error != "" {
+		t.Fatalf("expected success on second attempt, got %+v", res)
+	}
+	if res.Message != "final answer" {
+		t.Fatalf("message = %q, want only the last attempt's message", res.Message)
+	}
+	if res.SessionID != "attempt-2" {
+		t.Fatalf("session = %q, want attempt-2", res.SessionID)
+	}
+}
+
+func TestRunTaskWithRetries_StopsOnNonRetryable(t *testing.T) {
+	t.Cleanup(executor.SetTaskRetryDelay(0))
+
+	for _, code := range []int{124, 127, 130} {
+		calls := 0
+		res := runTaskWithRetries(TaskSpec{ID: "t"}, 5, 3, func(ts TaskSpec, timeout int) TaskResult {
+			calls++
+			return TaskResult{TaskID: ts.ID, ExitCode: code, Error: "nope"}
+		})
+		if calls != 1 || res.ExitCode != code {
+			t.Fatalf("exit %d: calls = %d, result = %+v; want a single attempt", code, calls, res)
+		}
+	}
+}
+
+func TestRunTaskWithBackoff_Curves(t *testing.T) {
+	tests := []struct {
+		curve string
+		want  []time.Duration
+	}{
+		{curve: "fixed", want: []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second}},
+		{curve: "linear", want: []time.Duration{2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second}},
+		{curve: "exponential", want: []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.curve, func(t *testing.T) {
+			var delays []time.Duration
+			t.Cleanup(executor.SetRetryAfterFn(func(d time.Duration) <-chan time.Time {
+				delays = append(delays, d)
+				ch := make(chan time.Time, 1)
+				ch <- time.Now()
+				return ch
+			}))
+
+			calls := 0
+			backoff := RetryBackoff{Curve: tt.curve, Base: 2 * time.Second}
+			res := runTaskWithBackoff(TaskSpec{ID: "t"}, 5, len(tt.want), backoff, func(ts TaskSpec, timeout int) TaskResult {
+				calls++
+				return TaskResult{TaskID: ts.ID, ExitCode: 1, Error: "boom"}
+			})
+			if calls != len(tt.want)+1 || res.ExitCode != 1 {
+				t.Fatalf("calls = %d, result = %+v", calls, res)
+			}
+			if !reflect.DeepEqual(delays, tt.want) {
+				t.Fatalf("delays = %v, want %v", delays, tt.want)
+			}
+		})
+	}
+
+	t.Run("default base and cap", func(t *testing.T) {
+		t.Cleanup(executor.SetTaskRetryDelay(3 * time.Second))
+		if got := (RetryBackoff{}).Delay(4); got != 3*time.Second {
+			t.Fatalf("default fixed delay = %s, want 3s", got)
+		}
+		if got := (RetryBackoff{Curve: "exponential", Base: time.Minute}).Delay(40); got != 10*time.Minute {
+			t.Fatalf("capped exponential delay = %s, want 10m", got)
+		}
+	})
+}
+
+func TestRun_RetryBackoffFlags(t *testing.T) {
+	defer resetTestHooks()
+	var delays []time.Duration
+	t.Cleanup(executor.SetRetryAfterFn(func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}))
+
+	restore := withBackend("echo", func(cfg *Config, target string) []string { return []string{target} })
+	defer restore()
+	calls := 0
+	runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+		calls++
+		if calls < 3 {
+			return TaskResult{ExitCode: 1, Error: "rate limited"}
+		}
+		return TaskResult{Message: "ok"}
+	}
+	os.Args = []string{"codeagent-wrapper", "--retries", "3", "--retry-backoff", "exponential", "--retry-base", "2s", "task"}
+
+	var exitCode int
+	captureOutput(t, func() { exitCode = run() })
+	if exitCode != 0 {
+		t.Fatalf("exit = %d, want 0", exitCode)
+	}
+	if want := []time.Duration{2 * time.Second, 4 * time.Second}; !reflect.DeepEqual(delays, want) {
+		t.Fatalf("delays = %v, want %v", delays, want)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--retry-backoff", "quadratic", "task"}
+	var stderr string
+	captureOutput(t, func() { stderr = captureStderr(t, func() { exitCode = run() }) })
+	if exitCode == 0 || !strings.Contains(stderr, "invalid retry backoff") {
+		t.Fatalf("exit = %d, stderr = %q; want invalid retry backoff error", exitCode, stderr)
+	}
+}
+
+func TestRun_RetriesFlag(t *testing.T) {
+	defer resetTestHooks()
+	t.Cleanup(executor.SetTaskRetryDelay(0))
+	stdout := captureStdoutPipe()
+
+	restore := withBackend("echo", nil)
+	defer restore()
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+
+	calls := 0
+	runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+		calls++
+		if calls == 1 {
+			return TaskResult{ExitCode: 1, Message: "partial", Error: "boom"}
+		}
+		return TaskResult{Message: "done", SessionID: "sid-retry"}
+	}
+	os.Args = []string{"codeagent-wrapper", "--retries", "1", "task"}
+
+	exitCode := run()
+	restoreStdoutPipe(stdout)
+	if exitCode != 0 {
+		t.Fatalf("exit=%d, want 0", exitCode)
+	}
+	if calls != 2 {
+		t.Fatalf("runTaskFn calls = %d, want 2", calls)
+	}
+	if out := stdout.String(); strings.Contains(out, "partial") || !strings.Contains(out, "done") {
+		t.Fatalf("stdout = %q, want only the retried message", out)
+	}
+}
+
+func TestRunCodexTask_FailOnTurnFailed(t *testing.T) {
+	defer resetTestHooks()
+
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		return newFakeCmd(fakeCmdConfig{
+			StdoutPlan: []fakeStdoutEvent{
+				{Data: `{"type":"thread.started","thread_id":"tf-thread"}` + "\n"},
+				{Data: `{"type":"turn.failed","error":{"message":"model refused"}}` + "\n"},
+				{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"half done"}}` + "\n"},
+			},
+		})
+	})
+	codexCommand = "fake-cmd"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("default: exit = %d, want 0 (%s)", res.ExitCode, res.Error)
+	}
+	if len(res.StreamErrors) != 1 || res.StreamErrors[0] != "model refused" {
+		t.Fatalf("StreamErrors = %v, want [model refused]", res.StreamErrors)
+	}
+
+	res = runCodexTask(TaskSpec{Task: "task", FailOnTurnFailed: true}, true, 5)
+	if res.ExitCode == 0 {
+		t.Fatalf("expected failure with FailOnTurnFailed, got %+v", res)
+	}
+	if !strings.Contains(res.Error, "model refused") {
+		t.Fatalf("error = %q, want turn.failed message", res.Error)
+	}
+	if res.Message != "half done" {
+		t.Fatalf("message = %q, want parsed message preserved", res.Message)
+	}
+}
+
+func TestRunCodexTask_FailIfNoFilesChanged(t *testing.T) {
+	defer resetTestHooks()
+
+	withChanges := false
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		plan := []fakeStdoutEvent{{Data: `{"type":"thread.started","thread_id":"fc-thread"}` + "\n"}}
+		if withChanges {
+			plan = append(plan, fakeStdoutEvent{Data: `{"type":"item.completed","item":{"type":"file_change","changes":[{"path":"a.go","kind":"update"},{"path":"b.go","kind":"add"}]}}` + "\n"})
+		}
+		plan = append(plan, fakeStdoutEvent{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}` + "\n"})
+		return newFakeCmd(fakeCmdConfig{StdoutPlan: plan})
+	})
+	codexCommand = "fake-cmd"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("default: exit = %d, want 0 (%s)", res.ExitCode, res.Error)
+	}
+	if len(res.ChangedFiles) != 0 {
+		t.Fatalf("ChangedFiles = %+v, want none", res.ChangedFiles)
+	}
+
+	res = runCodexTask(TaskSpec{Task: "task", FailIfNoFilesChanged: true}, true, 5)
+	if res.ExitCode == 0 || !strings.Contains(res.Error, "without reporting any file changes") {
+		t.Fatalf("expected failure without file changes, got %+v", res)
+	}
+	if res.Message != "done" {
+		t.Fatalf("message = %q, want parsed message preserved", res.Message)
+	}
+
+	withChanges = true
+	res = runCodexTask(TaskSpec{Task: "task", FailIfNoFilesChanged: true}, true, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("with file changes: exit = %d, want 0 (%s)", res.ExitCode, res.Error)
+	}
+	want := []executor.FileChange{{Path: "a.go", Kind: "update"}, {Path: "b.go", Kind: "add"}}
+	if !reflect.DeepEqual(res.ChangedFiles, want) {
+		t.Fatalf("ChangedFiles = %+v, want %+v", res.ChangedFiles, want)
+	}
+}
+
+func TestRunCodexTask_RawEventLog(t *testing.T) {
+	defer resetTestHooks()
+
+	lines := []string{
+		`{"type":"thread.started","thread_id":"raw-thread"}`,
+		`not json at all`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+	}
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		var plan []fakeStdoutEvent
+		for _, line := range lines {
+			plan = append(plan, fakeStdoutEvent{Data: line + "\n"})
+		}
+		return newFakeCmd(fakeCmdConfig{StdoutPlan: plan})
+	})
+	codexCommand = "fake-cmd"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	dir := t.TempDir()
+	rawPath := filepath.Join(dir, "events.jsonl")
+	if err := os.WriteFile(rawPath, []byte("stale\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CODEAGENT_RAW_LOG", rawPath)
+
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode != 0 || res.Message != "done" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	data, err := os.ReadFile(rawPath)
+	if err != nil {
+		t.Fatalf("raw log not written: %v", err)
+	}
+	if want := strings.Join(lines, "\n") + "\n"; string(data) != want {
+		t.Fatalf("raw log = %q, want %q", data, want)
+	}
+
+	// Tasks with an id (parallel mode) get their own file.
+	res = runCodexTask(TaskSpec{ID: "T1", Task: "task"}, true, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events-T1.jsonl")); err != nil {
+		t.Fatalf("per-task raw log missing: %v", err)
+	}
+
+	// An unwritable path only warns.
+	t.Setenv("CODEAGENT_RAW_LOG", filepath.Join(dir, "missing", "events.jsonl"))
+	res = runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode != 0 || res.Message != "done" {
+		t.Fatalf("raw log failure should not fail the task: %+v", res)
+	}
+}
+
+func TestRun_LogAlsoStderr(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			defer resetTestHooks()
+			stdout := captureStdoutPipe()
+
+			restore := withBackend(createFakeCodexScript(t, "tid-mirror", "mirror-ok"), buildCodexArgs)
+			defer restore()
+			stdinReader = strings.NewReader("")
+			isTerminalFn = func() bool { return true }
+			os.Args = []string{"codeagent-wrapper", "task"}
+			if enabled {
+				os.Args = []string{"codeagent-wrapper", "--log-also-stderr", "task"}
+			}
+
+			var exitCode int
+			errOutput := captureStderr(t, func() {
+				exitCode = run()
+			})
+			restoreStdoutPipe(stdout)
+			if exitCode != 0 {
+				t.Fatalf("exit=%d, want 0", exitCode)
+			}
+
+			mirrored := strings.Contains(errOutput, "[INFO] Script started")
+			if mirrored != enabled {
+				t.Fatalf("stderr mirrored=%t, want %t; stderr=%q", mirrored, enabled, errOutput)
+			}
+		})
+	}
+}
+
+func TestRunCodexTask_PostEOFGraceTerminatesLingeringBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+	t.Setenv("CODEAGENT_POST_EOF_GRACE", "200ms")
+
+	scriptPath := filepath.Join(t.TempDir(), "linger.sh")
+	script := `#!/bin/sh
+printf '%s\n' '{"type":"thread.started","thread_id":"linger-thread"}'
+printf '%s\n' '{"type":"item.completed","item":{"type":"agent_message","text":"done early"}}'
+exec 1>&- 2>&-
+exec sleep 30
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	codexCommand = scriptPath
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return nil }
+
+	start := time.Now()
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 20)
+	elapsed := time.Since(start)
+
+	if res.Message != "done early" || res.SessionID != "linger-thread" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if elapsed > 900*time.Millisecond {
+		t.Fatalf("wrapper returned after %v; want it to terminate the lingering backend after the short grace", elapsed)
+	}
+}
+
+func TestRun_DumpLastLog(t *testing.T) {
+	defer resetTestHooks()
+	tempDir := setTempDirEnv(t, t.TempDir())
+
+	base := time.Now().Add(-time.Hour)
+	logs := []struct {
+		name    string
+		content string
+		offset  time.Duration
+	}{
+		{"codeagent-wrapper-2001.log", "older log\n", 5 * time.Minute},
+		{"codeagent-wrapper-2002.log", "newest log\nsecond line\n", 30 * time.Minute},
+		{"codeagent-wrapper-2003.log", "middle log\n", 15 * time.Minute},
+	}
+	for _, l := range logs {
+		path := filepath.Join(tempDir, l.name)
+		if err := os.WriteFile(path, []byte(l.content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		modTime := base.Add(l.offset)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes(%s) error: %v", path, err)
+		}
+	}
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"codeagent-wrapper", "--dump-last-log"}
+
+	var exitCode int
+	output := captureOutput(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 0 {
+		t.Fatalf("exit = %d, want 0", exitCode)
+	}
+	if output != "newest log\nsecond line\n" {
+		t.Fatalf("output = %q, want newest log content", output)
+	}
+}
+
+func TestRun_DumpLastLogNoLogs(t *testing.T) {
+	defer resetTestHooks()
+	setTempDirEnv(t, t.TempDir())
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"codeagent-wrapper", "--dump-last-log"}
+
+	var exitCode int
+	stderr := captureStderr(t, func() {
+		exitCode = run()
+	})
+	if exitCode != 1 {
+		t.Fatalf("exit = %d, want 1", exitCode)
+	}
+	if !strings.Contains(stderr, "no wrapper logs found") {
+		t.Fatalf("stderr = %q, want missing-log error", stderr)
+	}
+}
+
+func TestRun_ConfirmGate(t *testing.T) {
+	bypassArgs := func(cfg *Config, target string) []string {
+		return []string{"--dangerously-bypass-approvals-and-sandbox", target}
+	}
+	safeArgs := func(cfg *Config, target string) []string {
+		return []string{target}
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		argsFn     func(*Config, string) []string
+		answer     string
+		terminal   bool
+		wantRun    bool
+		wantExit   int
+		wantPrompt bool
+	}{
+		{name: "yes runs", args: []string{"--confirm", "task"}, argsFn: bypassArgs, answer: "y\n", terminal: true, wantRun: true, wantExit: 0, wantPrompt: true},
+		{name: "no aborts", args: []string{"--confirm", "task"}, argsFn: bypassArgs, answer: "n\n", terminal: true, wantRun: false, wantExit: 130, wantPrompt: true},
+		{name: "empty answer aborts", args: []string{"--confirm", "task"}, argsFn: bypassArgs, answer: "\n", terminal: true, wantRun: false, wantExit: 130, wantPrompt: true},
+		{name: "non-tty skips prompt", args: []string{"--confirm", "task"}, argsFn: bypassArgs, answer: "", terminal: false, wantRun: true, wantExit: 0},
+		{name: "without flag skips prompt", args: []string{"task"}, argsFn: bypassArgs, answer: "n\n", terminal: true, wantRun: true, wantExit: 0},
+		{name: "no bypass skips prompt", args: []string{"--confirm", "task"}, argsFn: safeArgs, answer: "n\n", terminal: true, wantRun: true, wantExit: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetTestHooks()
+			stdout := captureStdoutPipe()
+			defer restoreStdoutPipe(stdout)
+
+			restore := withBackend("echo", tt.argsFn)
+			defer restore()
+			stdinReader = strings.NewReader(tt.answer)
+			isTerminalFn = func() bool { return tt.terminal }
+
+			ran := false
+			runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+				ran = true
+				return TaskResult{Message: "done"}
+			}
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+
+			var exitCode int
+			stderr := captureStderr(t, func() {
+				exitCode = run()
+			})
+			if exitCode != tt.wantExit {
+				t.Fatalf("exit = %d, want %d (stderr: %s)", exitCode, tt.wantExit, stderr)
+			}
+			if ran != tt.wantRun {
+				t.Fatalf("backend ran = %v, want %v", ran, tt.wantRun)
+			}
+			if got := strings.Contains(stderr, "with bypass in"); got != tt.wantPrompt {
+				t.Fatalf("prompt shown = %v, want %v (stderr: %s)", got, tt.wantPrompt, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_CopySessionToClipboard(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+
+	dir := t.TempDir()
+	captured := filepath.Join(dir, "clipboard.txt")
+	tool := filepath.Join(dir, "fake-clip")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\ncat > \""+captured+"\"\n"), 0o755); err != nil {
+		t.Fatalf("write fake clipboard tool: %v", err)
+	}
+
+	runWith := func(t *testing.T, lookPath func(string) (string, error)) int {
+		t.Helper()
+		stdout := captureStdoutPipe()
+		defer restoreStdoutPipe(stdout)
+
+		restore := withBackend("echo", func(cfg *Config, target string) []string { return []string{target} })
+		defer restore()
+		clipboardCommandsFn = func() [][]string { return [][]string{{"missing-clip"}, {"fake-clip"}} }
+		clipboardLookPathFn = lookPath
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			return TaskResult{Message: "done", SessionID: "sess-clip-42"}
+		}
+		os.Args = []string{"codeagent-wrapper", "--copy-session-to-clipboard", "task"}
+
+		var exitCode int
+		captureStderr(t, func() { exitCode = run() })
+		return exitCode
+	}
+
+	t.Run("pipes session id to tool", func(t *testing.T) {
+		defer resetTestHooks()
+		exitCode := runWith(t, func(name string) (string, error) {
+			if name == "fake-clip" {
+				return tool, nil
+			}
+			return "", exec.ErrNotFound
+		})
+		if exitCode != 0 {
+			t.Fatalf("exit = %d, want 0", exitCode)
+		}
+		data, err := os.ReadFile(captured)
+		if err != nil {
+			t.Fatalf("clipboard tool not invoked: %v", err)
+		}
+		if string(data) != "sess-clip-42" {
+			t.Fatalf("clipboard content = %q, want %q", data, "sess-clip-42")
+		}
+	})
+
+	t.Run("missing tool only warns", func(t *testing.T) {
+		defer resetTestHooks()
+		_ = os.Remove(captured)
+		exitCode := runWith(t, func(string) (string, error) { return "", exec.ErrNotFound })
+		if exitCode != 0 {
+			t.Fatalf("exit = %d, want 0", exitCode)
+		}
+		if _, err := os.Stat(captured); !os.IsNotExist(err) {
+			t.Fatalf("clipboard tool should not run, stat err = %v", err)
+		}
+		if _, err := copyToClipboard("x"); err == nil || !strings.Contains(err.Error(), "no clipboard tool found (tried missing-clip, fake-clip)") {
+			t.Fatalf("copyToClipboard error = %v", err)
+		}
+	})
+}
+
+func TestRun_StripControl(t *testing.T) {
+	const raw = "\x1b[?25l\x1b[2Kworking 10%\rworking 100%\x1b[?25h\n\x1b[32mdone\x1b[0m"
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "enabled", args: []string{"--strip-control", "task"}, want: "working 100%\ndone\n"},
+		{name: "disabled", args: []string{"task"}, want: raw + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetTestHooks()
+			restore := withBackend("echo", func(cfg *Config, target string) []string { return []string{target} })
+			defer restore()
+			runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+				return TaskResult{Message: raw}
+			}
+			os.Args = append([]string{"codeagent-wrapper"}, tt.args...)
+
+			var exitCode int
+			output := captureOutput(t, func() { exitCode = run() })
+			if exitCode != 0 {
+				t.Fatalf("exit = %d, want 0", exitCode)
+			}
+			if output != tt.want {
+				t.Fatalf("output = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_ResumeWorkdir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	projectDir := t.TempDir()
+
+	runCapture := func(t *testing.T, args ...string) (int, string, TaskSpec) {
+		t.Helper()
+		restore := withBackend("echo", func(cfg *Config, target string) []string { return []string{target} })
+		defer restore()
+		var got TaskSpec
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			got = ts
+			return TaskResult{Message: "done", SessionID: "sess-wd-1"}
+		}
+		os.Args = append([]string{"codeagent-wrapper"}, args...)
+
+		var exitCode int
+		var stderr string
+		captureOutput(t, func() { stderr = captureStderr(t, func() { exitCode = run() }) })
+		return exitCode, stderr, got
+	}
+
+	defer resetTestHooks()
+
+	// Unknown session: clear error instead of silently using the cwd.
+	exitCode, stderr, _ := runCapture(t, "--resume-workdir", "resume", "sess-wd-1", "continue")
+	if exitCode == 0 || !strings.Contains(stderr, `no recorded workdir for session "sess-wd-1"`) {
+		t.Fatalf("exit = %d, stderr = %q; want missing-session error", exitCode, stderr)
+	}
+
+	// A first run records session -> workdir.
+	if exitCode, stderr, _ = runCapture(t, "first task", projectDir); exitCode != 0 {
+		t.Fatalf("first run exit = %d, stderr = %q", exitCode, stderr)
+	}
+	absProject, _ := filepath.Abs(projectDir)
+	rec, ok, err := config.LookupSession("sess-wd-1")
+	if err != nil || !ok || rec.WorkDir != absProject {
+		t.Fatalf("recorded session = %+v, ok=%v, err=%v; want workdir %s", rec, ok, err, absProject)
+	}
+
+	exitCode, stderr, got := runCapture(t, "--resume-workdir", "resume", "sess-wd-1", "continue")
+	if exitCode != 0 {
+		t.Fatalf("resume exit = %d, stderr = %q", exitCode, stderr)
+	}
+	if got.WorkDir != absProject || got.SessionID != "sess-wd-1" {
+		t.Fatalf("resumed task = %+v; want workdir %s", got, absProject)
+	}
+
+	// An explicit workdir still wins.
+	otherDir := t.TempDir()
+	if _, _, got = runCapture(t, "--resume-workdir", "resume", "sess-wd-1", "continue", otherDir); got.WorkDir != otherDir {
+		t.Fatalf("explicit workdir = %q, want %q", got.WorkDir, otherDir)
+	}
+
+	// Without the flag resume keeps the default workdir.
+	if _, _, got = runCapture(t, "resume", "sess-wd-1", "continue"); got.WorkDir != defaultWorkdir {
+		t.Fatalf("workdir without flag = %q, want %q", got.WorkDir, defaultWorkdir)
+	}
+
+	if exitCode, stderr, _ = runCapture(t, "--resume-workdir", "new task"); exitCode == 0 || !strings.Contains(stderr, "--resume-workdir requires resume mode") {
+		t.Fatalf("exit = %d, stderr = %q; want resume-mode error", exitCode, stderr)
+	}
+}
+
+func TestRunCodexTask_RecordsWorkDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	scriptPath := filepath.Join(t.TempDir(), "pwd.sh")
+	script := `#!/bin/sh
+printf '{"type":"thread.started","thread_id":"wd-thread"}\n'
+printf '{"type":"item.completed","item":{"type":"agent_message","text":"%s"}}\n' "$(pwd -P)"
+sleep 0.05
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	codexCommand = scriptPath
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return nil }
+
+	resolve := func(dir string) string {
+		t.Helper()
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			t.Fatalf("EvalSymlinks(%s) error: %v", dir, err)
+		}
+		return resolved
+	}
+
+	projectDir := t.TempDir()
+	res := runCodexTask(TaskSpec{Task: "task", WorkDir: projectDir}, true, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("unexpected failure: %+v", res)
+	}
+	if resolve(res.WorkDir) != resolve(projectDir) || res.Message != resolve(projectDir) {
+		t.Fatalf("WorkDir = %q (ran in %q), want %q", res.WorkDir, res.Message, projectDir)
+	}
+
+	worktreeDir := t.TempDir()
+	t.Cleanup(executor.SetCreateWorktreeFn(func(string) (*worktree.Paths, error) {
+		return &worktree.Paths{Dir: worktreeDir, Branch: "do/wd", TaskID: "wd"}, nil
+	}))
+	res = runCodexTask(TaskSpec{Task: "task", WorkDir: projectDir, Worktree: true}, true, 5)
+	if res.ExitCode != 0 {
+		t.Fatalf("unexpected failure: %+v", res)
+	}
+	if resolve(res.WorkDir) != resolve(worktreeDir) || res.Message != resolve(worktreeDir) {
+		t.Fatalf("worktree WorkDir = %q (ran in %q), want %q", res.WorkDir, res.Message, worktreeDir)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "out.json")
+	if err := writeStructuredOutput(outputPath, []TaskResult{res}); err != nil {
+		t.Fatalf("writeStructuredOutput() error: %v", err)
+	}
+	var payload struct {
+		Results []struct {
+			WorkDir string `json:"work_dir"`
+		} `json:"results"`
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if len(payload.Results) != 1 || payload.Results[0].WorkDir != res.WorkDir {
+		t.Fatalf("output work_dir = %+v, want %q", payload.Results, res.WorkDir)
+	}
+}
+
+func TestRun_AppendWorkdirContext(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	runWithContext := func(t *testing.T, args ...string) TaskSpec {
+		t.Helper()
+		defer resetTestHooks()
+		stdout := captureStdoutPipe()
+		defer restoreStdoutPipe(stdout)
+
+		restore := withBackend("echo", nil)
+		defer restore()
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+
+		var got TaskSpec
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			got = ts
+			return TaskResult{Message: "done"}
+		}
+		os.Args = append([]string{"codeagent-wrapper"}, args...)
+		if code := run(); code != 0 {
+			t.Fatalf("exit = %d, want 0", code)
+		}
+		return got
+	}
+
+	writeFile := func(t *testing.T, path string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	t.Run("git repo respects gitignore and depth", func(t *testing.T) {
+		repo := t.TempDir()
+		if out, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %v: %s", err, out)
+		}
+		for _, name := range []string{"main.go", "pkg/util.go", "secret/key.txt", "deep/a/b/hidden.go"} {
+			writeFile(t, filepath.Join(repo, name))
+		}
+		if err := os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("secret/\n"), 0o644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+
+		ts := runWithContext(t, "--append-workdir-context", "task", repo)
+		if !strings.HasPrefix(ts.Task, "task\n\n# Workdir Context\n\n<workdir-tree") {
+			t.Fatalf("task missing workdir context:\n%s", ts.Task)
+		}
+		for _, want := range []string{"\nmain.go\n", "\npkg/\n", "\n  util.go\n", "\ndeep/\n", "\n    b/\n"} {
+			if !strings.Contains(ts.Task, want) {
+				t.Fatalf("listing missing %q:\n%s", want, ts.Task)
+			}
+		}
+		for _, unwanted := range []string{"key.txt", "secret", "hidden.go"} {
+			if strings.Contains(ts.Task, unwanted) {
+				t.Fatalf("listing should not contain %q:\n%s", unwanted, ts.Task)
+			}
+		}
+		if !ts.UseStdin {
+			t.Fatalf("expected the multi-line task to switch to stdin mode")
+		}
+	})
+
+	t.Run("listing is bounded", func(t *testing.T) {
+		dir := t.TempDir()
+		for i := 0; i < 400; i++ {
+			writeFile(t, filepath.Join(dir, fmt.Sprintf("file_with_a_long_name_%03d.txt", i)))
+		}
+
+		ts := runWithContext(t, "--append-workdir-context", "task", dir)
+		_, listing, ok := strings.Cut(ts.Task, "# Workdir Context\n\n")
+		if !ok {
+			t.Fatalf("task missing workdir context:\n%s", ts.Task)
+		}
+		if !strings.Contains(listing, "more entries omitted") {
+			t.Fatalf("expected truncation marker in listing:\n%s", listing)
+		}
+		if len(listing) > 8500 {
+			t.Fatalf("listing length = %d, want bounded", len(listing))
+		}
+		if strings.Contains(listing, "file_with_a_long_name_399.txt") {
+			t.Fatalf("listing should be truncated before the last entry")
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		ts := runWithContext(t, "task", t.TempDir())
+		if ts.Task != "task" || ts.UseStdin {
+			t.Fatalf("task = %q (stdin=%v), want untouched", ts.Task, ts.UseStdin)
+		}
+	})
+}
+
+func TestRun_WorkdirGitCheck(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	newRepo := func(t *testing.T, dirty bool) string {
+		t.Helper()
+		dir := t.TempDir()
+		if out, err := exec.Command("git", "-C", dir, "init").CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+		if dirty {
+			if err := os.WriteFile(filepath.Join(dir, "scratch.txt"), []byte("wip"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	runIn := func(t *testing.T, flag, workdir string) (int, string, bool) {
+		t.Helper()
+		stdout := captureStdoutPipe()
+		defer restoreStdoutPipe(stdout)
+
+		restore := withBackend("echo", func(cfg *Config, target string) []string { return []string{target} })
+		defer restore()
+		ran := false
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			ran = true
+			return TaskResult{Message: "done", SessionID: "sid"}
+		}
+		os.Args = []string{"codeagent-wrapper", "--log-also-stderr", flag, "task", workdir}
+
+		var exitCode int
+		stderr := captureStderr(t, func() { exitCode = run() })
+		return exitCode, stderr, ran
+	}
+
+	t.Run("dirty tree warns", func(t *testing.T) {
+		defer resetTestHooks()
+		code, stderr, ran := runIn(t, "--workdir-git-check", newRepo(t, true))
+		if code != 0 || !ran {
+			t.Fatalf("exit = %d, ran = %v; want the run to proceed", code, ran)
+		}
+		if !strings.Contains(stderr, "1 uncommitted change(s): ?? scratch.txt") {
+			t.Fatalf("stderr missing dirty-tree warning: %q", stderr)
+		}
+	})
+
+	t.Run("dirty tree fails with require-clean", func(t *testing.T) {
+		defer resetTestHooks()
+		code, stderr, ran := runIn(t, "--require-clean", newRepo(t, true))
+		if code != 1 || ran {
+			t.Fatalf("exit = %d, ran = %v; want 1 without running", code, ran)
+		}
+		if !strings.Contains(stderr, "--require-clean") {
+			t.Fatalf("stderr missing --require-clean error: %q", stderr)
+		}
+	})
+
+	t.Run("clean tree passes", func(t *testing.T) {
+		defer resetTestHooks()
+		code, stderr, ran := runIn(t, "--require-clean", newRepo(t, false))
+		if code != 0 || !ran {
+			t.Fatalf("exit = %d, ran = %v; want 0", code, ran)
+		}
+		if strings.Contains(stderr, "uncommitted") {
+			t.Fatalf("clean tree should not warn: %q", stderr)
+		}
+	})
+
+	t.Run("non-git workdir is skipped", func(t *testing.T) {
+		defer resetTestHooks()
+		code, _, ran := runIn(t, "--require-clean", t.TempDir())
+		if code != 0 || !ran {
+			t.Fatalf("exit = %d, ran = %v; want 0", code, ran)
+		}
+	})
+}
+
+func TestRun_Pipeline(t *testing.T) {
+	setup := func(t *testing.T) {
+		t.Helper()
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("USERPROFILE", home)
+		t.Cleanup(config.ResetModelsConfigCacheForTest)
+		config.ResetModelsConfigCacheForTest()
+
+		configDir := filepath.Join(home, ".codeagent")
+		if err := os.MkdirAll(configDir, 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "agents": {
+    "explore": { "backend": "codex", "model": "explore-model" },
+    "oracle": { "backend": "codex", "model": "oracle-model" }
+  }
+}`), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+	}
+
+	t.Run("stages run in order and chain output", func(t *testing.T) {
+		defer resetTestHooks()
+		setup(t)
+		outputPath := filepath.Join(t.TempDir(), "pipeline.json")
+
+		var calls []TaskSpec
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			calls = append(calls, ts)
+			return TaskResult{Message: "findings from " + ts.Model, SessionID: "sid-" + ts.Model}
+		}
+		os.Args = []string{"codeagent-wrapper", "--pipeline", "explore, oracle", "--output", outputPath, "review the parser"}
+
+		var code int
+		out := captureOutput(t, func() { code = run() })
+		if code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		if len(calls) != 2 {
+			t.Fatalf("backend calls = %d, want 2", len(calls))
+		}
+		if calls[0].Agent != "explore" || calls[1].Agent != "oracle" {
+			t.Fatalf("stage order = %q, %q; want explore, oracle", calls[0].Agent, calls[1].Agent)
+		}
+		if calls[0].Task != "review the parser" {
+			t.Fatalf("first stage task = %q", calls[0].Task)
+		}
+		if !strings.HasPrefix(calls[1].Task, "review the parser") || !strings.Contains(calls[1].Task, "# Output from previous agent (explore)\n\nfindings from explore-model") {
+			t.Fatalf("second stage did not receive first stage output: %q", calls[1].Task)
+		}
+		for _, want := range []string{"=== Stage 1/2: explore ===", "findings from explore-model", "=== Stage 2/2: oracle ===", "SESSION_ID: sid-oracle-model"} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("stdout missing %q: %q", want, out)
+			}
+		}
+
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("failed to read output file: %v", err)
+		}
+		var payload struct {
+			Results []TaskResult `json:"results"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("failed to unmarshal output json: %v", err)
+		}
+		if len(payload.Results) != 2 || payload.Results[0].TaskID != "explore" || payload.Results[1].TaskID != "oracle" {
+			t.Fatalf("output results = %+v, want both stages", payload.Results)
+		}
+	})
+
+	t.Run("failing stage stops the pipeline", func(t *testing.T) {
+		defer resetTestHooks()
+		setup(t)
+
+		calls := 0
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			calls++
+			return TaskResult{ExitCode: 3, Error: "boom"}
+		}
+		os.Args = []string{"codeagent-wrapper", "--pipeline", "explore,oracle", "task"}
+
+		var code int
+		_ = captureOutput(t, func() { code = run() })
+		if code != 3 {
+			t.Fatalf("run exit = %d, want 3", code)
+		}
+		if calls != 1 {
+			t.Fatalf("backend calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("explicit stdin task has CRLF normalized", func(t *testing.T) {
+		defer resetTestHooks()
+		setup(t)
+		t.Setenv("CODEAGENT_NORMALIZE_EOL", "1")
+		stdinReader = strings.NewReader("review\r\nthe parser\r\n")
+
+		var tasks []string
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			tasks = append(tasks, ts.Task)
+			return TaskResult{Message: "ok"}
+		}
+		os.Args = []string{"codeagent-wrapper", "--pipeline", "explore,oracle", "-"}
+
+		var code int
+		_ = captureOutput(t, func() { code = run() })
+		if code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		if len(tasks) != 2 {
+			t.Fatalf("backend calls = %d, want 2", len(tasks))
+		}
+		for i, task := range tasks {
+			if strings.Contains(task, "\r") || !strings.HasPrefix(task, "review\nthe parser") {
+				t.Fatalf("stage %d task = %q, want CRLF converted", i+1, task)
+			}
+		}
+	})
+
+	t.Run("rejects agent and resume", func(t *testing.T) {
+		defer resetTestHooks()
+		setup(t)
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			t.Fatalf("backend should not run")
+			return TaskResult{}
+		}
+		for _, args := range [][]string{
+			{"--pipeline", "explore,oracle", "--agent", "explore", "task"},
+			{"--pipeline", "explore,oracle", "resume", "sid", "task"},
+			{"--pipeline", "explore,,oracle", "task"},
+		} {
+			os.Args = append([]string{"codeagent-wrapper"}, args...)
+			if code := run(); code != 1 {
+				t.Fatalf("run(%v) exit = %d, want 1", args, code)
+			}
+		}
+	})
+}
+
+func TestRun_Probe(t *testing.T) {
+	probe := func(t *testing.T, result TaskResult, extra ...string) (int, string, string) {
+		t.Helper()
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+		var gotTask string
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			gotTask = ts.Task
+			return result
+		}
+		os.Args = append([]string{"codeagent-wrapper", "--backend", "claude", "--probe"}, extra...)
+
+		var code int
+		out := captureOutput(t, func() { code = run() })
+		return code, out, gotTask
+	}
+
+	t.Run("success", func(t *testing.T) {
+		defer resetTestHooks()
+		code, out, task := probe(t, TaskResult{Message: "OK", SessionID: "sid"})
+		if code != 0 {
+			t.Fatalf("exit = %d, want 0", code)
+		}
+		if task != probeTask {
+			t.Fatalf("probe task = %q, want %q", task, probeTask)
+		}
+		if !strings.Contains(out, "Probe claude: OK in ") || !strings.Contains(out, "(reply: OK)") {
+			t.Fatalf("unexpected probe output: %q", out)
+		}
+	})
+
+	t.Run("backend failure", func(t *testing.T) {
+		defer resetTestHooks()
+		code, out, _ := probe(t, TaskResult{ExitCode: 2, Error: "not logged in"})
+		if code != 2 {
+			t.Fatalf("exit = %d, want 2", code)
+		}
+		if !strings.Contains(out, "Probe claude: FAILED in ") || !strings.Contains(out, "not logged in") {
+			t.Fatalf("unexpected probe output: %q", out)
+		}
+	})
+
+	t.Run("empty reply fails", func(t *testing.T) {
+		defer resetTestHooks()
+		code, out, _ := probe(t, TaskResult{})
+		if code == 0 || !strings.Contains(out, "FAILED") || !strings.Contains(out, "no output message") {
+			t.Fatalf("exit = %d, output %q; want empty-reply failure", code, out)
+		}
+	})
+
+	t.Run("rejects a task", func(t *testing.T) {
+		defer resetTestHooks()
+		if code, _, task := probe(t, TaskResult{Message: "OK"}, "do work"); code != 1 || task != "" {
+			t.Fatalf("exit = %d, task = %q; want 1 without running", code, task)
+		}
+	})
+}
+
+func TestRun_OpencodeBackendEndToEnd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	binDir := t.TempDir()
+	argsFile := filepath.Join(t.TempDir(), "args.txt")
+	script := `#!/bin/sh
+printf '%s\n' "$@" > "` + argsFile + `"
+printf '{"type":"text","sessionID":"ses_e2e","part":{"type":"text","messageID":"msg_1","text":"Checking."}}\n'
+printf '{"type":"step_finish","sessionID":"ses_e2e","part":{"type":"step-finish","messageID":"msg_1","reason":"tool-calls"}}\n'
+printf '{"type":"text","sessionID":"ses_e2e","part":{"type":"text","messageID":"msg_2","text":"opencode done"}}\n'
+printf '{"type":"step_finish","sessionID":"ses_e2e","part":{"type":"step-finish","messageID":"msg_2","reason":"stop"}}\n'
+sleep 0.2
+`
+	if err := os.WriteFile(filepath.Join(binDir, "opencode"), []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake opencode: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	os.Args = []string{"codeagent-wrapper", "--backend", "opencode", "--model", "opencode/grok-code", "say hi"}
+
+	var code int
+	out := captureOutput(t, func() { code = run() })
+	if code != 0 {
+		t.Fatalf("run exit = %d, want 0 (stdout %q)", code, out)
+	}
+	if !strings.Contains(out, "opencode done") || strings.Contains(out, "Checking.") {
+		t.Fatalf("stdout should hold only the final assistant message: %q", out)
+	}
+	if !strings.Contains(out, "SESSION_ID: ses_e2e") {
+		t.Fatalf("stdout missing session id: %q", out)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("fake opencode was not run: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "run\n-m\nopencode/grok-code\n--format\njson\nsay hi"; got != want {
+		t.Fatalf("opencode args = %q, want %q", got, want)
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	dryRun := func(t *testing.T, args ...string) (int, string) {
+		t.Helper()
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			t.Fatalf("--dry-run must not run the backend")
+			return TaskResult{}
+		}
+		os.Args = append([]string{"codeagent-wrapper", "--dry-run"}, args...)
+		var code int
+		out := captureOutput(t, func() { code = run() })
+		return code, out
+	}
+
+	t.Run("new task as argument", func(t *testing.T) {
+		defer resetTestHooks()
+		workdir := t.TempDir()
+		code, out := dryRun(t, "--backend", "claude", "fix it", workdir)
+		if code != 0 {
+			t.Fatalf("exit = %d, want 0", code)
+		}
+		cfg := &Config{Mode: "new", WorkDir: workdir, Backend: "claude"}
+		wantCmd := "Command: claude " + strings.Join(ClaudeBackend{}.BuildArgs(cfg, "fix it"), " ")
+		for _, want := range []string{"Backend: claude", wantCmd, "Workdir: " + workdir, "Stdin: no"} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("dry-run output missing %q:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("resume with stdin heuristics", func(t *testing.T) {
+		defer resetTestHooks()
+		code, out := dryRun(t, "resume", "sid-123", "line one\nline two")
+		if code != 0 {
+			t.Fatalf("exit = %d, want 0", code)
+		}
+		if !strings.Contains(out, "sid-123") || !strings.HasSuffix(strings.Split(out, "\n")[1], " -") {
+			t.Fatalf("resume command should include the session and read the task from stdin:\n%s", out)
+		}
+		if !strings.Contains(out, "Stdin: yes (newline)") {
+			t.Fatalf("dry-run output missing stdin reason:\n%s", out)
+		}
+	})
+}
+
+func TestRun_CacheDir(t *testing.T) {
+	defer resetTestHooks()
+	cacheDir := t.TempDir()
+	workdir := t.TempDir()
+
+	calls := 0
+	runOnce := func(t *testing.T, task string, extra ...string) TaskResult {
+		t.Helper()
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			calls++
+			return TaskResult{TaskID: "single", Message: "result for " + ts.Task, SessionID: "sid"}
+		}
+		outputPath := filepath.Join(t.TempDir(), "out.json")
+		os.Args = append([]string{"codeagent-wrapper", "--cache-dir", cacheDir, "--output", outputPath}, extra...)
+		os.Args = append(os.Args, task, workdir)
+
+		var code int
+		_ = captureOutput(t, func() { code = run() })
+		if code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("failed to read output file: %v", err)
+		}
+		var payload outputPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("failed to unmarshal output json: %v", err)
+		}
+		return payload.Results[0]
+	}
+
+	if res := runOnce(t, "task A"); res.Cached || calls != 1 {
+		t.Fatalf("first run: cached = %v, calls = %d", res.Cached, calls)
+	}
+	if res := runOnce(t, "task A"); !res.Cached || calls != 1 || res.Message != "result for task A" {
+		t.Fatalf("second identical run should hit the cache: %+v, calls = %d", res, calls)
+	}
+	if res := runOnce(t, "task B"); res.Cached || calls != 2 {
+		t.Fatalf("changed task should miss: cached = %v, calls = %d", res.Cached, calls)
+	}
+	if res := runOnce(t, "task A", "--refresh"); res.Cached || calls != 3 {
+		t.Fatalf("--refresh should bypass the cache: cached = %v, calls = %d", res.Cached, calls)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--refresh", "task"}
+	if _, err := parseArgs(); err == nil {
+		t.Fatalf("--refresh without --cache-dir should be rejected")
+	}
+}
+
+func TestRun_DetachAndAttach(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	handleDir := t.TempDir()
+	detachHandleDirFn = func() (string, error) { return handleDir, nil }
+	attachPollInterval = 10 * time.Millisecond
+	var streamed bytes.Buffer
+	attachStreamOutput = &streamed
+
+	// Stand-in for the re-executed wrapper: logs, waits, then writes the
+	// --output document the real child would produce.
+	var childArgs []string
+	detachExecutableFn = func() (string, error) { return "/bin/sh", nil }
+	detachCommandFn = func(name string, args ...string) *exec.Cmd {
+		childArgs = args
+		script := `echo "child running"; sleep 0.3; printf '{"results":[{"task_id":"","exit_code":0,"message":"detached done","session_id":"sid-detached","error":"","log_path":""}],"summary":{"total":1,"success":1,"failed":0}}\n' > "$2"`
+		return exec.Command(name, append([]string{"-c", script, "sh"}, args...)...)
+	}
+
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+		t.Fatalf("--detach must not run the task in the foreground")
+		return TaskResult{}
+	}
+	os.Args = []string{"codeagent-wrapper", "--detach", "long task"}
+
+	var code int
+	out := captureOutput(t, func() { code = run() })
+	if code != 0 {
+		t.Fatalf("detach exit = %d, want 0", code)
+	}
+	if len(childArgs) < 3 || childArgs[0] != "--output" || childArgs[len(childArgs)-1] != "long task" {
+		t.Fatalf("child args = %q, want --output <path> ... long task without --detach", childArgs)
+	}
+	for _, arg := range childArgs {
+		if arg == "--detach" {
+			t.Fatalf("child args should not contain --detach: %q", childArgs)
+		}
+	}
+
+	var handlePath string
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, "DETACHED: "); ok {
+			handlePath = rest
+		}
+	}
+	if handlePath == "" {
+		t.Fatalf("detach output missing handle: %q", out)
+	}
+	handle, err := readDetachHandle(handlePath)
+	if err != nil {
+		t.Fatalf("invalid handle: %v", err)
+	}
+	// Still running: detach returned without waiting for the run.
+	if handle.PID <= 0 || handle.Log == "" || !processAlive(handle.PID) {
+		t.Fatalf("handle = %+v, want a running pid and a log path", handle)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--attach", handlePath}
+	out = captureOutput(t, func() { code = run() })
+	if code != 0 {
+		t.Fatalf("attach exit = %d, want 0", code)
+	}
+	if !strings.Contains(out, "detached done") || !strings.Contains(out, "SESSION_ID: sid-detached") {
+		t.Fatalf("attach output = %q", out)
+	}
+	if !strings.Contains(streamed.String(), "child running") {
+		t.Fatalf("attach should stream the console log, got %q", streamed.String())
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--attach", filepath.Join(t.TempDir(), "missing.json")}
+	if code := run(); code != 1 {
+		t.Fatalf("attach to a missing handle exit = %d, want 1", code)
+	}
+}
+
+func TestRun_StreamMessages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+printf '{"type":"text","sessionID":"ses_stream","part":{"type":"text","messageID":"msg_1","text":"Checking."}}\n'
+printf '{"type":"text","sessionID":"ses_stream","part":{"type":"text","messageID":"msg_2","text":"all "}}\n'
+printf '{"type":"text","sessionID":"ses_stream","part":{"type":"text","messageID":"msg_2","text":"done"}}\n'
+printf '{"type":"step_finish","sessionID":"ses_stream","part":{"type":"step-finish","messageID":"msg_2","reason":"stop"}}\n'
+sleep 0.2
+`
+	if err := os.WriteFile(filepath.Join(binDir, "opencode"), []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake opencode: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("CODEAGENT_STREAM", "1")
+
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	os.Args = []string{"codeagent-wrapper", "--backend", "opencode", "say hi"}
+
+	var code int
+	out := captureOutput(t, func() { code = run() })
+	if code != 0 {
+		t.Fatalf("run exit = %d, want 0 (stdout %q)", code, out)
+	}
+	if !strings.HasPrefix(out, "Checking.\nall done\n") {
+		t.Fatalf("stdout should start with the streamed text: %q", out)
+	}
+	if strings.Count(out, "all done") != 1 {
+		t.Fatalf("final message should not be printed again after streaming: %q", out)
+	}
+	if strings.Count(out, "SESSION_ID: ses_stream") != 1 {
+		t.Fatalf("stdout should end with one session footer: %q", out)
+	}
+}
+
+func TestRun_BackendBinaryOverride(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	bin := filepath.Join(t.TempDir(), "opencode-0.9")
+	script := `#!/bin/sh
+printf '{"type":"text","sessionID":"ses_bin","part":{"type":"text","messageID":"msg_1","text":"from override"}}\n'
+printf '{"type":"step_finish","sessionID":"ses_bin","part":{"type":"step-finish","messageID":"msg_1","reason":"stop"}}\n'
+sleep 0.2
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake opencode: %v", err)
+	}
+	t.Setenv("OPENCODE_BIN", bin)
+
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	os.Args = []string{"codeagent-wrapper", "--backend", "opencode", "say hi"}
+
+	var code int
+	var out string
+	errOut := captureStderr(t, func() {
+		out = captureOutput(t, func() { code = run() })
+	})
+	if code != 0 {
+		t.Fatalf("run exit = %d, want 0 (stdout %q, stderr %q)", code, out, errOut)
+	}
+	if !strings.Contains(out, "from override") {
+		t.Fatalf("stdout should hold the override's message: %q", out)
+	}
+	if !strings.Contains(errOut, "Command: "+bin+" run") {
+		t.Fatalf("startup banner should show the override binary: %q", errOut)
+	}
+}
+
+func TestRun_BannerRedactsSecrets(t *testing.T) {
+	defer resetTestHooks()
+	tmp := setTempDirEnv(t, t.TempDir())
+	t.Setenv("CODEAGENT_LOG_LEVEL", "debug")
+
+	const secret = "sk-proj-abcdefghijklmnop1234"
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+	runTaskFn = func(TaskSpec, bool, int) TaskResult { return TaskResult{ExitCode: 0, Message: "ok"} }
+	os.Args = []string{"codeagent-wrapper", "call the api with " + secret}
+
+	var code int
+	errOut := captureStderr(t, func() {
+		_ = captureOutput(t, func() { code = run() })
+	})
+	if code != 0 {
+		t.Fatalf("run exit = %d, want 0 (stderr %q)", code, errOut)
+	}
+	if strings.Contains(errOut, secret) || !strings.Contains(errOut, "sk-p****1234") {
+		t.Fatalf("banner should show the redacted key only: %q", errOut)
+	}
+
+	// With CODEAGENT_LOG_LEVEL=debug the log file keeps the full command.
+	logs, _ := filepath.Glob(filepath.Join(tmp, fmt.Sprintf("%s-%d*.log", primaryLogPrefix(), os.Getpid())))
+	found := false
+	for _, path := range logs {
+		data, _ := os.ReadFile(path)
+		found = found || (strings.Contains(string(data), "Command (unredacted)") && strings.Contains(string(data), secret))
+		_ = os.Remove(path)
+	}
+	if !found {
+		t.Fatalf("debug log should keep the unredacted command; logs: %v", logs)
+	}
+}
+
+func TestRun_QuietSuppressesBanner(t *testing.T) {
+	quietRun := func(t *testing.T, args ...string) (int, string, string) {
+		t.Helper()
+		defer resetTestHooks()
+		stdinReader = strings.NewReader("")
+		isTerminalFn = func() bool { return true }
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			if !ts.Quiet {
+				t.Errorf("TaskSpec.Quiet = false, want true")
+			}
+			return TaskResult{Message: "all done", SessionID: "sid-quiet"}
+		}
+		os.Args = append([]string{"codeagent-wrapper"}, args...)
+		var code int
+		var out string
+		errOut := captureStderr(t, func() {
+			out = captureOutput(t, func() { code = run() })
+		})
+		return code, out, errOut
+	}
+
+	check := func(t *testing.T, code int, out, errOut string) {
+		t.Helper()
+		if code != 0 {
+			t.Fatalf("run exit = %d, want 0", code)
+		}
+		if strings.Contains(errOut, "[codeagent-wrapper]") || strings.Contains(errOut, "Command:") {
+			t.Fatalf("--quiet should suppress the banner, stderr = %q", errOut)
+		}
+		if !strings.Contains(out, "all done") || !strings.Contains(out, "SESSION_ID: sid-quiet") {
+			t.Fatalf("stdout should keep the message and footer, got %q", out)
+		}
+	}
+
+	t.Run("flag", func(t *testing.T) {
+		code, out, errOut := quietRun(t, "--quiet", "do it")
+		check(t, code, out, errOut)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("CODEAGENT_QUIET", "1")
+		code, out, errOut := quietRun(t, "do it")
+		check(t, code, out, errOut)
+	})
+}
+
+func TestRun_WorktreeCleanup(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	defer resetTestHooks()
+
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"-c", "user.email=t@t", "-c", "user.name=T", "commit", "--allow-empty", "-m", "init"},
+		{"worktree", "add", "-b", "do/20260101-abc123", ".worktrees/do-20260101-abc123"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	worktreeDir := filepath.Join(repo, ".worktrees", "do-20260101-abc123")
+
+	cleanup := func(args ...string) (int, string, string) {
+		os.Args = append([]string{"codeagent-wrapper", "--worktree-cleanup"}, args...)
+		var code int
+		var out string
+		errOut := captureStderr(t, func() {
+			out = captureOutput(t, func() { code = run() })
+		})
+		return code, out, errOut
+	}
+
+	code, out, errOut := cleanup("20260101-abc123", repo)
+	if code != 0 {
+		t.Fatalf("exit = %d, want 0 (stderr %q)", code, errOut)
+	}
+	if !strings.Contains(out, "Removed worktree") || !strings.Contains(out, "do/20260101-abc123") {
+		t.Fatalf("stdout = %q, want a removal summary", out)
+	}
+	if _, err := os.Stat(worktreeDir); !os.IsNotExist(err) {
+		t.Fatalf("worktree dir still exists: %v", err)
+	}
+	if exec.Command("git", "-C", repo, "rev-parse", "--verify", "--quiet", "refs/heads/do/20260101-abc123").Run() == nil {
+		t.Fatalf("merged task branch should be deleted")
+	}
+
+	code, _, errOut = cleanup("20260101-abc123", repo)
+	if code != 1 || !strings.Contains(errOut, "no worktree or branch found for task 20260101-abc123") {
+		t.Fatalf("second cleanup exit = %d, stderr = %q; want 1 and a not-found error", code, errOut)
+	}
+
+	if out, err := exec.Command("git", "-C", repo, "worktree", "add", "-b", "do/20260102-def456", ".worktrees/do-20260102-def456").CombinedOutput(); err != nil {
+		t.Fatalf("git worktree add: %v\n%s", err, out)
+	}
+	code, out, errOut = cleanup("stale", repo)
+	if code != 0 {
+		t.Fatalf("stale cleanup exit = %d, want 0 (stderr %q)", code, errOut)
+	}
+	for _, want := range []string{"Worktrees scanned: 1", "Worktrees deleted: 1", "do-20260102-def456", "Worktrees kept: 0"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("stdout = %q, want %q", out, want)
+		}
+	}
+}
+
+func TestRunCodexTask_PlainTextFallback(t *testing.T) {
+	defer resetTestHooks()
+
+	stdout := "Here is the plain answer.\nSecond line.\n"
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		return newFakeCmd(fakeCmdConfig{StdoutPlan: []fakeStdoutEvent{{Data: stdout}}})
+	})
+	codexCommand = "fake-cmd"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode != 0 || res.Error != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if want := "Here is the plain answer.\nSecond line."; res.Message != want {
+		t.Fatalf("message = %q, want %q", res.Message, want)
+	}
+
+	// Plain text never masks a non-zero exit.
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		return newFakeCmd(fakeCmdConfig{StdoutPlan: []fakeStdoutEvent{{Data: stdout}}, WaitErr: errors.New("boom")})
+	})
+	res = runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode == 0 {
+		t.Fatalf("expected failure, got %+v", res)
+	}
+}
+
+func TestRun_TimeoutFlag(t *testing.T) {
+	defer resetTestHooks()
+	t.Setenv("CODEX_TIMEOUT", "3600")
+
+	restore := withBackend("echo", nil)
+	defer restore()
+	stdinReader = strings.NewReader("")
+	isTerminalFn = func() bool { return true }
+
+	var gotTimeout int
+	runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+		gotTimeout = timeout
+		return TaskResult{Message: "done"}
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--timeout", "90", "task"}
+	var exitCode int
+	captureOutput(t, func() { exitCode = run() })
+	if exitCode != 0 || gotTimeout != 90 {
+		t.Fatalf("exit = %d, timeout = %d; want 0 and the --timeout value over CODEX_TIMEOUT", exitCode, gotTimeout)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "task"}
+	captureOutput(t, func() { exitCode = run() })
+	if exitCode != 0 || gotTimeout != 3600 {
+		t.Fatalf("exit = %d, timeout = %d; want 0 and CODEX_TIMEOUT without the flag", exitCode, gotTimeout)
+	}
+
+	os.Args = []string{"codeagent-wrapper", "--timeout", "0", "task"}
+	var stderr string
+	captureOutput(t, func() { stderr = captureStderr(t, func() { exitCode = run() }) })
+	if exitCode == 0 || !strings.Contains(stderr, "--timeout must be > 0") {
+		t.Fatalf("exit = %d, stderr = %q; want a --timeout validation error", exitCode, stderr)
+	}
+}
+
+func TestRun_ResumeLast(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	projectDir := t.TempDir()
+	otherDir := t.TempDir()
+
+	runCapture := func(t *testing.T, sessionID string, args ...string) (int, string, TaskSpec) {
+		t.Helper()
+		restore := withBackend("echo", func(cfg *Config, target string) []string { return []string{target} })
+		defer restore()
+		var got TaskSpec
+		runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+			got = ts
+			return TaskResult{Message: "done", SessionID: sessionID}
+		}
+		os.Args = append([]string{"codeagent-wrapper"}, args...)
+
+		var exitCode int
+		var stderr string
+		captureOutput(t, func() { stderr = captureStderr(t, func() { exitCode = run() }) })
+		return exitCode, stderr, got
+	}
+
+	defer resetTestHooks()
+
+	exitCode, stderr, _ := runCapture(t, "", "resume-last", "continue", projectDir)
+	if exitCode == 0 || !strings.Contains(stderr, "resume-last: no previous") {
+		t.Fatalf("exit = %d, stderr = %q; want a no-session error", exitCode, stderr)
+	}
+
+	for _, run := range []struct{ session, dir string }{
+		{"sess-old", projectDir},
+		{"sess-other", otherDir},
+		{"sess-new", projectDir},
+	} {
+		if exitCode, stderr, _ = runCapture(t, run.session, "task", run.dir); exitCode != 0 {
+			t.Fatalf("run %s exit = %d, stderr = %q", run.session, exitCode, stderr)
+		}
+	}
+
+	exitCode, stderr, got := runCapture(t, "sess-new", "resume-last", "continue", projectDir)
+	if exitCode != 0 {
+		t.Fatalf("resume-last exit = %d, stderr = %q", exitCode, stderr)
+	}
+	if got.Mode != "resume" || got.SessionID != "sess-new" || got.Task != "continue" || got.WorkDir != projectDir {
+		t.Fatalf("resumed task = %+v; want sess-new in %s", got, projectDir)
+	}
+
+	if _, _, got = runCapture(t, "sess-other", "resume-last", "continue", otherDir); got.SessionID != "sess-other" {
+		t.Fatalf("resume-last in other dir resumed %q, want sess-other", got.SessionID)
+	}
+
+	if exitCode, stderr, _ = runCapture(t, "", "resume-last"); exitCode == 0 || !strings.Contains(stderr, "resume-last mode requires") {
+		t.Fatalf("exit = %d, stderr = %q; want a usage error", exitCode, stderr)
+	}
+}
+
+func TestRun_ResumeLastCanonicalBackend(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	projectDir := t.TempDir()
+	defer resetTestHooks()
+
+	var got TaskSpec
+	runTaskFn = func(ts TaskSpec, silent bool, timeout int) TaskResult {
+		got = ts
+		return TaskResult{Message: "done", SessionID: "sess-claude"}
+	}
+	runWith := func(args ...string) (int, string) {
+		t.Helper()
+		os.Args = append([]string{"codeagent-wrapper"}, args...)
+		var exitCode int
+		var stderr string
+		captureOutput(t, func() { stderr = captureStderr(t, func() { exitCode = run() }) })
+		return exitCode, stderr
+	}
+
+	if exitCode, stderr := runWith("--backend", "claude", "task", projectDir); exitCode != 0 {
+		t.Fatalf("initial run exit = %d, stderr = %q", exitCode, stderr)
+	}
+	for _, spec := range []string{"Claude", "claude,codex"} {
+		got = TaskSpec{}
+		exitCode, stderr := runWith("--backend", spec, "resume-last", "continue", projectDir)
+		if exitCode != 0 {
+			t.Fatalf("--backend %s resume-last exit = %d, stderr = %q", spec, exitCode, stderr)
+		}
+		if got.Mode != "resume" || got.SessionID != "sess-claude" {
+			t.Fatalf("--backend %s resumed %+v, want sess-claude", spec, got)
+		}
+	}
+}
+
+func TestRunCodexTask_FailureIncludesStderrTail(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	scriptPath := filepath.Join(t.TempDir(), "fail.sh")
+	script := `#!/bin/sh
+echo "error: 401 Unauthorized - check your API key" >&2
+sleep 0.2
+exit 3
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	codexCommand = scriptPath
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return nil }
+
+	res := runCodexTask(TaskSpec{Task: "task"}, true, 5)
+	if res.ExitCode != 3 {
+		t.Fatalf("exit = %d, want 3 (%+v)", res.ExitCode, res)
+	}
+	if !strings.Contains(res.Error, "exited with status 3; stderr: error: 401 Unauthorized") {
+		t.Fatalf("Error = %q, want the stderr tail attached", res.Error)
+	}
+	if res.BackendExitCode == nil || *res.BackendExitCode != 3 {
+		t.Fatalf("BackendExitCode = %v, want 3", res.BackendExitCode)
+	}
+}
+
+func TestRun_CheckBackends(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	defer resetTestHooks()
+
+	dir := t.TempDir()
+	writeScript := func(name, body string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	codexBin := writeScript("codex", `if [ "$1" = "--version" ]; then echo "codex-cli 1.2.3"; exit 0; fi
+echo "Not logged in"; exit 1
+`)
+	claudeBin := writeScript("claude", "echo '2.0.0 (Claude Code)'\n")
+	t.Setenv("CODEX_BIN", codexBin)
+	t.Setenv("CLAUDE_BIN", claudeBin)
+	t.Setenv("GEMINI_BIN", filepath.Join(dir, "missing-gemini"))
+
+	check := func(args ...string) (int, string) {
+		os.Args = append([]string{"codeagent-wrapper", "--check"}, args...)
+		var code int
+		out := captureOutput(t, func() { code = run() })
+		return code, out
+	}
+
+	code, out := check("codex", "claude", "gemini")
+	if code != 1 {
+		t.Fatalf("exit = %d, want 1\n%s", code, out)
+	}
+	for _, want := range []string{
+		"codex: UNAUTHENTICATED (" + codexBin + ", codex-cli 1.2.3; ",
+		"Not logged in",
+		"claude: OK (" + claudeBin + ", 2.0.0 (Claude Code))",
+		"gemini: MISSING (command",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output = %q, want %q", out, want)
+		}
+	}
+
+	if code, out = check("claude"); code != 0 || strings.Contains(out, "codex") {
+		t.Fatalf("exit = %d, output = %q; want only claude, OK", code, out)
+	}
+	stderr := captureStderr(t, func() { code, _ = check("bogus") })
+	if code != 1 || !strings.Contains(stderr, "ERROR:") {
+		t.Fatalf("exit = %d, stderr = %q; want an unknown-backend error", code, stderr)
+	}
+}
+
+func TestRunCodexTask_AgentYoloBypassesSandbox(t *testing.T) {
+	defer resetTestHooks()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("CODEX_BYPASS_SANDBOX", "false")
+	t.Cleanup(config.ResetModelsConfigCacheForTest)
+	config.ResetModelsConfigCacheForTest()
+
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "agents": {
+    "yolo-dev": { "backend": "codex", "model": "gpt-test", "yolo": true },
+    "plain-dev": { "backend": "codex", "model": "gpt-test" }
+  }
+}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	parsed, err := parseParallelConfig([]byte("---TASK---\nid: t1\nagent: yolo-dev\n---CONTENT---\ndo something"))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() error = %v", err)
+	}
+	if !parsed.Tasks[0].Yolo {
+		t.Fatalf("task = %+v, want Yolo from the agent preset", parsed.Tasks[0])
+	}
+	parsed, err = parseParallelConfig([]byte(`[{"id": "t2", "task": "do something", "agent": "plain-dev", "yolo": true}]`))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() JSON error = %v", err)
+	}
+	if !parsed.Tasks[0].Yolo {
+		t.Fatalf("task = %+v, want the task's own yolo kept when the preset has none", parsed.Tasks[0])
+	}
+
+	var gotArgs []string
+	_ = executor.SetNewCommandRunner(func(ctx context.Context, name string, args ...string) executor.CommandRunner {
+		gotArgs = args
+		return newFakeCmd(fakeCmdConfig{StdoutPlan: []fakeStdoutEvent{
+			{Data: `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}` + "\n"},
+		}})
+	})
+	codexCommand = "fake-cmd"
+
+	for _, yolo := range []bool{true, false} {
+		res := runCodexTask(TaskSpec{Task: "task", Yolo: yolo}, true, 5)
+		if res.ExitCode != 0 {
+			t.Fatalf("yolo=%v: unexpected result %+v", yolo, res)
+		}
+		if got := slices.Contains(gotArgs, "--dangerously-bypass-approvals-and-sandbox"); got != yolo {
+			t.Fatalf("yolo=%v: args = %v, bypass flag present = %v", yolo, gotArgs, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package wrapper
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +16,13 @@ type outputSummary struct {
 	Failed  int `json:"failed"`
 }
 
+// statusPayload is the minimal --status-file document for CI dashboards.
+type statusPayload struct {
+	OK     bool `json:"ok"`
+	Total  int  `json:"total"`
+	Failed int  `json:"failed"`
+}
+
 type outputPayload struct {
 	Results []TaskResult  `json:"results"`
 	Summary outputSummary `json:"summary"`
@@ -37,10 +45,7 @@ func writeStructuredOutput(path string, results []TaskResult) error {
 		return fmt.Errorf("failed to create output file %q: %w", cleanPath, err)
 	}
 
-	encodeErr := json.NewEncoder(f).Encode(outputPayload{
-		Results: results,
-		Summary: summarizeResults(results),
-	})
+	encodeErr := encodeOutputPayload(f, results)
 	closeErr := f.Close()
 
 	if encodeErr != nil {
@@ -52,6 +57,74 @@ func writeStructuredOutput(path string, results []TaskResult) error {
 	return nil
 }
 
+// writeStatusFile writes {"ok","total","failed"} for results to path. The
+// file is written to a temporary sibling and renamed into place so readers
+// never observe a partial document.
+func writeStatusFile(path string, results []TaskResult) error {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil
+	}
+
+	summary := summarizeResults(results)
+	data, err := json.Marshal(statusPayload{
+		OK:     summary.Failed == 0,
+		Total:  summary.Total,
+		Failed: summary.Failed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode status file: %w", err)
+	}
+
+	cleanPath := filepath.Clean(path)
+	dir := filepath.Dir(cleanPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create status file directory for %q: %w", cleanPath, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(cleanPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create status file %q: %w", cleanPath, err)
+	}
+	_, writeErr := tmp.Write(append(data, '\n'))
+	closeErr := tmp.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmp.Name(), 0o644)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), cleanPath)
+	}
+	if writeErr != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write status file %q: %w", cleanPath, writeErr)
+	}
+	return nil
+}
+
+// encodeOutputPayload writes results and their summary as one JSON document
+// followed by a newline; shared by --output and --parallel --json.
+func encodeOutputPayload(w io.Writer, results []TaskResult) error {
+	return json.NewEncoder(w).Encode(outputPayload{
+		Results: results,
+		Summary: summarizeResults(results),
+	})
+}
+
+// capMessageForDisplay applies --max-message-lines to a message printed to
+// stdout. The full message is still written to outputPath, if any.
+func capMessageForDisplay(message string, maxLines int, outputPath string) string {
+	capped, more := truncateLines(message, maxLines)
+	if more == 0 {
+		return message
+	}
+	if strings.TrimSpace(outputPath) != "" {
+		return fmt.Sprintf("%s\n... (%d more lines, see %s)", capped, more, outputPath)
+	}
+	return fmt.Sprintf("%s\n... (%d more lines omitted; use --output to keep the full message)", capped, more)
+}
+
 func summarizeResults(results []TaskResult) outputSummary {
 	summary := outputSummary{Total: len(results)}
 	for _, res := range results {
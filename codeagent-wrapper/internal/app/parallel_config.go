@@ -7,3 +7,7 @@ import (
 func parseParallelConfig(data []byte) (*ParallelConfig, error) {
 	return executor.ParseParallelConfig(data)
 }
+
+func parseResumeList(data []byte) (*ParallelConfig, error) {
+	return executor.ParseResumeList(data)
+}
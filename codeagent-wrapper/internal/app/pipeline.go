@@ -0,0 +1,144 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// parsePipeline splits a --pipeline value ("explore,oracle") into validated
+// agent names.
+func parsePipeline(raw string) ([]string, error) {
+	var agents []string
+	for _, part := range strings.Split(raw, ",") {
+		name := strings.TrimSpace(part)
+		if name == "" {
+			return nil, fmt.Errorf("--pipeline: empty agent name in %q", raw)
+		}
+		if err := config.ValidateAgentName(name); err != nil {
+			return nil, fmt.Errorf("--pipeline: invalid agent %q: %w", name, err)
+		}
+		agents = append(agents, name)
+	}
+	return agents, nil
+}
+
+// pipelineStageTask builds the task for a pipeline stage: the original task
+// followed by the message of the previous stage.
+func pipelineStageTask(task, prevAgent, prevMessage string) string {
+	if prevAgent == "" {
+		return task
+	}
+	return fmt.Sprintf("%s\n\n# Output from previous agent (%s)\n\n%s", task, prevAgent, strings.TrimSpace(prevMessage))
+}
+
+// runPipelineMode implements --pipeline: each agent runs in turn on the same
+// task, receiving the previous agent's message as context. All stage results
+// are printed and written to --output/--status-file; the first failing stage
+// stops the pipeline.
+func runPipelineMode(cmd *cobra.Command, args []string, opts *cliOptions, v *viper.Viper, name string) int {
+	agents, err := parsePipeline(opts.Pipeline)
+	if err != nil {
+		logError(err.Error())
+		return 1
+	}
+	if cmd.Flags().Changed("agent") {
+		logError("--pipeline and --agent are mutually exclusive; list every agent in --pipeline")
+		return 1
+	}
+	if len(args) > 0 && (args[0] == "resume" || args[0] == "resume-last") {
+		logError("--pipeline cannot be combined with resume; each stage starts a new session")
+		return 1
+	}
+
+	var results []TaskResult
+	var baseTask, prevAgent, prevMessage, outputPath, statusFile string
+	maxMessageLines := 0
+	exitCode := 0
+	for i, agent := range agents {
+		if err := cmd.Flags().Set("agent", agent); err != nil {
+			logError(err.Error())
+			return 1
+		}
+		cfg, err := buildSingleConfig(cmd, args, nil, opts, v)
+		if err != nil {
+			logError(err.Error())
+			return 1
+		}
+
+		if i == 0 {
+			outputPath, statusFile = cfg.OutputPath, cfg.StatusFile
+			maxMessageLines = cfg.MaxMessageLines
+			// Read stdin once up front; later stages must not wait on it.
+			baseTask = cfg.Task
+			if cfg.ExplicitStdin {
+				data, err := io.ReadAll(stdinReader)
+				if err != nil {
+					logError("Failed to read stdin: " + err.Error())
+					return 1
+				}
+				baseTask = normalizeStdinEOL(string(data))
+			} else if piped, err := readPipedTask(); err != nil {
+				logError("Failed to read piped stdin: " + err.Error())
+				return 1
+			} else if piped != "" {
+				baseTask = piped
+			}
+			if strings.TrimSpace(baseTask) == "" {
+				logError("--pipeline requires a task")
+				return 1
+			}
+		}
+		cfg.ExplicitStdin = false
+		cfg.Task = pipelineStageTask(baseTask, prevAgent, prevMessage)
+		cfg.OutputPath = ""
+		cfg.StatusFile = ""
+		// Stage messages are printed together under their headers below.
+		cfg.StreamMessages = false
+
+		logInfo(fmt.Sprintf("Pipeline stage %d/%d: agent=%s, backend=%s", i+1, len(agents), agent, cfg.Backend))
+		result, code, ran := executeSingleTask(cfg, name)
+		if !ran {
+			return code
+		}
+		result.TaskID = agent
+		results = append(results, result)
+		if code != 0 {
+			logError(fmt.Sprintf("Pipeline stopped: stage %d/%d (%s) exited %d", i+1, len(agents), agent, code))
+			exitCode = code
+			break
+		}
+		recordSessionRun(cfg, result)
+		prevAgent, prevMessage = agent, result.Message
+	}
+
+	if err := writeStructuredOutput(outputPath, results); err != nil {
+		logError(err.Error())
+		return 1
+	}
+	if err := writeStatusFile(statusFile, results); err != nil {
+		logError(err.Error())
+		return 1
+	}
+
+	for i, result := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("=== Stage %d/%d: %s ===\n", i+1, len(agents), result.TaskID)
+		if strings.TrimSpace(result.Message) != "" {
+			fmt.Println(capMessageForDisplay(result.Message, maxMessageLines, outputPath))
+		} else if result.Error != "" {
+			fmt.Println("Error: " + result.Error)
+		}
+		if result.SessionID != "" {
+			fmt.Printf("SESSION_ID: %s\n", result.SessionID)
+		}
+	}
+	return exitCode
+}
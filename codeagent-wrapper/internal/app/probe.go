@@ -0,0 +1,52 @@
+package wrapper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// probeTask is the fixed prompt --probe sends to the backend.
+const probeTask = "Reply with exactly: OK"
+
+// runProbeMode implements --probe: it runs probeTask on the selected backend
+// and reports whether a non-empty message came back, with the elapsed time.
+func runProbeMode(cmd *cobra.Command, args []string, opts *cliOptions, v *viper.Viper, name string) int {
+	if len(args) > 0 {
+		logError("--probe sends its own task; remove the positional arguments")
+		return 1
+	}
+	cfg, err := buildSingleConfig(cmd, []string{probeTask}, nil, opts, v)
+	if err != nil {
+		logError(err.Error())
+		return 1
+	}
+
+	cfg.StreamMessages = false
+
+	start := time.Now()
+	result, exitCode, ran := executeSingleTask(cfg, name)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if !ran {
+		fmt.Printf("Probe %s: FAILED before the backend ran (%s)\n", cfg.Backend, elapsed)
+		return exitCode
+	}
+	if exitCode != 0 {
+		reason := strings.TrimSpace(result.Error)
+		if reason == "" {
+			reason = fmt.Sprintf("exit code %d", exitCode)
+		}
+		fmt.Printf("Probe %s: FAILED in %s: %s\n", cfg.Backend, elapsed, reason)
+		return exitCode
+	}
+
+	reply := strings.TrimSpace(result.Message)
+	if first, _, found := strings.Cut(reply, "\n"); found {
+		reply = first + " ..."
+	}
+	fmt.Printf("Probe %s: OK in %s (reply: %s)\n", cfg.Backend, elapsed, safeTruncate(reply, 80))
+	return 0
+}
@@ -0,0 +1,58 @@
+package wrapper
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// startupProfiler collects wrapper phase timings when --profile is set.
+// A nil profiler records nothing.
+var startupProfiler *phaseProfile
+
+type profilePhase struct {
+	name     string
+	duration time.Duration
+}
+
+// phaseProfile records how long each wrapper phase took so users can tell
+// wrapper overhead apart from backend latency.
+type phaseProfile struct {
+	start  time.Time
+	phases []profilePhase
+}
+
+func newPhaseProfile() *phaseProfile {
+	return &phaseProfile{start: time.Now()}
+}
+
+func (p *phaseProfile) record(name string, d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.phases = append(p.phases, profilePhase{name: name, duration: d})
+}
+
+// track starts timing a phase and returns a func that records it.
+func (p *phaseProfile) track(name string) func() {
+	if p == nil {
+		return func() {}
+	}
+	started := time.Now()
+	return func() { p.record(name, time.Since(started)) }
+}
+
+func (p *phaseProfile) writeReport(w io.Writer) {
+	if p == nil {
+		return
+	}
+	fmt.Fprintln(w, "[profile]")
+	for _, phase := range p.phases {
+		fmt.Fprintf(w, "  %-15s %s\n", phase.name+":", formatPhaseDuration(phase.duration))
+	}
+	fmt.Fprintf(w, "  %-15s %s\n", "total:", formatPhaseDuration(time.Since(p.start)))
+}
+
+func formatPhaseDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
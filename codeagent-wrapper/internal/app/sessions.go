@@ -0,0 +1,67 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// recordSessionRun remembers which directory a session ran in so later runs
+// can resume there (--resume-workdir). Registry failures only warn.
+func recordSessionRun(cfg *Config, result TaskResult) {
+	if strings.TrimSpace(result.SessionID) == "" {
+		return
+	}
+	workDir := result.WorkDir
+	if workDir == "" {
+		workDir = cfg.WorkDir
+		if abs, err := filepath.Abs(workDir); err == nil {
+			workDir = abs
+		}
+	}
+	rec := config.SessionRecord{
+		SessionID: result.SessionID,
+		WorkDir:   workDir,
+		Backend:   cfg.Backend,
+		UpdatedAt: time.Now(),
+	}
+	if err := config.RecordSession(rec); err != nil {
+		logWarn(fmt.Sprintf("Failed to record session %s: %v", result.SessionID, err))
+	}
+}
+
+// resolveLastSession returns the id of the most recent session recorded for
+// workDir and backend (resume-last mode).
+func resolveLastSession(workDir, backend string) (string, error) {
+	absDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return "", fmt.Errorf("resume-last: %w", err)
+	}
+	rec, ok, err := config.LatestSession(absDir, backend)
+	if err != nil {
+		return "", fmt.Errorf("resume-last: %w", err)
+	}
+	if !ok {
+		return "", fmt.Errorf("resume-last: no previous %s session recorded for %s; run a task there first or use resume <session_id>", backend, absDir)
+	}
+	return rec.SessionID, nil
+}
+
+// resolveResumeWorkdir returns the directory recorded for sessionID.
+func resolveResumeWorkdir(sessionID string) (string, error) {
+	rec, ok, err := config.LookupSession(sessionID)
+	if err != nil {
+		return "", fmt.Errorf("--resume-workdir: %w", err)
+	}
+	if !ok || strings.TrimSpace(rec.WorkDir) == "" {
+		return "", fmt.Errorf("--resume-workdir: no recorded workdir for session %q; pass the workdir explicitly", sessionID)
+	}
+	if info, err := os.Stat(rec.WorkDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("--resume-workdir: recorded workdir %s for session %q no longer exists", rec.WorkDir, sessionID)
+	}
+	return rec.WorkDir, nil
+}
@@ -0,0 +1,62 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultTaskJSONField = "prompt"
+
+// loadTaskFromJSON reads a JSON document and returns the string at field, a
+// dot-separated path such as "prompt" or "task.input.prompt". Numeric path
+// segments index into arrays ("steps.0.prompt").
+func loadTaskFromJSON(path, field string) (string, error) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		field = defaultTaskJSONField
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	current := doc
+	segments := strings.Split(field, ".")
+	for i, segment := range segments {
+		walked := strings.Join(segments[:i+1], ".")
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return "", fmt.Errorf("field %q not found in %s (missing %q)", field, path, walked)
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return "", fmt.Errorf("field %q not found in %s (no index %q in array of %d)", field, path, walked, len(node))
+			}
+			current = node[idx]
+		default:
+			return "", fmt.Errorf("field %q not found in %s (%q is not an object)", field, path, strings.Join(segments[:i], "."))
+		}
+	}
+
+	text, ok := current.(string)
+	if !ok {
+		return "", fmt.Errorf("field %q in %s is not a string", field, path)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("field %q in %s is empty", field, path)
+	}
+	return text, nil
+}
@@ -0,0 +1,67 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// templatePlaceholder matches {{name}} (surrounding spaces allowed).
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// parseTemplateVars turns repeated --var name=value flags into a map. Later
+// assignments of the same name win.
+func parseTemplateVars(assignments []string) (map[string]string, error) {
+	vars := make(map[string]string, len(assignments))
+	for _, assignment := range assignments {
+		name, value, ok := strings.Cut(assignment, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --var %q: want name=value", assignment)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// renderTaskTemplate reads the template at path and replaces every {{name}}
+// with its value from vars. Placeholders without a value are an error.
+func renderTaskTemplate(path string, vars map[string]string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := make(map[string]bool)
+	used := make(map[string]bool)
+	rendered := templatePlaceholder.ReplaceAllStringFunc(string(data), func(match string) string {
+		name := templatePlaceholder.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing[name] = true
+			return match
+		}
+		used[name] = true
+		return value
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("missing template variables in %s: %s (pass --var name=value)", path, strings.Join(names, ", "))
+	}
+	for name := range vars {
+		if !used[name] {
+			logWarn(fmt.Sprintf("--var %s is not used by template %s", name, path))
+		}
+	}
+	if strings.TrimSpace(rendered) == "" {
+		return "", fmt.Errorf("template %s is empty", path)
+	}
+	return rendered, nil
+}
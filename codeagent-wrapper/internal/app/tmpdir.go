@@ -57,6 +57,31 @@ func ensureExecutableTempDir() {
 	fmt.Fprintf(os.Stderr, "INFO: temp dir is not executable; set TMPDIR=%s\n", fallback)
 }
 
+// newLoggerWithFallback opens the wrapper log in the current temp dir. When
+// that fails it retries under ~/.codeagent/tmp, and as a last resort returns a
+// file-less logger that reports warnings on stderr so the run can continue.
+func newLoggerWithFallback() *Logger {
+	logger, err := NewLogger()
+	if err == nil {
+		return logger
+	}
+
+	if fallback := defaultFallbackTempDir(); fallback != "" && fallback != filepath.Clean(os.TempDir()) {
+		if mkErr := os.MkdirAll(fallback, 0o700); mkErr == nil {
+			prev := currentTempDirFromEnv()
+			setTempEnv(fallback)
+			if logger, fbErr := NewLogger(); fbErr == nil {
+				fmt.Fprintf(os.Stderr, "WARN: failed to create log file (%v); using %s\n", err, fallback)
+				return logger
+			}
+			setTempEnv(prev)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "WARN: failed to create log file (%v); logging warnings to stderr only\n", err)
+	return newFallbackLogger()
+}
+
 func setTempEnv(dir string) {
 	_ = os.Setenv("TMPDIR", dir)
 	_ = os.Setenv("TMP", dir)
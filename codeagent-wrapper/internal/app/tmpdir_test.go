@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -81,6 +82,67 @@ func TestEnsureExecutableTempDir_FallbackWhenCurrentNotExecutable(t *testing.T)
 	}
 }
 
+func TestNewLoggerWithFallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("temp dir fallback relies on TMPDIR semantics")
+	}
+
+	// A regular file in place of the temp dir makes the log file unwritable.
+	blocked := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocked, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("home tmp", func(t *testing.T) {
+		restore := captureTempEnv()
+		t.Cleanup(restore)
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("USERPROFILE", home)
+		t.Setenv("TMPDIR", blocked)
+
+		var logger *Logger
+		stderr := captureStderr(t, func() { logger = newLoggerWithFallback() })
+		defer logger.Close()
+
+		fallback := filepath.Join(home, ".codeagent", "tmp")
+		if got := filepath.Dir(logger.Path()); got != fallback {
+			t.Fatalf("log dir = %q, want %q", got, fallback)
+		}
+		if !strings.Contains(stderr, "using "+fallback) {
+			t.Fatalf("stderr missing fallback notice: %q", stderr)
+		}
+	})
+
+	t.Run("stderr only", func(t *testing.T) {
+		restore := captureTempEnv()
+		t.Cleanup(restore)
+		t.Setenv("HOME", blocked)
+		t.Setenv("USERPROFILE", blocked)
+		t.Setenv("TMPDIR", blocked)
+
+		stderr := captureStderr(t, func() {
+			logger := newLoggerWithFallback()
+			if logger.Path() != "" {
+				t.Errorf("fallback logger path = %q, want empty", logger.Path())
+			}
+			logger.Info("quiet")
+			logger.Warn("still visible")
+			logger.Flush()
+			if err := logger.Close(); err != nil {
+				t.Errorf("Close() = %v", err)
+			}
+		})
+
+		if !strings.Contains(stderr, "logging warnings to stderr only") {
+			t.Fatalf("stderr missing degradation notice: %q", stderr)
+		}
+		if !strings.Contains(stderr, "[WARN] still visible") || strings.Contains(stderr, "quiet") {
+			t.Fatalf("unexpected fallback output: %q", stderr)
+		}
+	})
+}
+
 func captureTempEnv() func() {
 	type entry struct {
 		set bool
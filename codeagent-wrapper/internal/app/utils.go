@@ -5,28 +5,59 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
+	config "codeagent-wrapper/internal/config"
 	utils "codeagent-wrapper/internal/utils"
 )
 
-func resolveTimeout() int {
+// resolveTimeoutSource returns the task timeout in seconds and where it came
+// from: flagValue (already validated, from --timeout) when positive, then
+// CODEX_TIMEOUT, then defaultTimeout.
+func resolveTimeoutSource(flagValue int) (int, string) {
+	if flagValue > 0 {
+		return normalizeTimeout(flagValue), "--timeout"
+	}
+
 	raw := os.Getenv("CODEX_TIMEOUT")
 	if raw == "" {
-		return defaultTimeout
+		return defaultTimeout, "default"
 	}
 
 	parsed, err := strconv.Atoi(raw)
 	if err != nil || parsed <= 0 {
 		logWarn(fmt.Sprintf("Invalid CODEX_TIMEOUT '%s', falling back to %ds", raw, defaultTimeout))
-		return defaultTimeout
+		return defaultTimeout, "default"
+	}
+	return normalizeTimeout(parsed), "CODEX_TIMEOUT"
+}
+
+// normalizeTimeout reads values above 10000 as milliseconds.
+func normalizeTimeout(value int) int {
+	if value > 10000 {
+		return value / 1000
 	}
+	return value
+}
 
-	if parsed > 10000 {
-		return parsed / 1000
+// resolveRunTimeout returns the wall-clock bound for a whole --parallel run
+// from CODEAGENT_RUN_TIMEOUT, in seconds (values above 10000 are read as
+// milliseconds, as for CODEX_TIMEOUT). Zero means no bound; unset, zero or
+// invalid values disable it.
+func resolveRunTimeout() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_RUN_TIMEOUT"))
+	if raw == "" {
+		return 0
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed < 0 {
+		logWarn(fmt.Sprintf("Invalid CODEAGENT_RUN_TIMEOUT '%s', running without an overall bound", raw))
+		return 0
 	}
-	return parsed
+	return time.Duration(normalizeTimeout(parsed)) * time.Second
 }
 
 func readPipedTask() (string, error) {
@@ -44,14 +75,29 @@ func readPipedTask() (string, error) {
 		return "", nil
 	}
 	logInfo(fmt.Sprintf("Read %d bytes from stdin pipe", len(data)))
-	return string(data), nil
+	return normalizeStdinEOL(string(data)), nil
+}
+
+// normalizeStdinEOL converts CRLF line endings in task text read from stdin
+// to LF, so a Windows pipe does not leave a '\r' at the end of every prompt
+// line. Only "\r\n" pairs change; a lone '\r' is kept. It applies on Windows
+// by default; CODEAGENT_NORMALIZE_EOL=1 turns it on elsewhere and =0 off.
+func normalizeStdinEOL(text string) string {
+	enabled := runtime.GOOS == "windows"
+	if _, set := os.LookupEnv("CODEAGENT_NORMALIZE_EOL"); set {
+		enabled = config.EnvFlagEnabled("CODEAGENT_NORMALIZE_EOL")
+	}
+	if !enabled || !strings.Contains(text, "\r\n") {
+		return text
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n")
 }
 
 func shouldUseStdin(taskText string, piped bool) bool {
 	if piped {
 		return true
 	}
-	if len(taskText) > 800 {
+	if len(taskText) > stdinThreshold() {
 		return true
 	}
 	return strings.ContainsAny(taskText, stdinSpecialChars)
@@ -211,6 +257,20 @@ func sanitizeOutput(s string) string {
 	return utils.SanitizeOutput(s)
 }
 
+// redactSecrets masks API keys, tokens and JWTs in s.
+func redactSecrets(s string) string {
+	return utils.RedactSecrets(s)
+}
+
+func truncateLines(s string, maxLines int) (string, int) {
+	return utils.TruncateLines(s, maxLines)
+}
+
+// stripControlSequences removes TTY control sequences and redraws.
+func stripControlSequences(s string) string {
+	return utils.StripControlSequences(s)
+}
+
 func min(a, b int) int {
 	return utils.Min(a, b)
 }
@@ -0,0 +1,47 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+
+	backend "codeagent-wrapper/internal/backend"
+)
+
+// versionInfo is the --version --json document consumed by CI health checks.
+type versionInfo struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Go       string   `json:"go"`
+	Backends []string `json:"backends"`
+}
+
+// printVersion writes "<name> version <version>", or with asJSON the build
+// metadata including every registered backend.
+func printVersion(w io.Writer, name string, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintf(w, "%s version %s\n", name, version)
+		return err
+	}
+
+	registry := backend.Registry()
+	names := make([]string, 0, len(registry))
+	for backendName := range registry {
+		names = append(names, backendName)
+	}
+	sort.Strings(names)
+
+	data, err := json.Marshal(versionInfo{
+		Name:     name,
+		Version:  version,
+		Go:       runtime.Version(),
+		Backends: names,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,56 @@
+package wrapper
+
+import (
+	"fmt"
+	"os"
+
+	"codeagent-wrapper/internal/worktree"
+)
+
+// staleWorktreesArg makes --worktree-cleanup sweep every stale worktree
+// instead of removing a single task's.
+const staleWorktreesArg = "stale"
+
+// cleanupOldWorktrees removes the .worktrees/do-* checkouts in projectDir's
+// repository whose creating process is gone or whose branch is merged.
+func cleanupOldWorktrees(projectDir string) (CleanupStats, error) {
+	return worktree.CleanupStale(projectDir)
+}
+
+// runWorktreeCleanupMode implements --worktree-cleanup <task_id> [workdir]:
+// it removes the .worktrees/do-<task_id> checkout left by a --worktree run
+// and deletes its do/<task_id> branch when that branch is fully merged.
+// --worktree-cleanup stale [workdir] sweeps all stale worktrees instead.
+func runWorktreeCleanupMode(taskID string, args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "ERROR: --worktree-cleanup takes a task id and an optional workdir")
+		return 1
+	}
+	projectDir := defaultWorkdir
+	if len(args) == 1 {
+		projectDir = args[0]
+	}
+
+	if taskID == staleWorktreesArg {
+		stats, err := cleanupOldWorktrees(projectDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		fmt.Println("Worktree cleanup completed")
+		printCleanupStats("Worktrees", stats)
+		return 0
+	}
+
+	paths, err := worktree.FindWorktree(projectDir, taskID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if err := worktree.RemoveWorktree(paths, true); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed worktree %s and branch %s\n", paths.Dir, paths.Branch)
+	return 0
+}
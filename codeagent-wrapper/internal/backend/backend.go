@@ -12,6 +12,21 @@ type Backend interface {
 	Env(baseURL, apiKey string) map[string]string
 }
 
+// MessagePostProcessor is implemented by backends that need to clean up the
+// final agent message before it is returned to the caller.
+type MessagePostProcessor interface {
+	PostProcess(msg string) string
+}
+
+// PostProcessMessage applies the backend's PostProcess hook when available and
+// returns msg unchanged otherwise.
+func PostProcessMessage(b Backend, msg string) string {
+	if p, ok := b.(MessagePostProcessor); ok && msg != "" {
+		return p.PostProcess(msg)
+	}
+	return msg
+}
+
 var (
 	logWarnFn  = func(string) {}
 	logErrorFn = func(string) {}
@@ -2,9 +2,12 @@ package backend
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	config "codeagent-wrapper/internal/config"
@@ -104,6 +107,105 @@ func TestBackendBuildArgs_Model(t *testing.T) {
 	})
 }
 
+func TestBackendBuildArgs_ModelModes(t *testing.T) {
+	t.Setenv("CODEAGENT_SKIP_PERMISSIONS", "false")
+	t.Setenv("CODEX_BYPASS_SANDBOX", "false")
+
+	tests := []struct {
+		name    string
+		backend Backend
+		cfg     config.Config
+		want    []string
+	}{
+		{
+			name:    "codex new",
+			backend: CodexBackend{},
+			cfg:     config.Config{Mode: "new", WorkDir: "/tmp", Model: "o3"},
+			want:    []string{"e", "--model", "o3", "--skip-git-repo-check", "-C", "/tmp", "--json", "task"},
+		},
+		{
+			name:    "codex resume",
+			backend: CodexBackend{},
+			cfg:     config.Config{Mode: "resume", SessionID: "sid-1", Model: "o3"},
+			want:    []string{"e", "--model", "o3", "--skip-git-repo-check", "--json", "resume", "sid-1", "task"},
+		},
+		{
+			name:    "codex resume without model",
+			backend: CodexBackend{},
+			cfg:     config.Config{Mode: "resume", SessionID: "sid-1"},
+			want:    []string{"e", "--skip-git-repo-check", "--json", "resume", "sid-1", "task"},
+		},
+		{
+			name:    "claude new",
+			backend: ClaudeBackend{},
+			cfg:     config.Config{Mode: "new", Model: "opus"},
+			want:    []string{"-p", "--setting-sources", "", "--model", "opus", "--output-format", "stream-json", "--verbose", "task"},
+		},
+		{
+			name:    "claude resume",
+			backend: ClaudeBackend{},
+			cfg:     config.Config{Mode: "resume", SessionID: "sid-2", Model: "opus"},
+			want:    []string{"-p", "--setting-sources", "", "--model", "opus", "-r", "sid-2", "--output-format", "stream-json", "--verbose", "task"},
+		},
+		{
+			name:    "gemini new",
+			backend: GeminiBackend{},
+			cfg:     config.Config{Mode: "new", Model: "gemini-3-pro-preview"},
+			want:    []string{"-o", "stream-json", "-y", "-m", "gemini-3-pro-preview", "task"},
+		},
+		{
+			name:    "gemini resume",
+			backend: GeminiBackend{},
+			cfg:     config.Config{Mode: "resume", SessionID: "sid-3", Model: "gemini-3-pro-preview"},
+			want:    []string{"-o", "stream-json", "-y", "-m", "gemini-3-pro-preview", "-r", "sid-3", "task"},
+		},
+		{
+			name:    "gemini resume without model",
+			backend: GeminiBackend{},
+			cfg:     config.Config{Mode: "resume", SessionID: "sid-3"},
+			want:    []string{"-o", "stream-json", "-y", "-r", "sid-3", "task"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			got := tt.backend.BuildArgs(&cfg, "task")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodexReasoningEffort(t *testing.T) {
+	var warnings []string
+	SetLogFuncs(func(msg string) { warnings = append(warnings, msg) }, nil)
+	t.Cleanup(func() { SetLogFuncs(nil, nil) })
+	t.Setenv("CODEX_BYPASS_SANDBOX", "false")
+
+	for raw, want := range map[string]string{"": "", "high": "high", " Medium ": "medium", "LOW": "low", "extreme": ""} {
+		if got := CodexReasoningEffort(raw); got != want {
+			t.Errorf("CodexReasoningEffort(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"extreme"`) {
+		t.Fatalf("warnings = %q, want one for the unknown value", warnings)
+	}
+
+	cfg := &config.Config{Mode: "new", WorkDir: "/tmp", ReasoningEffort: "extreme"}
+	got := CodexBackend{}.BuildArgs(cfg, "task")
+	want := []string{"e", "--skip-git-repo-check", "-C", "/tmp", "--json", "task"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unknown effort should be dropped: got %q, want %q", got, want)
+	}
+	cfg.ReasoningEffort = "High"
+	got = CodexBackend{}.BuildArgs(cfg, "task")
+	want = []string{"e", "-c", "model_reasoning_effort=high", "--skip-git-repo-check", "-C", "/tmp", "--json", "task"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
 func TestClaudeBuildArgs_GeminiAndCodexModes(t *testing.T) {
 	t.Run("gemini new mode defaults workdir", func(t *testing.T) {
 		backend := GeminiBackend{}
@@ -179,6 +281,36 @@ func TestClaudeBuildArgs_GeminiAndCodexModes(t *testing.T) {
 	})
 }
 
+func TestClaudeBuildArgs_AllowFile(t *testing.T) {
+	t.Setenv("CODEAGENT_SKIP_PERMISSIONS", "false")
+	cfg := &config.Config{Mode: "new", WorkDir: "/repo", ClaudeAllowFile: "/etc/claude-allow.json"}
+	got := ClaudeBackend{}.BuildArgs(cfg, "todo")
+	want := []string{"-p", "--setting-sources", "", "--settings", "/etc/claude-allow.json", "--output-format", "stream-json", "--verbose", "todo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Other backends ignore the claude-specific settings file.
+	if args := (CodexBackend{}).BuildArgs(cfg, "todo"); slices.Contains(args, "--settings") {
+		t.Fatalf("codex args should not include --settings, got %v", args)
+	}
+}
+
+func TestClaudeBuildArgs_SystemPrompt(t *testing.T) {
+	t.Setenv("CODEAGENT_SKIP_PERMISSIONS", "false")
+	cfg := &config.Config{Mode: "resume", SessionID: "sid", SystemPrompt: "You are the reviewer."}
+	got := ClaudeBackend{}.BuildArgs(cfg, "-")
+	want := []string{"-p", "--setting-sources", "", "-r", "sid", "--append-system-prompt", "You are the reviewer.", "--output-format", "stream-json", "--verbose", "-"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	cfg.SystemPrompt = "  "
+	if got := (ClaudeBackend{}).BuildArgs(cfg, "-"); slices.Contains(got, "--append-system-prompt") {
+		t.Fatalf("blank system prompt should be omitted, got %v", got)
+	}
+}
+
 func TestClaudeBuildArgs_BackendMetadata(t *testing.T) {
 	tests := []struct {
 		backend Backend
@@ -320,3 +452,132 @@ func TestOpencodeBackend_Interface(t *testing.T) {
 		t.Errorf("Command() = %q, want %q", backend.Command(), "opencode")
 	}
 }
+
+func TestPostProcessMessage(t *testing.T) {
+	t.Run("gemini cleans control markers and blank runs", func(t *testing.T) {
+		raw := "Hello\x1b  \n\n\n\nworld\x00\t \n"
+		got := PostProcessMessage(GeminiBackend{}, raw)
+		if want := "Hello\n\nworld"; got != want {
+			t.Fatalf("PostProcessMessage(gemini) = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("default backends leave message unchanged", func(t *testing.T) {
+		raw := "keep  \n\n\n\x1bas-is "
+		for _, b := range []Backend{CodexBackend{}, ClaudeBackend{}, OpencodeBackend{}} {
+			if got := PostProcessMessage(b, raw); got != raw {
+				t.Fatalf("PostProcessMessage(%s) = %q, want %q", b.Name(), got, raw)
+			}
+		}
+	})
+
+	t.Run("nil backend is identity", func(t *testing.T) {
+		if got := PostProcessMessage(nil, "msg"); got != "msg" {
+			t.Fatalf("PostProcessMessage(nil) = %q, want %q", got, "msg")
+		}
+	})
+}
+
+func TestSelectList(t *testing.T) {
+	names := func(backends []Backend) []string {
+		var out []string
+		for _, b := range backends {
+			out = append(out, b.Name())
+		}
+		return out
+	}
+
+	t.Run("drops empty tokens", func(t *testing.T) {
+		for spec, want := range map[string][]string{
+			"claude,,codex":        {"claude", "codex"},
+			" , Claude ,":          {"claude"},
+			"gemini,claude,gemini": {"gemini", "claude"},
+		} {
+			got, err := SelectList(spec)
+			if err != nil {
+				t.Fatalf("SelectList(%q) error: %v", spec, err)
+			}
+			if !slices.Equal(names(got), want) {
+				t.Fatalf("SelectList(%q) = %v, want %v", spec, names(got), want)
+			}
+		}
+	})
+
+	t.Run("all empty", func(t *testing.T) {
+		for _, spec := range []string{",,", " , ", ","} {
+			if _, err := SelectList(spec); err == nil || !strings.Contains(err.Error(), "contains no backend names") {
+				t.Fatalf("SelectList(%q) err = %v, want empty-list error", spec, err)
+			}
+			if _, err := Select(spec); err == nil {
+				t.Fatalf("Select(%q) expected error", spec)
+			}
+		}
+	})
+
+	t.Run("mixed valid and invalid", func(t *testing.T) {
+		_, err := SelectList("claude,,codx,codex")
+		if err == nil || !strings.Contains(err.Error(), `"codx"`) {
+			t.Fatalf("SelectList() err = %v, want error naming codx", err)
+		}
+	})
+
+	t.Run("select picks first installed", func(t *testing.T) {
+		orig := lookPathFn
+		t.Cleanup(func() { lookPathFn = orig })
+		lookPathFn = func(file string) (string, error) {
+			if file == "codex" {
+				return "/usr/bin/codex", nil
+			}
+			return "", errors.New("not found")
+		}
+
+		got, err := Select("claude,,codex")
+		if err != nil {
+			t.Fatalf("Select() error: %v", err)
+		}
+		if got.Name() != "codex" {
+			t.Fatalf("Select() = %s, want codex", got.Name())
+		}
+
+		lookPathFn = func(string) (string, error) { return "", errors.New("not found") }
+		got, err = Select("gemini,claude")
+		if err != nil {
+			t.Fatalf("Select() error: %v", err)
+		}
+		if got.Name() != "gemini" {
+			t.Fatalf("Select() with nothing installed = %s, want first entry gemini", got.Name())
+		}
+	})
+}
+
+func TestResolveCommand(t *testing.T) {
+	t.Setenv("CODEX_BIN", "/opt/codex-1.2/bin/codex")
+	t.Setenv("CLAUDE_BIN", "   ")
+	t.Setenv("GEMINI_BIN", "")
+
+	for command, want := range map[string]string{
+		"codex":    "/opt/codex-1.2/bin/codex",
+		"claude":   "claude",
+		"gemini":   "gemini",
+		"opencode": "opencode",
+		"echo":     "echo",
+	} {
+		if got := ResolveCommand(command); got != want {
+			t.Errorf("ResolveCommand(%q) = %q, want %q", command, got, want)
+		}
+	}
+
+	orig := lookPathFn
+	t.Cleanup(func() { lookPathFn = orig })
+	var looked []string
+	lookPathFn = func(file string) (string, error) {
+		looked = append(looked, file)
+		return "", errors.New("not found")
+	}
+	if _, err := Select("codex,gemini"); err != nil {
+		t.Fatalf("Select() error: %v", err)
+	}
+	if !slices.Equal(looked, []string{"/opt/codex-1.2/bin/codex", "gemini"}) {
+		t.Fatalf("fallback chain looked up %v, want the CODEX_BIN override first", looked)
+	}
+}
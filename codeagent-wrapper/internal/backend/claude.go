@@ -123,6 +123,12 @@ func buildClaudeArgs(cfg *config.Config, targetArg string) []string {
 	// This ensures a clean execution environment without CLAUDE.md or skills that would trigger codeagent
 	args = append(args, "--setting-sources", "")
 
+	// A curated settings file re-enables specific permissions without
+	// re-enabling the user/project sources that would cause recursion.
+	if allowFile := strings.TrimSpace(cfg.ClaudeAllowFile); allowFile != "" {
+		args = append(args, "--settings", allowFile)
+	}
+
 	if model := strings.TrimSpace(cfg.Model); model != "" {
 		args = append(args, "--model", model)
 	}
@@ -143,6 +149,10 @@ func buildClaudeArgs(cfg *config.Config, targetArg string) []string {
 		args = append(args, cfg.DisallowedTools...)
 	}
 
+	if systemPrompt := strings.TrimSpace(cfg.SystemPrompt); systemPrompt != "" {
+		args = append(args, "--append-system-prompt", systemPrompt)
+	}
+
 	args = append(args, "--output-format", "stream-json", "--verbose", targetArg)
 
 	return args
@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"fmt"
 	"strings"
 
 	config "codeagent-wrapper/internal/config"
@@ -29,6 +30,26 @@ func (CodexBackend) BuildArgs(cfg *config.Config, targetArg string) []string {
 	return BuildCodexArgs(cfg, targetArg)
 }
 
+// codexReasoningEfforts are the model_reasoning_effort values Codex accepts.
+var codexReasoningEfforts = []string{"minimal", "low", "medium", "high", "xhigh"}
+
+// CodexReasoningEffort normalizes a reasoning effort for Codex. Unknown
+// values are dropped with a warning so Codex runs with its default instead
+// of rejecting the config override.
+func CodexReasoningEffort(raw string) string {
+	effort := strings.ToLower(strings.TrimSpace(raw))
+	if effort == "" {
+		return ""
+	}
+	for _, allowed := range codexReasoningEfforts {
+		if effort == allowed {
+			return effort
+		}
+	}
+	logWarnFn(fmt.Sprintf("Ignoring unknown reasoning effort %q (expected one of %s)", raw, strings.Join(codexReasoningEfforts, ", ")))
+	return ""
+}
+
 func BuildCodexArgs(cfg *config.Config, targetArg string) []string {
 	if cfg == nil {
 		panic("buildCodexArgs: nil config")
@@ -56,7 +77,7 @@ func BuildCodexArgs(cfg *config.Config, targetArg string) []string {
 		args = append(args, "--model", model)
 	}
 
-	if reasoningEffort := strings.TrimSpace(cfg.ReasoningEffort); reasoningEffort != "" {
+	if reasoningEffort := CodexReasoningEffort(cfg.ReasoningEffort); reasoningEffort != "" {
 		args = append(args, "-c", "model_reasoning_effort="+reasoningEffort)
 	}
 
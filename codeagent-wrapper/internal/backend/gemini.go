@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 
 	config "codeagent-wrapper/internal/config"
 )
@@ -31,6 +32,37 @@ func (GeminiBackend) BuildArgs(cfg *config.Config, targetArg string) []string {
 	return buildGeminiArgs(cfg, targetArg)
 }
 
+// PostProcess cleans up artifacts of Gemini's concatenated deltas: stray
+// control characters, trailing whitespace and runs of blank lines.
+func (GeminiBackend) PostProcess(msg string) string {
+	msg = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, msg)
+
+	lines := strings.Split(msg, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
 // LoadGeminiEnv loads environment variables from ~/.gemini/.env
 // Supports GEMINI_API_KEY, GEMINI_MODEL, GOOGLE_GEMINI_BASE_URL
 // Also sets GEMINI_API_KEY_AUTH_MECHANISM=bearer for third-party API compatibility
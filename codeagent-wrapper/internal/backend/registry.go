@@ -2,6 +2,8 @@ package backend
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"strings"
 )
 
@@ -12,12 +14,36 @@ var registry = map[string]Backend{
 	"opencode": OpencodeBackend{},
 }
 
+// commandEnvOverrides maps each backend's default command to the environment
+// variable that can point it at another binary (a versioned name or an
+// absolute path).
+var commandEnvOverrides = map[string]string{
+	"codex":    "CODEX_BIN",
+	"claude":   "CLAUDE_BIN",
+	"gemini":   "GEMINI_BIN",
+	"opencode": "OPENCODE_BIN",
+}
+
+// lookPathFn resolves backend executables when picking from a fallback chain.
+var lookPathFn = exec.LookPath
+
 // Registry exposes the available backends. Intended for internal inspection/tests.
 func Registry() map[string]Backend {
 	return registry
 }
 
+// Select returns the backend for name. A comma-separated name is treated as a
+// fallback chain (see SelectList) and resolves to the first backend whose
+// command is installed, or the first entry when none are.
 func Select(name string) (Backend, error) {
+	if strings.Contains(name, ",") {
+		backends, err := SelectList(name)
+		if err != nil {
+			return nil, err
+		}
+		return firstAvailable(backends), nil
+	}
+
 	key := strings.ToLower(strings.TrimSpace(name))
 	if key == "" {
 		key = "codex"
@@ -27,3 +53,68 @@ func Select(name string) (Backend, error) {
 	}
 	return nil, fmt.Errorf("unsupported backend %q", name)
 }
+
+// SelectList parses a comma-separated backend chain such as "claude,codex".
+// Entries are trimmed and empty entries are dropped, so "claude,,codex" is
+// equivalent to "claude,codex"; duplicates keep their first position. A list
+// with no names, or with an unknown name, is rejected.
+func SelectList(spec string) ([]Backend, error) {
+	var backends []Backend
+	seen := make(map[string]struct{})
+	for _, token := range strings.Split(spec, ",") {
+		key := strings.ToLower(strings.TrimSpace(token))
+		if key == "" {
+			continue
+		}
+		backend, ok := registry[key]
+		if !ok {
+			return nil, fmt.Errorf("unsupported backend %q in backend list %q", strings.TrimSpace(token), spec)
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		backends = append(backends, backend)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("backend list %q contains no backend names", spec)
+	}
+	return backends, nil
+}
+
+// ResolveCommand returns the executable to launch for a backend command:
+// the value of its *_BIN override when set (e.g. CODEX_BIN for "codex"),
+// otherwise command itself. Command() keeps returning the canonical name so
+// callers can still tell backends apart.
+func ResolveCommand(command string) string {
+	key, ok := commandEnvOverrides[command]
+	if !ok {
+		return command
+	}
+	value, set := os.LookupEnv(key)
+	if !set {
+		return command
+	}
+	if override := strings.TrimSpace(value); override != "" {
+		return override
+	}
+	logWarnFn(fmt.Sprintf("%s is set but empty; using %q", key, command))
+	return command
+}
+
+// CommandEnvOverride returns the *_BIN variable that can replace command
+// (e.g. CODEX_BIN for "codex"), or "" when command has none.
+func CommandEnvOverride(command string) string {
+	return commandEnvOverrides[command]
+}
+
+func firstAvailable(backends []Backend) Backend {
+	for _, backend := range backends {
+		command := ResolveCommand(backend.Command())
+		if _, err := lookPathFn(command); err == nil {
+			return backend
+		}
+		logWarnFn(fmt.Sprintf("backend %s: command %q not found on PATH", backend.Name(), command))
+	}
+	return backends[0]
+}
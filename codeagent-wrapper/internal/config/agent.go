@@ -11,8 +11,20 @@ import (
 )
 
 type BackendConfig struct {
-	BaseURL string `json:"base_url,omitempty"`
-	APIKey  string `json:"api_key,omitempty"`
+	BaseURL   string            `json:"base_url,omitempty"`
+	APIKey    string            `json:"api_key,omitempty"`
+	Extractor *MessageExtractor `json:"extractor,omitempty"`
+}
+
+// MessageExtractor describes how to pull the final message (and optionally
+// the session id) out of a backend's JSON events when the parser has no
+// built-in support for its format. Paths are dot-separated JSON paths.
+type MessageExtractor struct {
+	MessageType  string `json:"message_type"`
+	MessagePath  string `json:"message_path"`
+	SessionPath  string `json:"session_path,omitempty"`
+	CompleteType string `json:"complete_type,omitempty"`
+	Append       bool   `json:"append,omitempty"`
 }
 
 type AgentModelConfig struct {
@@ -39,6 +51,10 @@ var defaultModelsConfig = ModelsConfig{}
 
 const modelsConfigTildePath = "~/.codeagent/models.json"
 
+// modelsConfigEnv names a models.json to read instead of the home-dir one,
+// e.g. a project-local file shared through the repository.
+const modelsConfigEnv = "CODEAGENT_MODELS_CONFIG"
+
 const modelsConfigExample = `{
   "default_backend": "codex",
   "default_model": "gpt-4.1",
@@ -57,6 +73,17 @@ const modelsConfigExample = `{
   }
 }`
 
+var logWarnFn = func(string) {}
+
+// SetLogWarnFunc configures the hook used to report recoverable config
+// problems. Callers can safely pass nil to disable it.
+func SetLogWarnFunc(warnFn func(string)) {
+	if warnFn == nil {
+		warnFn = func(string) {}
+	}
+	logWarnFn = warnFn
+}
+
 var (
 	modelsConfigOnce   sync.Once
 	modelsConfigCached *ModelsConfig
@@ -71,6 +98,10 @@ func modelsConfig() (*ModelsConfig, error) {
 }
 
 func modelsConfigPath() (string, error) {
+	if path, ok := modelsConfigOverride(); ok {
+		return path, nil
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil || strings.TrimSpace(home) == "" {
 		return "", fmt.Errorf("failed to resolve user home directory: %w", err)
@@ -85,8 +116,28 @@ func modelsConfigPath() (string, error) {
 	return configPath, nil
 }
 
+// modelsConfigOverride returns the absolute path named by
+// CODEAGENT_MODELS_CONFIG when it is an existing file.
+func modelsConfigOverride() (string, bool) {
+	raw := strings.TrimSpace(os.Getenv(modelsConfigEnv))
+	if raw == "" {
+		return "", false
+	}
+	path, err := filepath.Abs(raw)
+	if err != nil {
+		return "", false
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
 func modelsConfigHint(configPath string) string {
 	configPath = strings.TrimSpace(configPath)
+	if override, ok := modelsConfigOverride(); ok && configPath == override {
+		return fmt.Sprintf("Fix %s (set via %s), e.g.:\n%s", configPath, modelsConfigEnv, modelsConfigExample)
+	}
 	if configPath == "" {
 		return fmt.Sprintf("Create %s with e.g.:\n%s", modelsConfigTildePath, modelsConfigExample)
 	}
@@ -94,12 +145,18 @@ func modelsConfigHint(configPath string) string {
 }
 
 func loadModelsConfig() (*ModelsConfig, error) {
+	if raw := strings.TrimSpace(os.Getenv(modelsConfigEnv)); raw != "" {
+		if _, ok := modelsConfigOverride(); !ok {
+			logWarnFn(fmt.Sprintf("%s=%s is not an existing file; falling back to %s", modelsConfigEnv, raw, modelsConfigTildePath))
+		}
+	}
+
 	configPath, err := modelsConfigPath()
 	if err != nil {
 		return nil, fmt.Errorf("%w\n\n%s", err, modelsConfigHint(""))
 	}
 
-	data, err := os.ReadFile(configPath) // #nosec G304 -- path is fixed under user home and validated to stay within configDir
+	data, err := os.ReadFile(configPath) // #nosec G304 -- path is fixed under user home (validated to stay within configDir) or chosen explicitly via CODEAGENT_MODELS_CONFIG
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("models config not found: %s\n\n%s", configPath, modelsConfigHint(configPath))
@@ -163,6 +220,20 @@ func ResolveBackendConfig(backendName string) (baseURL, apiKey string) {
 	return strings.TrimSpace(resolved.BaseURL), strings.TrimSpace(resolved.APIKey)
 }
 
+// ResolveMessageExtractor returns the extractor configured for backendName in
+// models.json. Rules missing message_type or message_path are ignored.
+func ResolveMessageExtractor(backendName string) (MessageExtractor, bool) {
+	cfg, err := modelsConfig()
+	if err != nil || cfg == nil {
+		return MessageExtractor{}, false
+	}
+	rule := resolveBackendConfig(cfg, backendName).Extractor
+	if rule == nil || strings.TrimSpace(rule.MessageType) == "" || strings.TrimSpace(rule.MessagePath) == "" {
+		return MessageExtractor{}, false
+	}
+	return *rule, true
+}
+
 func resolveBackendConfig(cfg *ModelsConfig, backendName string) BackendConfig {
 	if cfg == nil || len(cfg.Backends) == 0 {
 		return BackendConfig{}
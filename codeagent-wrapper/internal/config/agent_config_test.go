@@ -179,6 +179,39 @@ func TestResolveAgentConfig_DynamicAgent(t *testing.T) {
 	}
 }
 
+func TestResolveMessageExtractor(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(ResetModelsConfigCacheForTest)
+	ResetModelsConfigCacheForTest()
+
+	configDir := filepath.Join(home, ".codeagent")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "models.json"), []byte(`{
+  "backends": {
+    "opencode": {"extractor": {"message_type": "final", "message_path": "payload.text", "session_path": "sid", "append": true}},
+    "gemini": {"extractor": {"message_type": "final"}}
+  }
+}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	rule, ok := ResolveMessageExtractor("OpenCode")
+	want := MessageExtractor{MessageType: "final", MessagePath: "payload.text", SessionPath: "sid", Append: true}
+	if !ok || rule != want {
+		t.Fatalf("ResolveMessageExtractor(opencode) = %+v, %v; want %+v", rule, ok, want)
+	}
+	if _, ok := ResolveMessageExtractor("gemini"); ok {
+		t.Fatalf("rule without message_path should be ignored")
+	}
+	if _, ok := ResolveMessageExtractor("codex"); ok {
+		t.Fatalf("backend without extractor should have no rule")
+	}
+}
+
 func TestLoadModelsConfig_InvalidJSON(t *testing.T) {
 	tmpDir := t.TempDir()
 	configDir := filepath.Join(tmpDir, ".codeagent")
@@ -260,3 +293,82 @@ func TestResolveAgentConfig_EmptyModel_ReturnsError(t *testing.T) {
 		t.Fatalf("error should mention empty model, got: %s", err.Error())
 	}
 }
+
+func TestLoadModelsConfig_EnvOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(ResetModelsConfigCacheForTest)
+	t.Cleanup(func() { SetLogWarnFunc(nil) })
+
+	var warns []string
+	SetLogWarnFunc(func(msg string) { warns = append(warns, msg) })
+
+	homeConfig := filepath.Join(home, ".codeagent", "models.json")
+	if err := os.MkdirAll(filepath.Dir(homeConfig), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(homeConfig, []byte(`{"default_backend":"codex","agents":{"home":{"backend":"codex","model":"m"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	projectConfig := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(projectConfig, []byte(`{"default_backend":"claude","agents":{"project":{"backend":"claude","model":"m"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(modelsConfigEnv, projectConfig)
+	ResetModelsConfigCacheForTest()
+	cfg, err := loadModelsConfig()
+	if err != nil {
+		t.Fatalf("loadModelsConfig() error = %v", err)
+	}
+	if _, ok := cfg.Agents["project"]; !ok || cfg.DefaultBackend != "claude" || len(warns) != 0 {
+		t.Fatalf("cfg = %+v, warns = %v; want the override file without warnings", cfg, warns)
+	}
+
+	missing := filepath.Join(t.TempDir(), "nope.json")
+	t.Setenv(modelsConfigEnv, missing)
+	ResetModelsConfigCacheForTest()
+	cfg, err = loadModelsConfig()
+	if err != nil {
+		t.Fatalf("loadModelsConfig() error = %v", err)
+	}
+	if _, ok := cfg.Agents["home"]; !ok {
+		t.Fatalf("cfg = %+v, want the home-dir config as fallback", cfg)
+	}
+	if len(warns) != 1 || !strings.Contains(warns[0], missing) || !strings.Contains(warns[0], "falling back") {
+		t.Fatalf("warns = %v, want one fallback warning naming %s", warns, missing)
+	}
+}
+
+func TestResolveAgentConfig_CachesModelsConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Cleanup(ResetModelsConfigCacheForTest)
+	ResetModelsConfigCacheForTest()
+
+	configPath := filepath.Join(home, ".codeagent", "models.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"agents":{"develop":{"backend":"codex","model":"m1"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, model, _, _, _, _, _, _, _, err := ResolveAgentConfig("develop"); err != nil || model != "m1" {
+		t.Fatalf("first resolve = %q, %v; want m1", model, err)
+	}
+	// Later resolutions in the same process reuse the parsed file.
+	if err := os.Remove(configPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, model, _, _, _, _, _, _, _, err := ResolveAgentConfig("develop"); err != nil || model != "m1" {
+		t.Fatalf("cached resolve = %q, %v; want m1 without re-reading", model, err)
+	}
+
+	ResetModelsConfigCacheForTest()
+	if _, _, _, _, _, _, _, _, _, err := ResolveAgentConfig("develop"); err == nil {
+		t.Fatalf("resolve after reset should re-read the (now missing) file")
+	}
+}
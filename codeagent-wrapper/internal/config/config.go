@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds CLI configuration.
@@ -29,6 +30,63 @@ type Config struct {
 	DisallowedTools    []string
 	Skills             []string
 	Worktree           bool // Execute in a new git worktree
+	// JSONStreamPassthrough forwards the raw backend JSON stream to stdout.
+	JSONStreamPassthrough bool
+	// ClaudeAllowFile is a settings file passed to claude via --settings so
+	// curated permissions apply even though setting sources are disabled.
+	ClaudeAllowFile string
+	// Retries is how many times a failed run is retried.
+	Retries int
+	// RetryBackoff is the retry pause curve (fixed, linear or exponential).
+	RetryBackoff string
+	// RetryBase is the first retry pause; zero uses the default.
+	RetryBase time.Duration
+	// FailOnTurnFailed fails runs whose stream reported turn.failed/error
+	// events even when the backend exited 0.
+	FailOnTurnFailed bool
+	// FailIfNoFilesChanged fails runs whose stream reported no file_change
+	// events.
+	FailIfNoFilesChanged bool
+	// StatusFile receives a minimal {"ok","total","failed"} summary.
+	StatusFile string
+	// StreamJSONValidate logs backend events that miss fields expected for
+	// their type.
+	StreamJSONValidate bool
+	// AppendWorkdirContext appends a bounded listing of the workdir to the
+	// task text.
+	AppendWorkdirContext bool
+	// Confirm asks for interactive confirmation before running a backend
+	// with its sandbox/permission bypass enabled.
+	Confirm bool
+	// CopySessionToClipboard copies the captured session id to the system
+	// clipboard after the run.
+	CopySessionToClipboard bool
+	// StripControl removes terminal control sequences from the captured
+	// message.
+	StripControl bool
+	// WorkdirGitCheck warns before the run when the workdir has uncommitted
+	// git changes; RequireClean turns the warning into an error.
+	WorkdirGitCheck bool
+	RequireClean    bool
+	// MaxMessageLines caps how many lines of the message are printed to
+	// stdout (0 = no cap); structured output keeps the full message.
+	MaxMessageLines int
+	// DryRun prints the resolved backend command instead of running it.
+	DryRun bool
+	// SystemPrompt is appended to the backend's system prompt (claude
+	// --append-system-prompt); other backends get it prepended to the task.
+	SystemPrompt string
+	// CacheDir enables the task result cache; CacheRefresh ignores cached
+	// results and CacheNoWrite stops new results from being stored.
+	CacheDir     string
+	CacheRefresh bool
+	CacheNoWrite bool
+	// StreamMessages prints assistant text to stdout as it arrives
+	// (CODEAGENT_STREAM) instead of once at the end.
+	StreamMessages bool
+	// Quiet skips the startup banner on stderr; the log file still records
+	// the command.
+	Quiet bool
 }
 
 // EnvFlagEnabled returns true when the environment variable exists and is not
@@ -86,6 +144,34 @@ func ValidateAgentName(name string) error {
 	return nil
 }
 
+// NormalizeSessionID trims whitespace and one pair of surrounding quotes
+// from a resume session id and rejects ids the backends could not have
+// issued: anything but letters, digits, '-', '_', '.' and ':', or a leading
+// '-' that a backend CLI would read as a flag.
+func NormalizeSessionID(raw string) (string, error) {
+	id := strings.TrimSpace(raw)
+	if len(id) >= 2 && (id[0] == '"' || id[0] == '\'') && id[len(id)-1] == id[0] {
+		id = strings.TrimSpace(id[1 : len(id)-1])
+	}
+	if id == "" {
+		return "", fmt.Errorf("invalid session id %q: empty", raw)
+	}
+	if id[0] == '-' {
+		return "", fmt.Errorf("invalid session id %q: must not start with '-'", raw)
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return "", fmt.Errorf("invalid session id %q: contains %q", raw, r)
+		}
+	}
+	return id, nil
+}
+
 const maxParallelWorkersLimit = 100
 
 // ResolveMaxParallelWorkers reads CODEAGENT_MAX_PARALLEL_WORKERS. It returns 0
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSessionRecords bounds the session registry; the oldest entries are
+// dropped first.
+const maxSessionRecords = 500
+
+// SessionRecord is one entry of ~/.codeagent/sessions.json.
+type SessionRecord struct {
+	SessionID string    `json:"session_id"`
+	WorkDir   string    `json:"workdir"`
+	Backend   string    `json:"backend,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type sessionRegistry struct {
+	Sessions []SessionRecord `json:"sessions"`
+}
+
+func sessionsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return "", fmt.Errorf("failed to resolve user home directory: %w", err)
+	}
+	return filepath.Join(home, ".codeagent", "sessions.json"), nil
+}
+
+func loadSessionRegistry(path string) (sessionRegistry, error) {
+	var reg sessionRegistry
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return reg, nil
+		}
+		return reg, err
+	}
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return reg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return reg, nil
+}
+
+// RecordSession stores rec in the session registry, replacing any earlier
+// entry for the same session id. The file is rewritten atomically.
+func RecordSession(rec SessionRecord) error {
+	rec.SessionID = strings.TrimSpace(rec.SessionID)
+	if rec.SessionID == "" {
+		return fmt.Errorf("session id is empty")
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = time.Now()
+	}
+
+	path, err := sessionsPath()
+	if err != nil {
+		return err
+	}
+	reg, err := loadSessionRegistry(path)
+	if err != nil {
+		return err
+	}
+
+	sessions := []SessionRecord{rec}
+	for _, existing := range reg.Sessions {
+		if existing.SessionID != rec.SessionID {
+			sessions = append(sessions, existing)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	if len(sessions) > maxSessionRecords {
+		sessions = sessions[:maxSessionRecords]
+	}
+
+	data, err := json.MarshalIndent(sessionRegistry{Sessions: sessions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sessions-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// LookupSession returns the registry entry for sessionID.
+func LookupSession(sessionID string) (SessionRecord, bool, error) {
+	path, err := sessionsPath()
+	if err != nil {
+		return SessionRecord{}, false, err
+	}
+	reg, err := loadSessionRegistry(path)
+	if err != nil {
+		return SessionRecord{}, false, err
+	}
+	sessionID = strings.TrimSpace(sessionID)
+	for _, rec := range reg.Sessions {
+		if rec.SessionID == sessionID {
+			return rec, true, nil
+		}
+	}
+	return SessionRecord{}, false, nil
+}
+
+// LatestSession returns the most recently recorded session that ran in
+// workDir. Entries recorded for a different backend are skipped when both
+// backends are known.
+func LatestSession(workDir, backend string) (SessionRecord, bool, error) {
+	path, err := sessionsPath()
+	if err != nil {
+		return SessionRecord{}, false, err
+	}
+	reg, err := loadSessionRegistry(path)
+	if err != nil {
+		return SessionRecord{}, false, err
+	}
+	workDir = filepath.Clean(workDir)
+	backend = strings.TrimSpace(backend)
+
+	var latest SessionRecord
+	found := false
+	for _, rec := range reg.Sessions {
+		if rec.WorkDir == "" || filepath.Clean(rec.WorkDir) != workDir {
+			continue
+		}
+		if backend != "" && rec.Backend != "" && rec.Backend != backend {
+			continue
+		}
+		if !found || rec.UpdatedAt.After(latest.UpdatedAt) {
+			latest = rec
+			found = true
+		}
+	}
+	return latest, found, nil
+}
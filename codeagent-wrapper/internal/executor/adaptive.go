@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// transientFailureMarkers are substrings (lower-case) of backend errors that
+// indicate overload or rate limiting rather than a problem with the task.
+var transientFailureMarkers = []string{
+	"rate limit",
+	"rate_limit",
+	"ratelimit",
+	"too many requests",
+	"429",
+	"overloaded",
+	"503",
+	"service unavailable",
+	"capacity",
+	"quota",
+	"try again later",
+}
+
+// isTransientFailure reports whether a failed result looks like overload
+// (rate limiting, 429/503, capacity errors) that lower concurrency may relieve.
+func isTransientFailure(res TaskResult) bool {
+	if !isRetryableResult(res) {
+		return false
+	}
+	text := strings.ToLower(res.Error + "\n" + strings.Join(res.StreamErrors, "\n"))
+	for _, marker := range transientFailureMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptiveLimiter is an AIMD concurrency cap: every transient failure halves
+// the limit (down to 1) and every success raises it by 1/limit, so it grows
+// back by roughly one slot per window of successful tasks up to ceiling.
+type adaptiveLimiter struct {
+	mu      sync.Mutex
+	ceiling int
+	limit   float64
+	active  int
+	wake    chan struct{}
+}
+
+func newAdaptiveLimiter(ceiling int) *adaptiveLimiter {
+	if ceiling < 1 {
+		ceiling = 1
+	}
+	return &adaptiveLimiter{
+		ceiling: ceiling,
+		limit:   float64(ceiling),
+		wake:    make(chan struct{}),
+	}
+}
+
+// Limit returns the current effective concurrency.
+func (l *adaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.effectiveLocked()
+}
+
+func (l *adaptiveLimiter) effectiveLocked() int {
+	n := int(l.limit)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// acquire blocks until a slot is free under the current limit or ctx is done.
+func (l *adaptiveLimiter) acquire(ctx context.Context) bool {
+	for {
+		l.mu.Lock()
+		if l.active < l.effectiveLocked() {
+			l.active++
+			l.mu.Unlock()
+			return true
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release frees a slot and adjusts the limit based on the task outcome.
+func (l *adaptiveLimiter) release(res TaskResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active > 0 {
+		l.active--
+	}
+
+	before := l.effectiveLocked()
+	switch {
+	case isTransientFailure(res):
+		l.limit /= 2
+		if l.limit < 1 {
+			l.limit = 1
+		}
+	case res.ExitCode == 0 && res.Error == "":
+		l.limit += 1 / l.limit
+		if l.limit > float64(l.ceiling) {
+			l.limit = float64(l.ceiling)
+		}
+	}
+	if after := l.effectiveLocked(); after != before {
+		logInfo(fmt.Sprintf("adaptive concurrency: limit %d -> %d (task %s)", before, after, res.TaskID))
+	}
+
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -25,8 +28,50 @@ import (
 )
 
 const postMessageTerminateDelay = 1 * time.Second
+
+// postEOFGrace returns how long to wait for the backend to exit after its
+// stdout reached EOF (CODEAGENT_POST_EOF_GRACE). The value is a Go duration
+// ("500ms", "3s") or a number of seconds; it defaults to
+// postMessageTerminateDelay.
+func postEOFGrace() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_POST_EOF_GRACE"))
+	if raw == "" {
+		return postMessageTerminateDelay
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return d
+	}
+	if secs, err := strconv.Atoi(raw); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return postMessageTerminateDelay
+}
+
 const forceKillWaitTimeout = 5 * time.Second
 
+// minKillGrace is the shortest CODEAGENT_KILL_GRACE accepted.
+const minKillGrace = 1 * time.Second
+
+// forceKillGrace returns how long a terminated backend gets to exit after
+// SIGTERM before it is killed: CODEAGENT_KILL_GRACE in whole seconds (at
+// least minKillGrace), or forceKillDelay when unset or invalid.
+func forceKillGrace() time.Duration {
+	fallback := time.Duration(forceKillDelay.Load()) * time.Second
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_KILL_GRACE"))
+	if raw == "" {
+		return fallback
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil || secs < 0 {
+		logWarn(fmt.Sprintf("Invalid CODEAGENT_KILL_GRACE %q, using %s", raw, fallback))
+		return fallback
+	}
+	if grace := time.Duration(secs) * time.Second; grace > minKillGrace {
+		return grace
+	}
+	return minKillGrace
+}
+
 // Defaults duplicated from wrapper for module decoupling.
 const (
 	defaultWorkdir        = "."
@@ -35,6 +80,17 @@ const (
 
 	codexLogLineLimit  = 1000
 	stderrCaptureLimit = 4 * 1024
+	// plainOutputCaptureLimit bounds the non-JSON stdout kept as a fallback
+	// message for backends that exit 0 without emitting any events.
+	plainOutputCaptureLimit = 64 * 1024
+
+	// exitWorkdirMissing (EX_NOINPUT from sysexits.h) reports a task whose
+	// workdir does not exist, before any backend is started.
+	exitWorkdirMissing = 66
+
+	// exitTooManyReconnects (EX_TEMPFAIL) reports a run aborted because the
+	// backend kept reconnecting (CODEAGENT_MAX_RECONNECTS).
+	exitTooManyReconnects = 75
 )
 
 const (
@@ -51,6 +107,9 @@ var (
 	commandContext     = exec.CommandContext
 	terminateCommandFn = terminateCommand
 	createWorktreeFn   = worktree.CreateWorktree
+	signalNotifyFn     = signal.Notify
+	signalStopFn       = signal.Stop
+	lookPathFn         = exec.LookPath
 )
 
 var forceKillDelay atomic.Int32
@@ -60,9 +119,10 @@ func init() {
 }
 
 type (
-	Backend = backend.Backend
-	Config  = config.Config
-	Logger  = ilogger.Logger
+	Backend    = backend.Backend
+	Config     = config.Config
+	Logger     = ilogger.Logger
+	FileChange = parser.FileChange
 )
 
 type minimalClaudeSettings = backend.MinimalClaudeSettings
@@ -71,6 +131,8 @@ func loadMinimalClaudeSettings() minimalClaudeSettings { return backend.LoadMini
 
 func loadGeminiEnv() map[string]string { return backend.LoadGeminiEnv() }
 
+func postProcessMessage(b Backend, msg string) string { return backend.PostProcessMessage(b, msg) }
+
 func NewLogger() (*Logger, error) { return ilogger.NewLogger() }
 
 func NewLoggerWithSuffix(suffix string) (*Logger, error) { return ilogger.NewLoggerWithSuffix(suffix) }
@@ -93,8 +155,23 @@ func logConcurrencyState(event, taskID string, active, limit int) {
 	ilogger.LogConcurrencyState(event, taskID, active, limit)
 }
 
-func parseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func()) (message, threadID string) {
-	return parser.ParseJSONStreamInternal(r, warnFn, infoFn, onMessage, onComplete)
+func parseJSONStreamWithOptions(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func(), opts parser.ParseOptions) parser.StreamResult {
+	return parser.ParseJSONStreamWithOptions(r, warnFn, infoFn, onMessage, onComplete, opts)
+}
+
+// messageExtractorFor returns the models.json extractor rule for backendName.
+func messageExtractorFor(backendName string) *parser.ExtractorRule {
+	rule, ok := config.ResolveMessageExtractor(backendName)
+	if !ok {
+		return nil
+	}
+	return &parser.ExtractorRule{
+		MessageType:  rule.MessageType,
+		MessagePath:  rule.MessagePath,
+		SessionPath:  rule.SessionPath,
+		CompleteType: rule.CompleteType,
+		Append:       rule.Append,
+	}
 }
 
 func sanitizeOutput(s string) string { return utils.SanitizeOutput(s) }
@@ -103,6 +180,8 @@ func safeTruncate(s string, maxLen int) string { return utils.SafeTruncate(s, ma
 
 func min(a, b int) int { return utils.Min(a, b) }
 
+func resolveCommand(name string) string { return backend.ResolveCommand(name) }
+
 // commandRunner abstracts exec.Cmd for testability
 type commandRunner interface {
 	Start() error
@@ -126,7 +205,23 @@ type processHandle interface {
 
 // realCmd implements commandRunner using exec.Cmd
 type realCmd struct {
-	cmd *exec.Cmd
+	cmd      *exec.Cmd
+	ownGroup bool
+}
+
+// processGroupStarter is implemented by command runners that can start the
+// backend in a process group of its own, away from the terminal's foreground
+// group, so a Ctrl+C aimed at the wrapper does not reach it directly.
+type processGroupStarter interface {
+	StartInOwnProcessGroup()
+}
+
+func (r *realCmd) StartInOwnProcessGroup() {
+	if r == nil || r.cmd == nil {
+		return
+	}
+	setOwnProcessGroup(r.cmd)
+	r.ownGroup = true
 }
 
 func (r *realCmd) Start() error {
@@ -297,12 +392,38 @@ var newCommandRunner = func(ctx context.Context, name string, args ...string) co
 }
 
 type parseResult struct {
-	message  string
-	threadID string
+	message     string
+	threadID    string
+	errors      []string
+	fileChanges []parser.FileChange
+	usage       parser.TokenUsage
+	plainText   string
+}
+
+func (r *TaskResult) setUsage(usage parser.TokenUsage) {
+	r.Tokens = usage.TotalTokens
+	r.InputTokens = usage.InputTokens
+	r.OutputTokens = usage.OutputTokens
 }
 
 type taskLoggerContextKey struct{}
 
+type signalsHandledContextKey struct{}
+
+// withSignalsHandled marks ctx as belonging to a run that handles
+// SIGINT/SIGTERM itself, so individual tasks must not react to them.
+func withSignalsHandled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, signalsHandledContextKey{}, true)
+}
+
+func signalsHandled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	handled, _ := ctx.Value(signalsHandledContextKey{}).(bool)
+	return handled
+}
+
 func withTaskLogger(ctx context.Context, logger *Logger) context.Context {
 	if ctx == nil || logger == nil {
 		return ctx
@@ -358,12 +479,26 @@ func DefaultRunCodexTaskFn(task TaskSpec, timeout int) TaskResult {
 	if task.Mode == "" {
 		task.Mode = "new"
 	}
+	backendName := task.Backend
+	if backendName == "" {
+		backendName = defaultBackendName
+	}
+
 	if strings.TrimSpace(task.PromptFile) != "" {
 		prompt, err := ReadAgentPromptFile(task.PromptFile, false)
-		if err != nil {
+		switch {
+		case err == nil && backendName == "claude":
+			// Claude takes the persona as a system prompt instead of a
+			// preamble in the user task.
+			task.SystemPrompt = prompt
+		case err == nil:
+			task.Task = WrapTaskWithAgentPrompt(prompt, task.Task)
+			task.UseStdin = true
+		case task.Agent != "" && errors.Is(err, os.ErrNotExist):
+			logWarn(fmt.Sprintf("Task %s: agent prompt file %s not found; running the task without it", task.ID, task.PromptFile))
+		default:
 			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "failed to read prompt file: " + err.Error()}
 		}
-		task.Task = WrapTaskWithAgentPrompt(prompt, task.Task)
 	}
 	// Resolve skills: explicit > auto-detect from workdir
 	skills := task.Skills
@@ -379,11 +514,6 @@ func DefaultRunCodexTaskFn(task TaskSpec, timeout int) TaskResult {
 		task.UseStdin = true
 	}
 
-	backendName := task.Backend
-	if backendName == "" {
-		backendName = defaultBackendName
-	}
-
 	backend, err := selectBackendFn(backendName)
 	if err != nil {
 		return TaskResult{TaskID: task.ID, ExitCode: 1, Error: err.Error()}
@@ -394,17 +524,21 @@ func DefaultRunCodexTaskFn(task TaskSpec, timeout int) TaskResult {
 	if parentCtx == nil {
 		parentCtx = context.Background()
 	}
-	return RunCodexTaskWithContext(parentCtx, task, backend, "", nil, nil, false, true, timeout)
+	return RunWithResultCache(task, func(task TaskSpec) TaskResult {
+		return RunCodexTaskWithContext(parentCtx, task, backend, "", nil, nil, false, true, timeout)
+	})
 }
 
 func TopologicalSort(tasks []TaskSpec) ([][]TaskSpec, error) {
 	idToTask := make(map[string]TaskSpec, len(tasks))
 	indegree := make(map[string]int, len(tasks))
 	adj := make(map[string][]string, len(tasks))
+	position := make(map[string]int, len(tasks))
 
-	for _, task := range tasks {
+	for i, task := range tasks {
 		idToTask[task.ID] = task
 		indegree[task.ID] = 0
+		position[task.ID] = i
 	}
 
 	for _, task := range tasks {
@@ -446,21 +580,63 @@ func TopologicalSort(tasks []TaskSpec) ([][]TaskSpec, error) {
 				}
 			}
 		}
+		// Keep each layer in declared (config) order.
+		sort.SliceStable(next, func(i, j int) bool { return position[next[i]] < position[next[j]] })
 		queue = append(queue, next...)
 	}
 
 	if processed != len(tasks) {
-		cycleIDs := make([]string, 0)
-		for id, deg := range indegree {
-			if deg > 0 {
-				cycleIDs = append(cycleIDs, id)
+		cycle := findDependencyCycle(tasks, indegree, position)
+		return nil, fmt.Errorf("cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	return layers, nil
+}
+
+// findDependencyCycle returns one cycle among the tasks TopologicalSort could
+// not place, as a closed path ("a", "c", "b", "a") in which each task depends
+// on the next, starting from the earliest-declared task of the cycle. Every
+// unplaced task still has an unplaced dependency, so following them from any
+// unplaced task must eventually revisit one.
+func findDependencyCycle(tasks []TaskSpec, indegree, position map[string]int) []string {
+	pending := make(map[string][]string)
+	start := ""
+	for _, task := range tasks {
+		if indegree[task.ID] == 0 {
+			continue
+		}
+		if start == "" {
+			start = task.ID
+		}
+		for _, dep := range task.Dependencies {
+			if indegree[dep] > 0 {
+				pending[task.ID] = append(pending[task.ID], dep)
 			}
 		}
-		sort.Strings(cycleIDs)
-		return nil, fmt.Errorf("cycle detected involving tasks: %s", strings.Join(cycleIDs, ","))
 	}
 
-	return layers, nil
+	seenAt := make(map[string]int)
+	var path []string
+	for id := start; id != ""; {
+		if i, ok := seenAt[id]; ok {
+			cycle := path[i:]
+			first := 0
+			for j := range cycle {
+				if position[cycle[j]] < position[cycle[first]] {
+					first = j
+				}
+			}
+			cycle = append(append([]string{}, cycle[first:]...), cycle[:first]...)
+			return append(cycle, cycle[0])
+		}
+		seenAt[id] = len(path)
+		path = append(path, id)
+		if len(pending[id]) == 0 {
+			break
+		}
+		id = pending[id][0]
+	}
+	return path
 }
 
 func ExecuteConcurrent(layers [][]TaskSpec, timeout int, runTask func(TaskSpec, int) TaskResult) []TaskResult {
@@ -468,7 +644,47 @@ func ExecuteConcurrent(layers [][]TaskSpec, timeout int, runTask func(TaskSpec,
 	return ExecuteConcurrentWithContext(context.Background(), layers, timeout, maxWorkers, runTask)
 }
 
+// ConcurrentOptions configures ExecuteConcurrentWithOptions.
+type ConcurrentOptions struct {
+	Timeout    int
+	MaxWorkers int
+	RunTask    func(TaskSpec, int) TaskResult
+	// Retries is how many times a failed task is re-run before giving up.
+	Retries int
+	// RetryBackoff shapes the pause between retries.
+	RetryBackoff RetryBackoff
+	// TaskOrder selects the start order within a layer (see TaskOrder*).
+	TaskOrder string
+	// InterruptFile aborts the run once the file exists: in-flight tasks are
+	// cancelled and tasks that have not started yet are skipped.
+	InterruptFile string
+	// AdaptiveConcurrency lowers the worker limit when tasks fail with
+	// rate-limit-like errors and ramps it back up as tasks succeed (AIMD).
+	// MaxWorkers (or the task count when unlimited) is the ceiling.
+	AdaptiveConcurrency bool
+	// FailFast cancels the run as soon as any task fails: in-flight tasks are
+	// terminated and tasks that have not started yet are skipped.
+	FailFast bool
+	// DrainOnInterrupt handles SIGINT/SIGTERM for the whole run: no further
+	// tasks are started, running ones get the kill grace to finish before
+	// they are cancelled, and a second signal cancels them right away.
+	DrainOnInterrupt bool
+	// OnDrain, when set, is called once a DrainOnInterrupt signal has been
+	// received, even if every running task then finishes within the grace.
+	OnDrain func()
+}
+
+// interruptPollInterval controls how often InterruptFile is checked.
+var interruptPollInterval = 250 * time.Millisecond
+
 func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec, timeout int, maxWorkers int, runTask func(TaskSpec, int) TaskResult) []TaskResult {
+	return ExecuteConcurrentWithOptions(parentCtx, layers, ConcurrentOptions{Timeout: timeout, MaxWorkers: maxWorkers, RunTask: runTask})
+}
+
+func ExecuteConcurrentWithOptions(parentCtx context.Context, layers [][]TaskSpec, opts ConcurrentOptions) []TaskResult {
+	timeout := opts.Timeout
+	maxWorkers := opts.MaxWorkers
+	runTask := opts.RunTask
 	if runTask == nil {
 		runTask = DefaultRunCodexTaskFn
 	}
@@ -509,31 +725,112 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// launchCtx gates starting new tasks; a drain stops it while leaving
+	// running tasks on ctx.
+	launchCtx, stopLaunch := context.WithCancel(ctx)
+	defer stopLaunch()
+
+	var drained atomic.Bool
+	if opts.DrainOnInterrupt {
+		ctx = withSignalsHandled(ctx)
+		sigCh := make(chan os.Signal, 2)
+		signalNotifyFn(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		defer signalStopFn(sigCh)
+		grace := forceKillGrace()
+		go drainOnSignal(ctx, sigCh, grace, func() {
+			drained.Store(true)
+			if opts.OnDrain != nil {
+				opts.OnDrain()
+			}
+			logWarn("interrupt received; not starting new tasks")
+			fmt.Fprintf(os.Stderr, "Interrupted: waiting up to %s for running tasks (interrupt again to stop them now)\n", grace)
+			stopLaunch()
+		}, func() {
+			logWarn("cancelling running tasks after interrupt")
+			cancel()
+		})
+	}
+
+	var interrupted atomic.Bool
+	if interruptFile := strings.TrimSpace(opts.InterruptFile); interruptFile != "" {
+		go watchInterruptFile(ctx, interruptFile, func() {
+			interrupted.Store(true)
+			logWarn(fmt.Sprintf("interrupt file %s detected; cancelling remaining tasks", interruptFile))
+			cancel()
+		})
+	}
+	var systemicCause atomic.Value // description of the layer that failed systemically
+	var failFastOnce sync.Once
+	var failFastCause atomic.Value // ID of the task that triggered fail-fast
+	noteOutcome := func(res TaskResult) {
+		if !opts.FailFast || (res.ExitCode == 0 && res.Error == "") {
+			return
+		}
+		failFastOnce.Do(func() {
+			failFastCause.Store(res.TaskID)
+			logWarn(fmt.Sprintf("fail-fast: task %s failed; cancelling remaining tasks", res.TaskID))
+			cancel()
+		})
+	}
+	skippedResult := func(taskID string) TaskResult {
+		if interrupted.Load() {
+			return TaskResult{TaskID: taskID, ExitCode: 130, Error: "skipped: run interrupted by " + opts.InterruptFile, Interrupted: true}
+		}
+		if drained.Load() {
+			return TaskResult{TaskID: taskID, ExitCode: 130, Error: "not started: run interrupted", Interrupted: true}
+		}
+		if cause, ok := failFastCause.Load().(string); ok {
+			return TaskResult{TaskID: taskID, ExitCode: 1, Error: "skipped: fail-fast after task " + cause + " failed"}
+		}
+		if cause, ok := systemicCause.Load().(string); ok {
+			return TaskResult{TaskID: taskID, ExitCode: 1, Error: "skipped: " + cause}
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// Only parentCtx carries a deadline: the overall run timeout.
+			return TaskResult{TaskID: taskID, ExitCode: 124, Error: "skipped: run timeout reached before the task started"}
+		}
+		return cancelledTaskResult(taskID, ctx)
+	}
+
 	workerLimit := maxWorkers
 	if workerLimit < 0 {
 		workerLimit = 0
 	}
 
 	var sem chan struct{}
-	if workerLimit > 0 {
+	var adaptive *adaptiveLimiter
+	if opts.AdaptiveConcurrency {
+		ceiling := workerLimit
+		if ceiling == 0 {
+			ceiling = totalTasks
+		}
+		adaptive = newAdaptiveLimiter(ceiling)
+	} else if workerLimit > 0 {
 		sem = make(chan struct{}, workerLimit)
 	}
 
 	logConcurrencyPlanning(workerLimit, totalTasks)
 
 	acquireSlot := func() bool {
+		if adaptive != nil {
+			return adaptive.acquire(launchCtx)
+		}
 		if sem == nil {
 			return true
 		}
 		select {
 		case sem <- struct{}{}:
 			return true
-		case <-ctx.Done():
+		case <-launchCtx.Done():
 			return false
 		}
 	}
 
-	releaseSlot := func() {
+	releaseSlot := func(res TaskResult) {
+		if adaptive != nil {
+			adaptive.release(res)
+			return
+		}
 		if sem == nil {
 			return
 		}
@@ -545,11 +842,15 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 
 	var activeWorkers int64
 
-	for _, layer := range layers {
+	dependents := countDependents(layers)
+	systemicRatio := systemicFailureRatio()
+	systemicAbort := systemicFailureAbort()
+
+	for layerIdx, layer := range layers {
 		var wg sync.WaitGroup
 		executed := 0
 
-		for _, task := range layer {
+		for _, task := range orderLayer(layer, opts.TaskOrder, dependents) {
 			if skip, reason := shouldSkipTask(task, failed); skip {
 				res := TaskResult{TaskID: task.ID, ExitCode: 1, Error: reason}
 				results = append(results, res)
@@ -557,8 +858,17 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 				continue
 			}
 
-			if ctx.Err() != nil {
-				res := cancelledTaskResult(task.ID, ctx)
+			if launchCtx.Err() != nil {
+				res := skippedResult(task.ID)
+				results = append(results, res)
+				failed[task.ID] = res
+				continue
+			}
+
+			// Acquire the worker slot before spawning so tasks start in
+			// layer order when concurrency is capped.
+			if !acquireSlot() {
+				res := skippedResult(task.ID)
 				results = append(results, res)
 				failed[task.ID] = res
 				continue
@@ -568,20 +878,18 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 			wg.Add(1)
 			go func(ts TaskSpec) {
 				defer wg.Done()
+				var outcome TaskResult
+				defer func() { releaseSlot(outcome) }()
 				var taskLogPath string
 				handle := taskLoggerHandle{}
 				defer func() {
 					if r := recover(); r != nil {
-						resultsCh <- TaskResult{TaskID: ts.ID, ExitCode: 1, Error: fmt.Sprintf("panic: %v", r), LogPath: taskLogPath, sharedLog: handle.shared}
+						outcome = TaskResult{TaskID: ts.ID, ExitCode: 1, Error: fmt.Sprintf("panic: %v", r), LogPath: taskLogPath, sharedLog: handle.shared}
+						noteOutcome(outcome)
+						resultsCh <- outcome
 					}
 				}()
 
-				if !acquireSlot() {
-					resultsCh <- cancelledTaskResult(ts.ID, ctx)
-					return
-				}
-				defer releaseSlot()
-
 				current := atomic.AddInt64(&activeWorkers, 1)
 				logConcurrencyState("start", ts.ID, int(current), workerLimit)
 				defer func() {
@@ -603,7 +911,7 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 
 				printTaskStart(ts.ID, taskLogPath, handle.shared)
 
-				res := runTask(ts, timeout)
+				res := RunTaskWithBackoff(ts, timeout, opts.Retries, opts.RetryBackoff, runTask)
 				if taskLogPath != "" {
 					if res.LogPath == "" || (handle.shared && handle.logger != nil && res.LogPath == handle.logger.Path()) {
 						res.LogPath = taskLogPath
@@ -613,24 +921,95 @@ func ExecuteConcurrentWithContext(parentCtx context.Context, layers [][]TaskSpec
 				if handle.shared && handle.logger != nil && res.LogPath == handle.logger.Path() {
 					res.sharedLog = true
 				}
+				outcome = res
+				noteOutcome(res)
 				resultsCh <- res
 			}(task)
 		}
 
 		wg.Wait()
 
+		layerResults := make([]TaskResult, 0, executed)
 		for i := 0; i < executed; i++ {
 			res := <-resultsCh
 			results = append(results, res)
+			layerResults = append(layerResults, res)
 			if res.ExitCode != 0 || res.Error != "" {
 				failed[res.TaskID] = res
 			}
 		}
+
+		// A cancelled run (run timeout, interrupt, fail-fast) fails its
+		// in-flight tasks on purpose; that is not an outage.
+		if code, count, ok := detectSystemicFailure(layerResults, systemicRatio); ok && ctx.Err() == nil {
+			msg := systemicFailureMessage(layerIdx+1, code, count, len(layerResults))
+			logError(msg)
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", msg)
+			if systemicAbort && layerIdx < len(layers)-1 {
+				systemicCause.Store(fmt.Sprintf("layer %d failed systemically (exit %d)", layerIdx+1, code))
+				logWarn("CODEAGENT_SYSTEMIC_FAILURE_ABORT set; skipping the remaining layers")
+				cancel()
+			}
+		}
 	}
 
 	return results
 }
 
+// commandNotFoundMessage explains a missing backend binary, pointing at the
+// *_BIN override that was used or that could be set.
+func commandNotFoundMessage(commandName, execName string) string {
+	envKey := backend.CommandEnvOverride(commandName)
+	switch {
+	case envKey == "":
+		return fmt.Sprintf("%s command not found in PATH", execName)
+	case execName != commandName:
+		return fmt.Sprintf("backend %q binary %q (from %s) not found", commandName, execName, envKey)
+	default:
+		return fmt.Sprintf("backend %q not found in PATH; install it or set %s", commandName, envKey)
+	}
+}
+
+// drainOnSignal waits for the first signal on sigCh and calls onDrain, then
+// calls onAbort on a second signal or once grace has passed. It returns when
+// ctx is done.
+func drainOnSignal(ctx context.Context, sigCh <-chan os.Signal, grace time.Duration, onDrain, onAbort func()) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-sigCh:
+	}
+	onDrain()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-sigCh:
+		onAbort()
+	case <-timer.C:
+		onAbort()
+	}
+}
+
+// watchInterruptFile polls for path until ctx is done and calls onInterrupt
+// once when the file appears.
+func watchInterruptFile(ctx context.Context, path string, onInterrupt func()) {
+	ticker := time.NewTicker(interruptPollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := os.Stat(path); err == nil {
+			onInterrupt()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func cancelledTaskResult(taskID string, ctx context.Context) TaskResult {
 	exitCode := 130
 	msg := "execution cancelled"
@@ -723,7 +1102,7 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 			coverage := sanitizeOutput(res.Coverage)
 			keyOutput := sanitizeOutput(res.KeyOutput)
 			logPath := sanitizeOutput(res.LogPath)
-			filesChanged := sanitizeOutput(strings.Join(res.FilesChanged, ", "))
+			filesChanged := sanitizeOutput(reportedFiles(res))
 
 			target := res.CoverageTarget
 			if target <= 0 {
@@ -739,17 +1118,23 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if coverage != "" {
 					sb.WriteString(fmt.Sprintf(" %s", coverage))
 				}
+				if res.Cached {
+					sb.WriteString(" (cached)")
+				}
 				sb.WriteString("\n")
 
 				if keyOutput != "" {
 					sb.WriteString(fmt.Sprintf("Did: %s\n", keyOutput))
 				}
-				if len(res.FilesChanged) > 0 {
+				if filesChanged != "" {
 					sb.WriteString(fmt.Sprintf("Files: %s\n", filesChanged))
 				}
 				if res.TestsPassed > 0 {
 					sb.WriteString(fmt.Sprintf("Tests: %d passed\n", res.TestsPassed))
 				}
+				if usage := formatUsage(res); usage != "" {
+					sb.WriteString(fmt.Sprintf("Usage: %s\n", usage))
+				}
 				if logPath != "" {
 					sb.WriteString(fmt.Sprintf("Log: %s\n", logPath))
 				}
@@ -761,7 +1146,7 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if keyOutput != "" {
 					sb.WriteString(fmt.Sprintf("Did: %s\n", keyOutput))
 				}
-				if len(res.FilesChanged) > 0 {
+				if filesChanged != "" {
 					sb.WriteString(fmt.Sprintf("Files: %s\n", filesChanged))
 				}
 				if res.TestsPassed > 0 {
@@ -772,13 +1157,20 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if gap != "" {
 					sb.WriteString(fmt.Sprintf("Gap: %s\n", gap))
 				}
+				if usage := formatUsage(res); usage != "" {
+					sb.WriteString(fmt.Sprintf("Usage: %s\n", usage))
+				}
 				if logPath != "" {
 					sb.WriteString(fmt.Sprintf("Log: %s\n", logPath))
 				}
 
 			} else {
 				// Failed task: show error detail
-				sb.WriteString(fmt.Sprintf("\n### %s %s FAILED\n", taskID, failedSymbol))
+				status := "FAILED"
+				if res.Interrupted {
+					status = "NOT STARTED (interrupted)"
+				}
+				sb.WriteString(fmt.Sprintf("\n### %s %s %s\n", taskID, failedSymbol, status))
 				sb.WriteString(fmt.Sprintf("Exit code: %d\n", res.ExitCode))
 				if errText := sanitizeOutput(res.Error); errText != "" {
 					sb.WriteString(fmt.Sprintf("Error: %s\n", errText))
@@ -788,6 +1180,9 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				if detail != "" {
 					sb.WriteString(fmt.Sprintf("Detail: %s\n", detail))
 				}
+				if usage := formatUsage(res); usage != "" {
+					sb.WriteString(fmt.Sprintf("Usage: %s\n", usage))
+				}
 				if logPath != "" {
 					sb.WriteString(fmt.Sprintf("Log: %s\n", logPath))
 				}
@@ -797,6 +1192,9 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 		// Summary section
 		sb.WriteString("\n## Summary\n")
 		sb.WriteString(fmt.Sprintf("- %d/%d completed successfully\n", success, len(results)))
+		if totalTokens := sumTokens(results); totalTokens > 0 {
+			sb.WriteString(fmt.Sprintf("- Tokens: %s\n", formatTokenCount(totalTokens)))
+		}
 
 		if belowTarget > 0 || failed > 0 {
 			var needFix []string
@@ -841,6 +1239,8 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 				sb.WriteString(fmt.Sprintf("Status: FAILED (exit code %d)\nError: %s\n", res.ExitCode, sanitizeOutput(res.Error)))
 			} else if res.ExitCode != 0 {
 				sb.WriteString(fmt.Sprintf("Status: FAILED (exit code %d)\n", res.ExitCode))
+			} else if res.Cached {
+				sb.WriteString("Status: SUCCESS (cached)\n")
 			} else {
 				sb.WriteString("Status: SUCCESS\n")
 			}
@@ -850,6 +1250,12 @@ func GenerateFinalOutputWithMode(results []TaskResult, summaryOnly bool) string
 			if res.SessionID != "" {
 				sb.WriteString(fmt.Sprintf("Session: %s\n", sanitizeOutput(res.SessionID)))
 			}
+			if len(res.ChangedFiles) > 0 {
+				sb.WriteString(fmt.Sprintf("Files: %s\n", sanitizeOutput(formatChangedFiles(res.ChangedFiles))))
+			}
+			if usage := formatUsage(res); usage != "" {
+				sb.WriteString(fmt.Sprintf("Usage: %s\n", usage))
+			}
 			if res.LogPath != "" {
 				logPath := sanitizeOutput(res.LogPath)
 				if res.sharedLog {
@@ -898,7 +1304,7 @@ func buildCodexArgs(cfg *Config, targetArg string) []string {
 		args = append(args, "--model", model)
 	}
 
-	if reasoningEffort := strings.TrimSpace(cfg.ReasoningEffort); reasoningEffort != "" {
+	if reasoningEffort := backend.CodexReasoningEffort(cfg.ReasoningEffort); reasoningEffort != "" {
 		args = append(args, "-c", "model_reasoning_effort="+reasoningEffort)
 	}
 
@@ -920,7 +1326,31 @@ func buildCodexArgs(cfg *Config, targetArg string) []string {
 	)
 }
 
-func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backend Backend, defaultCommandName string, defaultArgsBuilder func(*Config, string) []string, customArgs []string, useCustomArgs bool, silent bool, timeoutSec int) TaskResult {
+// ensureWorkdir fails with a readable error when dir does not exist, instead
+// of letting the backend die with an opaque exec error. With
+// CODEAGENT_MKDIR_WORKDIR=1 the directory is created instead.
+func ensureWorkdir(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("workdir %q is not a directory", dir)
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("workdir %q is not accessible: %v", dir, err)
+	}
+	if !config.EnvFlagEnabled("CODEAGENT_MKDIR_WORKDIR") {
+		return fmt.Errorf("workdir %q does not exist (set CODEAGENT_MKDIR_WORKDIR=1 to create it)", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create workdir %q: %v", dir, err)
+	}
+	logInfo(fmt.Sprintf("Created workdir %s", dir))
+	return nil
+}
+
+func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backend Backend, defaultCommandName string, defaultArgsBuilder func(*Config, string) []string, customArgs []string, useCustomArgs bool, silent bool, timeoutSec int) (result TaskResult) {
 	taskCtx := taskSpec.Context
 	if parentCtx == nil {
 		parentCtx = taskCtx
@@ -929,7 +1359,7 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		parentCtx = context.Background()
 	}
 
-	result := TaskResult{TaskID: taskSpec.ID}
+	result = TaskResult{TaskID: taskSpec.ID}
 	injectedLogger := taskLoggerFromContext(taskCtx)
 	if injectedLogger == nil {
 		injectedLogger = taskLoggerFromContext(parentCtx)
@@ -944,9 +1374,12 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		Model:           taskSpec.Model,
 		ReasoningEffort: taskSpec.ReasoningEffort,
 		SkipPermissions: taskSpec.SkipPermissions,
+		Yolo:            taskSpec.Yolo,
 		Backend:         defaultBackendName,
 		AllowedTools:    taskSpec.AllowedTools,
 		DisallowedTools: taskSpec.DisallowedTools,
+		ClaudeAllowFile: taskSpec.ClaudeAllowFile,
+		SystemPrompt:    taskSpec.SystemPrompt,
 	}
 
 	commandName := strings.TrimSpace(defaultCommandName)
@@ -995,6 +1428,18 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		cfg.WorkDir = paths.Dir
 		logInfo(fmt.Sprintf("Using worktree: %s (task_id: %s, branch: %s)", paths.Dir, paths.TaskID, paths.Branch))
 	}
+	result.WorkDir = cfg.WorkDir
+	if absDir, err := filepath.Abs(cfg.WorkDir); err == nil {
+		result.WorkDir = absDir
+	}
+
+	if cfg.Mode != "resume" {
+		if err := ensureWorkdir(cfg.WorkDir); err != nil {
+			result.ExitCode = exitWorkdirMissing
+			result.Error = err.Error()
+			return result
+		}
+	}
 
 	if cfg.Mode == "resume" && strings.TrimSpace(cfg.SessionID) == "" {
 		result.ExitCode = 1
@@ -1111,14 +1556,43 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	ctx := parentCtx
 	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
 	defer cancel()
-	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	if !signalsHandled(parentCtx) {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+	}
 
+	// attachStderr appends the captured stderr tail (the backend's own reason
+	// for failing, usually) to msg; an empty tail is left out.
 	attachStderr := func(msg string) string {
-		return fmt.Sprintf("%s; stderr: %s", msg, stderrBuf.String())
+		tail := strings.TrimSpace(stderrBuf.String())
+		if tail == "" {
+			return msg
+		}
+		return fmt.Sprintf("%s; stderr: %s", msg, tail)
 	}
 
-	cmd := newCommandRunner(ctx, commandName, codexArgs...)
+	execName := resolveCommand(commandName)
+	if execName != commandName {
+		logInfoFn(fmt.Sprintf("Using %s binary: %s", commandName, execName))
+	}
+	if _, err := lookPathFn(execName); err != nil && (errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist)) {
+		msg := commandNotFoundMessage(commandName, execName)
+		logErrorFn(msg)
+		result.ExitCode = 127
+		result.Error = msg
+		return result
+	}
+	cmd := newCommandRunner(ctx, execName, codexArgs...)
+
+	// When the caller handles SIGINT itself (drain mode), keep the backend
+	// out of the terminal's foreground process group; otherwise Ctrl+C is
+	// delivered to every running backend at once and nothing is left to drain.
+	if signalsHandled(parentCtx) {
+		if pg, ok := cmd.(processGroupStarter); ok {
+			pg.StartInOwnProcessGroup()
+		}
+	}
 
 	if len(fileEnv) > 0 {
 		cmd.SetEnv(fileEnv)
@@ -1131,6 +1605,7 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		}
 	}
 
+	var injected map[string]string
 	if envBackend != nil {
 		baseURL, apiKey := config.ResolveBackendConfig(cfg.Backend)
 		if agentName := strings.TrimSpace(taskSpec.Agent); agentName != "" {
@@ -1141,17 +1616,27 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 				}
 			}
 		}
-		if injected := envBackend.Env(baseURL, apiKey); len(injected) > 0 {
+		if injected = envBackend.Env(baseURL, apiKey); len(injected) > 0 {
 			cmd.SetEnv(injected)
 			// Log injected env vars with masked API keys (to file and stderr)
 			for k, v := range injected {
 				msg := fmt.Sprintf("Env: %s=%s", k, maskSensitiveValue(k, v))
 				logInfoFn(msg)
-				fmt.Fprintln(os.Stderr, "  "+msg)
+				if !taskSpec.Quiet {
+					fmt.Fprintln(os.Stderr, "  "+msg)
+				}
 			}
 		}
 	}
 
+	if passed := passThroughEnv(fileEnv, injected); len(passed) > 0 {
+		msg := "Env (passed through): " + strings.Join(passed, ", ")
+		logInfoFn(msg)
+		if !taskSpec.Quiet {
+			fmt.Fprintln(os.Stderr, "  "+msg)
+		}
+	}
+
 	injectTempEnv(cmd)
 
 	if commandName == "claude" {
@@ -1165,7 +1650,9 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 			cmd.SetEnv(map[string]string{"CLAUDE_CODE_TMPDIR": nestedTmpDir})
 			defer os.RemoveAll(nestedTmpDir) //nolint:errcheck
 			logInfoFn("CLAUDE_CODE_TMPDIR: " + nestedTmpDir)
-			fmt.Fprintln(os.Stderr, "  CLAUDE_CODE_TMPDIR: "+nestedTmpDir)
+			if !taskSpec.Quiet {
+				fmt.Fprintln(os.Stderr, "  CLAUDE_CODE_TMPDIR: "+nestedTmpDir)
+			}
 		}
 
 		// Claude Code sets CLAUDECODE=1 in its child processes. If we don't
@@ -1232,18 +1719,69 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 		return result
 	}
 
-	stdoutReader := io.Reader(stdout)
+	var stdoutWriters []io.Writer
 	if stdoutLogger != nil {
-		stdoutReader = io.TeeReader(stdout, stdoutLogger)
+		stdoutWriters = append(stdoutWriters, stdoutLogger)
+	}
+	if taskSpec.StreamPassthrough {
+		// Buffer display writes so a slow stdout reader cannot stall parsing.
+		passthrough := newBoundedLineWriter(streamPassthroughOutput, streamBufferLines)
+		defer func() {
+			if dropped := passthrough.Close(streamBufferDrainTimeout); dropped > 0 {
+				// The consumer parses stdout, so tell it on stderr that the
+				// event stream has gaps.
+				msg := fmt.Sprintf("stream passthrough: dropped %d lines because stdout could not keep up", dropped)
+				logWarnFn(msg)
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", msg)
+			}
+		}()
+		stdoutWriters = append(stdoutWriters, passthrough)
+	}
+	if path := rawLogPath(taskSpec.ID); path != "" {
+		if rawLog := openRawEventLog(path, logWarnFn); rawLog != nil {
+			defer rawLog.Close()
+			logInfoFn(fmt.Sprintf("Raw backend events: %s", path))
+			stdoutWriters = append(stdoutWriters, rawLog)
+		}
+	}
+	stdoutReader := io.Reader(stdout)
+	if len(stdoutWriters) > 0 {
+		stdoutReader = io.TeeReader(stdout, io.MultiWriter(stdoutWriters...))
 	}
 
 	// Start parse goroutine BEFORE starting the command to avoid race condition
 	// where fast-completing commands close stdout before parser starts reading
 	messageSeen := make(chan struct{}, 1)
 	completeSeen := make(chan struct{}, 1)
+	stdoutEOF := make(chan struct{}, 1)
 	parseCh := make(chan parseResult, 1)
+	parseOpts := parser.ParseOptions{Validate: taskSpec.ValidateStream, MaxLineBytes: maxJSONLineBytes()}
+	if envBackend != nil {
+		parseOpts.Extractor = messageExtractorFor(envBackend.Name())
+	}
+	if taskSpec.StreamMessages && !taskSpec.StreamPassthrough {
+		streamer := newMessageStreamer(streamMessagesOutput)
+		defer streamer.finish()
+		parseOpts.OnText = streamer.text
+	}
+	if !silent && showReasoning() {
+		parseOpts.OnReasoning = reasoningPrinter(os.Stderr, commandName)
+	}
+	activity := newActivityTracker(time.Now())
+	parseOpts.OnEvent = activity.event
+	var reconnectAbort <-chan string
+	if limit := maxReconnects(); limit > 0 {
+		guard := newReconnectGuard(limit, reconnectWindow)
+		parseOpts.OnReconnect = guard.observe
+		reconnectAbort = guard.abort
+	}
+	plainOut := &tailBuffer{limit: plainOutputCaptureLimit}
+	parseOpts.OnPlainLine = func(line []byte) {
+		_, _ = plainOut.Write(line)
+		_, _ = plainOut.Write([]byte{'\n'})
+	}
 	go func() {
-		msg, tid := parseJSONStreamInternal(stdoutReader, logWarnFn, logInfoFn, func() {
+		streamRes := parseJSONStreamWithOptions(stdoutReader, logWarnFn, logInfoFn, func() {
 			select {
 			case messageSeen <- struct{}{}:
 			default:
@@ -1253,24 +1791,28 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 			case completeSeen <- struct{}{}:
 			default:
 			}
-		})
-		select {
-		case completeSeen <- struct{}{}:
-		default:
-		}
-		parseCh <- parseResult{message: msg, threadID: tid}
+		}, parseOpts)
+		stdoutEOF <- struct{}{}
+		msg := postProcessMessage(envBackend, streamRes.Message)
+		parseCh <- parseResult{message: msg, threadID: streamRes.ThreadID, errors: streamRes.Errors, fileChanges: streamRes.FileChanges, usage: streamRes.Usage, plainText: strings.TrimSpace(plainOut.String())}
 	}()
 
-	logInfoFn(fmt.Sprintf("Starting %s with args: %s %s...", commandName, commandName, strings.Join(codexArgs[:min(5, len(codexArgs))], " ")))
+	logInfoFn(fmt.Sprintf("Starting %s with args: %s %s...", commandName, commandName, utils.RedactSecrets(strings.Join(codexArgs[:min(5, len(codexArgs))], " "))))
 
-	if err := cmd.Start(); err != nil {
+	spawnStart := time.Now()
+	err = cmd.Start()
+	result.SpawnDuration = time.Since(spawnStart)
+	defer func() {
+		result.DurationMs = time.Since(spawnStart).Milliseconds()
+	}()
+	if err != nil {
 		closeWithReason(stdout, "start-failed")
 		closeWithReason(stderr, "start-failed")
 		if stdinPipe != nil {
 			_ = stdinPipe.Close()
 		}
 		if strings.Contains(err.Error(), "executable file not found") {
-			msg := fmt.Sprintf("%s command not found in PATH", commandName)
+			msg := fmt.Sprintf("%s command not found in PATH", execName)
 			logErrorFn(msg)
 			result.ExitCode = 127
 			result.Error = attachStderr(msg)
@@ -1283,6 +1825,13 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	}
 
 	logInfoFn(fmt.Sprintf("Starting %s with PID: %d", commandName, cmd.Process().Pid()))
+	stopHeartbeat := startHeartbeat(heartbeatInterval(), activity, func(msg string) {
+		logInfoFn(msg)
+		if !silent {
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", commandName, prefixMsg(msg))
+		}
+	})
+	defer stopHeartbeat()
 	if logger != nil {
 		logInfoFn(fmt.Sprintf("Log capturing to: %s", logger.Path()))
 	}
@@ -1308,10 +1857,17 @@ func RunCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, backe
 	waitCh := make(chan error, 1)
 	go func() { waitCh <- cmd.Wait() }()
 
+	// The fallback kill never fires before the SIGTERM grace has run out.
+	killWait := forceKillWaitTimeout
+	if grace := forceKillGrace(); grace > killWait {
+		killWait = grace
+	}
+
 	var (
 		waitErr              error
 		forceKillTimer       *forceKillTimer
 		ctxCancelled         bool
+		reconnectFailure     string
 		messageTimer         *time.Timer
 		messageTimerCh       <-chan time.Time
 		forcedAfterComplete  bool
@@ -1340,7 +1896,27 @@ waitLoop:
 				case err := <-waitCh:
 					waitErr = err
 					break waitLoop
-				case <-time.After(forceKillWaitTimeout):
+				case <-time.After(killWait):
+					if proc := cmd.Process(); proc != nil {
+						_ = proc.Kill()
+					}
+				}
+			}
+		case reason := <-reconnectAbort:
+			reconnectFailure = reason
+			logErrorFn(fmt.Sprintf("Aborting %s: %s", commandName, reason))
+			if !terminated {
+				if timer := terminateCommandFn(cmd); timer != nil {
+					forceKillTimer = timer
+					terminated = true
+				}
+			}
+			for {
+				select {
+				case err := <-waitCh:
+					waitErr = err
+					break waitLoop
+				case <-time.After(killWait):
 					if proc := cmd.Process(); proc != nil {
 						_ = proc.Kill()
 					}
@@ -1364,7 +1940,7 @@ waitLoop:
 				case err := <-waitCh:
 					waitErr = err
 					break waitLoop
-				case <-time.After(forceKillWaitTimeout):
+				case <-time.After(killWait):
 					if proc := cmd.Process(); proc != nil {
 						_ = proc.Kill()
 					}
@@ -1377,6 +1953,21 @@ waitLoop:
 			}
 			messageTimer = time.NewTimer(postMessageTerminateDelay)
 			messageTimerCh = messageTimer.C
+		case <-stdoutEOF:
+			// stdout is closed but the process may linger; give it a short
+			// grace to exit on its own before terminating it.
+			completeSeenObserved = true
+			stdoutEOF = nil
+			grace := postEOFGrace()
+			if messageTimer != nil {
+				// Only shorten an already running completion timer.
+				if grace < postMessageTerminateDelay && messageTimer.Stop() {
+					messageTimer.Reset(grace)
+				}
+				continue
+			}
+			messageTimer = time.NewTimer(grace)
+			messageTimerCh = messageTimer.C
 		case <-messageSeen:
 			messageSeenObserved = true
 		}
@@ -1394,12 +1985,16 @@ waitLoop:
 	if forceKillTimer != nil {
 		forceKillTimer.Stop()
 	}
+	result.BackendExitCode = backendExitCode(waitErr)
 
 	var parsed parseResult
 	switch {
 	case ctxCancelled:
 		closeWithReason(stdout, stdoutCloseReasonCtx)
 		parsed = <-parseCh
+	case reconnectFailure != "":
+		closeWithReason(stdout, "reconnect-abort")
+		parsed = <-parseCh
 	case messageSeenObserved || completeSeenObserved:
 		closeWithReason(stdout, stdoutCloseReasonWait)
 		parsed = <-parseCh
@@ -1428,6 +2023,15 @@ waitLoop:
 	// We use StderrPipe and drain ourselves to avoid that deadlock class (common when children inherit pipes).
 	<-stderrDone
 
+	if reconnectFailure != "" {
+		result.ExitCode = exitTooManyReconnects
+		result.Error = attachStderr(fmt.Sprintf("%s aborted after %s", commandName, reconnectFailure))
+		result.Message = parsed.message
+		result.SessionID = parsed.threadID
+		result.StreamErrors = parsed.errors
+		return result
+	}
+
 	if ctxErr := ctx.Err(); ctxErr != nil {
 		if errors.Is(ctxErr, context.DeadlineExceeded) {
 			result.ExitCode = 124
@@ -1451,6 +2055,9 @@ waitLoop:
 				// Preserve parsed output when the backend exits non-zero (e.g. API error with stream-json output).
 				result.Message = parsed.message
 				result.SessionID = parsed.threadID
+				result.StreamErrors = parsed.errors
+				result.ChangedFiles = parsed.fileChanges
+				result.setUsage(parsed.usage)
 				if stdoutLogger != nil {
 					stdoutLogger.Flush()
 				}
@@ -1468,6 +2075,10 @@ waitLoop:
 
 	message := parsed.message
 	threadID := parsed.threadID
+	if message == "" && parsed.plainText != "" {
+		logWarnFn(fmt.Sprintf("%s produced no JSON events; using its plain-text output as the message", commandName))
+		message = parsed.plainText
+	}
 	if message == "" {
 		logErrorFn(fmt.Sprintf("%s completed without agent_message output", commandName))
 		result.ExitCode = 1
@@ -1485,10 +2096,27 @@ waitLoop:
 	result.ExitCode = 0
 	result.Message = message
 	result.SessionID = threadID
+	result.StreamErrors = parsed.errors
+	result.ChangedFiles = parsed.fileChanges
+	result.setUsage(parsed.usage)
 	if result.LogPath == "" && injectedLogger != nil {
 		result.LogPath = injectedLogger.Path()
 	}
 
+	if taskSpec.FailOnTurnFailed && len(parsed.errors) > 0 {
+		msg := fmt.Sprintf("%s reported a failed turn: %s", commandName, parsed.errors[len(parsed.errors)-1])
+		logErrorFn(msg)
+		result.ExitCode = 1
+		result.Error = attachStderr(msg)
+	}
+
+	if taskSpec.FailIfNoFilesChanged && result.ExitCode == 0 && len(parsed.fileChanges) == 0 {
+		msg := fmt.Sprintf("%s finished without reporting any file changes", commandName)
+		logErrorFn(msg)
+		result.ExitCode = 1
+		result.Error = attachStderr(msg)
+	}
+
 	return result
 }
 
@@ -1508,6 +2136,21 @@ func injectTempEnv(cmd commandRunner) {
 	cmd.SetEnv(env)
 }
 
+// backendExitCode turns the error from waiting on the backend into its raw
+// exit status: 0 for a clean exit, the ExitError code otherwise, and nil when
+// the wait failed for a reason other than the process exiting.
+func backendExitCode(waitErr error) *int {
+	code := 0
+	if waitErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(waitErr, &exitErr) {
+			return nil
+		}
+		code = exitErr.ExitCode()
+	}
+	return &code
+}
+
 func cancelReason(commandName string, ctx context.Context) string {
 	if ctx == nil {
 		return "Context cancelled"
@@ -1566,12 +2209,22 @@ func terminateCommand(cmd commandRunner) *forceKillTimer {
 		return nil
 	}
 
-	_ = sendTermSignal(proc)
+	rc, grouped := cmd.(*realCmd)
+	grouped = grouped && rc.ownGroup
+	if grouped {
+		_ = signalProcessGroup(proc, false)
+	} else {
+		_ = sendTermSignal(proc)
+	}
 
 	done := make(chan struct{}, 1)
-	timer := time.AfterFunc(time.Duration(forceKillDelay.Load())*time.Second, func() {
+	timer := time.AfterFunc(forceKillGrace(), func() {
 		if p := cmd.Process(); p != nil {
-			_ = p.Kill()
+			if grouped {
+				_ = signalProcessGroup(p, true)
+			} else {
+				_ = p.Kill()
+			}
 		}
 		close(done)
 	})
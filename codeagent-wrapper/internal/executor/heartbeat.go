@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHeartbeatInterval is how long a backend may go without emitting an
+// event before the wrapper reports that it is still running.
+const defaultHeartbeatInterval = 60 * time.Second
+
+// heartbeatInterval reads CODEAGENT_HEARTBEAT: a Go duration ("30s") or a
+// number of seconds, where 0 or "off" disables the heartbeat. Unset or
+// unparsable values use defaultHeartbeatInterval.
+func heartbeatInterval() time.Duration {
+	raw := strings.TrimSpace(strings.ToLower(os.Getenv("CODEAGENT_HEARTBEAT")))
+	switch raw {
+	case "":
+		return defaultHeartbeatInterval
+	case "off", "false", "no":
+		return 0
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return d
+	}
+	if secs, err := strconv.Atoi(raw); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultHeartbeatInterval
+}
+
+// activityTracker remembers the last backend event the parser decoded.
+type activityTracker struct {
+	mu       sync.Mutex
+	start    time.Time
+	lastType string
+	lastAt   time.Time
+}
+
+func newActivityTracker(start time.Time) *activityTracker {
+	return &activityTracker{start: start, lastAt: start}
+}
+
+func (a *activityTracker) event(eventType string) {
+	a.mu.Lock()
+	a.lastType = eventType
+	a.lastAt = time.Now()
+	a.mu.Unlock()
+}
+
+// idleMessage describes how long the backend has been quiet at now.
+func (a *activityTracker) idleMessage(now time.Time) (string, time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	idle := now.Sub(a.lastAt)
+	if a.lastType == "" {
+		return fmt.Sprintf("still running, no events yet after %s", formatIdle(now.Sub(a.start))), idle
+	}
+	return fmt.Sprintf("still running, last event %s %s ago", a.lastType, formatIdle(idle)), idle
+}
+
+func formatIdle(d time.Duration) string {
+	if d >= time.Second {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// startHeartbeat calls report whenever the backend has been quiet for at
+// least interval, at most once per interval. The returned func stops it.
+func startHeartbeat(interval time.Duration, tracker *activityTracker, report func(string)) (stop func()) {
+	if interval <= 0 || tracker == nil || report == nil {
+		return func() {}
+	}
+	check := interval / 4
+	if check < 10*time.Millisecond {
+		check = 10 * time.Millisecond
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		var lastReport time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				msg, idle := tracker.idleMessage(now)
+				if idle < interval || now.Sub(lastReport) < interval {
+					continue
+				}
+				lastReport = now
+				report(msg)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatInterval(t *testing.T) {
+	for raw, want := range map[string]time.Duration{
+		"":      defaultHeartbeatInterval,
+		"30s":   30 * time.Second,
+		"45":    45 * time.Second,
+		"0":     0,
+		"off":   0,
+		"bogus": defaultHeartbeatInterval,
+		"-5s":   defaultHeartbeatInterval,
+	} {
+		t.Setenv("CODEAGENT_HEARTBEAT", raw)
+		if got := heartbeatInterval(); got != want {
+			t.Errorf("heartbeatInterval(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	var mu sync.Mutex
+	var reports []string
+	report := func(msg string) {
+		mu.Lock()
+		reports = append(reports, msg)
+		mu.Unlock()
+	}
+	snapshot := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), reports...)
+	}
+
+	tracker := newActivityTracker(time.Now())
+	stop := startHeartbeat(40*time.Millisecond, tracker, report)
+	time.Sleep(70 * time.Millisecond)
+	got := snapshot()
+	if len(got) == 0 || !strings.HasPrefix(got[0], "still running, no events yet after ") {
+		t.Fatalf("reports before any event = %q, want a no-events heartbeat", got)
+	}
+
+	tracker.event("turn.started")
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	got = snapshot()
+	if !strings.HasPrefix(got[len(got)-1], "still running, last event turn.started ") || !strings.HasSuffix(got[len(got)-1], " ago") {
+		t.Fatalf("last report = %q, want it to name the last event", got[len(got)-1])
+	}
+
+	count := len(got)
+	time.Sleep(100 * time.Millisecond)
+	if len(snapshot()) != count {
+		t.Fatalf("heartbeat kept reporting after stop")
+	}
+	stop()
+}
+
+func TestStartHeartbeat_QuietWhileActive(t *testing.T) {
+	tracker := newActivityTracker(time.Now())
+	reported := make(chan string, 10)
+	stop := startHeartbeat(60*time.Millisecond, tracker, func(msg string) { reported <- msg })
+	defer stop()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		tracker.event("item.completed")
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case msg := <-reported:
+		t.Fatalf("unexpected heartbeat while events keep arriving: %q", msg)
+	default:
+	}
+
+	if stop := startHeartbeat(0, tracker, func(string) { t.Fatal("disabled heartbeat reported") }); stop == nil {
+		t.Fatal("startHeartbeat(0) should return a no-op stop func")
+	} else {
+		stop()
+	}
+}
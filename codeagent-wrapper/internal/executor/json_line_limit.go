@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxJSONLineBytes reads CODEAGENT_MAX_JSON_LINE, the longest backend event
+// line in bytes that is decoded rather than skipped, as a
+// parser.ParseOptions.MaxLineBytes value: unset keeps the parser default and
+// 0 lifts the cap to the parser's hard ceiling (256 MiB).
+func maxJSONLineBytes() int {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_MAX_JSON_LINE"))
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		logWarn(fmt.Sprintf("Invalid CODEAGENT_MAX_JSON_LINE %q, using the default limit", raw))
+		return 0
+	}
+	if n == 0 {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,22 @@
+package executor
+
+import "testing"
+
+func TestMaxJSONLineBytes(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 0},
+		{"0", -1},
+		{"1048576", 1048576},
+		{"-5", 0},
+		{"big", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("CODEAGENT_MAX_JSON_LINE", tt.env)
+		if got := maxJSONLineBytes(); got != tt.want {
+			t.Errorf("maxJSONLineBytes() with %q = %d, want %d", tt.env, got, tt.want)
+		}
+	}
+}
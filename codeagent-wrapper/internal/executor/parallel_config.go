@@ -2,19 +2,40 @@ package executor
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	config "codeagent-wrapper/internal/config"
 )
 
+const (
+	defaultTaskSeparator    = "---TASK---"
+	defaultContentSeparator = "---CONTENT---"
+	delimiterHeaderPrefix   = "---DELIMITER:"
+)
+
+// ParseParallelConfig reads a parallel task list. Input starting with "{" or
+// "[" is JSON (see parseParallelConfigJSON); anything else uses the
+// ---TASK---/---CONTENT--- block format, whose separators can be replaced by
+// a "---DELIMITER: <task> <content>---" first line (see
+// parseDelimiterHeader).
 func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 	trimmed := bytes.TrimSpace(data)
 	if len(trimmed) == 0 {
 		return nil, fmt.Errorf("parallel config is empty")
 	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		return parseParallelConfigJSON(trimmed)
+	}
+
+	taskSep, contentSep, body, err := parseDelimiterHeader(string(trimmed))
+	if err != nil {
+		return nil, err
+	}
 
-	tasks := strings.Split(string(trimmed), "---TASK---")
+	tasks := strings.Split(body, taskSep)
 	var cfg ParallelConfig
 	seen := make(map[string]struct{})
 
@@ -26,9 +47,9 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 		}
 		taskIndex++
 
-		parts := strings.SplitN(taskBlock, "---CONTENT---", 2)
+		parts := strings.SplitN(taskBlock, contentSep, 2)
 		if len(parts) != 2 {
-			return nil, fmt.Errorf("task block #%d missing ---CONTENT--- separator", taskIndex)
+			return nil, fmt.Errorf("task block #%d missing %s separator", taskIndex, contentSep)
 		}
 
 		meta := strings.TrimSpace(parts[0])
@@ -38,7 +59,8 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 		agentSpecified := false
 		for _, line := range strings.Split(meta, "\n") {
 			line = strings.TrimSpace(line)
-			if line == "" {
+			// Blank lines and "# ..." comments carry no metadata.
+			if line == "" || strings.HasPrefix(line, "#") {
 				continue
 			}
 			kv := strings.SplitN(line, ":", 2)
@@ -88,6 +110,12 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 						task.Dependencies = append(task.Dependencies, dep)
 					}
 				}
+			case "priority":
+				priority, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("task block #%d has invalid priority %q: must be an integer", taskIndex, value)
+				}
+				task.Priority = priority
 			case "skills":
 				for _, s := range strings.Split(value, ",") {
 					s = strings.TrimSpace(s)
@@ -98,56 +126,173 @@ func ParseParallelConfig(data []byte) (*ParallelConfig, error) {
 			}
 		}
 
-		if task.Mode == "" {
-			task.Mode = "new"
+		task.Task = content
+		if err := finishParallelTask(&task, fmt.Sprintf("task block #%d", taskIndex), "content", agentSpecified, seen); err != nil {
+			return nil, err
 		}
+		cfg.Tasks = append(cfg.Tasks, task)
+	}
 
-		if agentSpecified {
-			if strings.TrimSpace(task.Agent) == "" {
-				return nil, fmt.Errorf("task block #%d has empty agent field", taskIndex)
-			}
-			if err := config.ValidateAgentName(task.Agent); err != nil {
-				return nil, fmt.Errorf("task block #%d invalid agent name: %w", taskIndex, err)
-			}
-			backend, model, promptFile, reasoning, _, _, _, allowedTools, disallowedTools, err := config.ResolveAgentConfig(task.Agent)
-			if err != nil {
-				return nil, fmt.Errorf("task block #%d failed to resolve agent %q: %w", taskIndex, task.Agent, err)
-			}
-			if task.Backend == "" {
-				task.Backend = backend
-			}
-			if task.Model == "" {
-				task.Model = model
-			}
-			if task.ReasoningEffort == "" {
-				task.ReasoningEffort = reasoning
-			}
-			task.PromptFile = promptFile
-			task.AllowedTools = allowedTools
-			task.DisallowedTools = disallowedTools
-		}
+	if len(cfg.Tasks) == 0 {
+		return nil, fmt.Errorf("no tasks found")
+	}
+
+	return &cfg, nil
+}
+
+// parseDelimiterHeader returns the task and content separators for a block
+// format config and the text after the optional header. A first line of the
+// form "---DELIMITER: @@@TASK@@@ @@@CONTENT@@@---" replaces the default
+// ---TASK---/---CONTENT--- pair, for task content that has to contain those
+// strings literally.
+func parseDelimiterHeader(text string) (taskSep, contentSep, body string, err error) {
+	if !strings.HasPrefix(text, delimiterHeaderPrefix) {
+		return defaultTaskSeparator, defaultContentSeparator, text, nil
+	}
+	header, rest, _ := strings.Cut(text, "\n")
+	header = strings.TrimSpace(header)
+	inner, ok := strings.CutSuffix(strings.TrimPrefix(header, delimiterHeaderPrefix), "---")
+	fields := strings.Fields(inner)
+	if !ok || len(fields) != 2 {
+		return "", "", "", fmt.Errorf("invalid delimiter header %q: want %s <task separator> <content separator>---", header, delimiterHeaderPrefix)
+	}
+	if fields[0] == fields[1] {
+		return "", "", "", fmt.Errorf("invalid delimiter header %q: task and content separators must differ", header)
+	}
+	return fields[0], fields[1], rest, nil
+}
+
+// parseParallelConfigJSON accepts either a ParallelConfig object
+// ({"backend": ..., "tasks": [...]}) or a bare array of tasks, and applies
+// the same defaults and checks as the block format. Unknown fields are
+// rejected so a misspelled key does not silently drop a setting.
+func parseParallelConfigJSON(data []byte) (*ParallelConfig, error) {
+	var cfg ParallelConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var err error
+	if data[0] == '[' {
+		err = dec.Decode(&cfg.Tasks)
+	} else {
+		err = dec.Decode(&cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON parallel config: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid JSON parallel config: unexpected data after the config")
+	}
 
-		if task.ID == "" {
-			return nil, fmt.Errorf("task block #%d missing id field", taskIndex)
+	seen := make(map[string]struct{})
+	for i := range cfg.Tasks {
+		task := &cfg.Tasks[i]
+		label := fmt.Sprintf("task #%d", i+1)
+		task.ID = strings.TrimSpace(task.ID)
+		task.Task = strings.TrimSpace(task.Task)
+		switch strings.TrimSpace(task.WorkDir) {
+		case "":
+			task.WorkDir = defaultWorkdir
+		case "-":
+			return nil, fmt.Errorf("%s has invalid workdir: '-' is not a valid directory path", label)
 		}
-		if content == "" {
-			return nil, fmt.Errorf("task block #%d (%q) missing content", taskIndex, task.ID)
+		if task.SessionID != "" {
+			task.Mode = "resume"
 		}
-		if task.Mode == "resume" && strings.TrimSpace(task.SessionID) == "" {
-			return nil, fmt.Errorf("task block #%d (%q) has empty session_id", taskIndex, task.ID)
+		if task.Backend == "" {
+			task.Backend = cfg.GlobalBackend
 		}
-		if _, exists := seen[task.ID]; exists {
-			return nil, fmt.Errorf("task block #%d has duplicate id: %s", taskIndex, task.ID)
+		if err := finishParallelTask(task, label, "task", task.Agent != "", seen); err != nil {
+			return nil, err
 		}
-
-		task.Task = content
-		cfg.Tasks = append(cfg.Tasks, task)
-		seen[task.ID] = struct{}{}
 	}
 
 	if len(cfg.Tasks) == 0 {
 		return nil, fmt.Errorf("no tasks found")
 	}
-
 	return &cfg, nil
 }
+
+// finishParallelTask resolves the task's agent and enforces the invariants
+// shared by both config formats: a non-empty unique id, a non-empty task
+// body and a session id for resumed tasks. label names the task in errors and
+// contentName names the field holding its body.
+func finishParallelTask(task *TaskSpec, label, contentName string, agentSpecified bool, seen map[string]struct{}) error {
+	if task.Mode == "" {
+		task.Mode = "new"
+	}
+
+	if agentSpecified {
+		if strings.TrimSpace(task.Agent) == "" {
+			return fmt.Errorf("%s has empty agent field", label)
+		}
+		if err := config.ValidateAgentName(task.Agent); err != nil {
+			return fmt.Errorf("%s invalid agent name: %w", label, err)
+		}
+		backend, model, promptFile, reasoning, _, _, yolo, allowedTools, disallowedTools, err := config.ResolveAgentConfig(task.Agent)
+		if err != nil {
+			return fmt.Errorf("%s failed to resolve agent %q: %w", label, task.Agent, err)
+		}
+		if task.Backend == "" {
+			task.Backend = backend
+		}
+		if task.Model == "" {
+			task.Model = model
+		}
+		if task.ReasoningEffort == "" {
+			task.ReasoningEffort = reasoning
+		}
+		if task.PromptFile == "" {
+			task.PromptFile = promptFile
+		}
+		task.Yolo = task.Yolo || yolo
+		if len(task.AllowedTools) == 0 {
+			task.AllowedTools = allowedTools
+		}
+		if len(task.DisallowedTools) == 0 {
+			task.DisallowedTools = disallowedTools
+		}
+	}
+
+	if task.ID == "" {
+		return fmt.Errorf("%s missing id field", label)
+	}
+	if task.Task == "" {
+		return fmt.Errorf("%s (%q) missing %s", label, task.ID, contentName)
+	}
+	if task.Mode == "resume" {
+		if strings.TrimSpace(task.SessionID) == "" {
+			return fmt.Errorf("%s (%q) has empty session_id", label, task.ID)
+		}
+		sessionID, err := config.NormalizeSessionID(task.SessionID)
+		if err != nil {
+			return fmt.Errorf("%s (%q): %w", label, task.ID, err)
+		}
+		task.SessionID = sessionID
+		// A resumed session continues in the workdir it ran in; a fresh
+		// worktree branch has none of its state.
+		if task.Worktree {
+			return fmt.Errorf("%s (%q) cannot combine session_id with worktree: true; a resumed session cannot move into a new worktree", label, task.ID)
+		}
+	}
+	if len(task.Dependencies) > 0 {
+		deps := make([]string, 0, len(task.Dependencies))
+		seenDeps := make(map[string]struct{}, len(task.Dependencies))
+		for _, dep := range task.Dependencies {
+			if dep == task.ID {
+				return fmt.Errorf("%s (%q) depends on itself", label, task.ID)
+			}
+			if _, dup := seenDeps[dep]; dup {
+				logWarn(fmt.Sprintf("%s (%q) lists dependency %q more than once; ignoring the repeat", label, task.ID, dep))
+				continue
+			}
+			seenDeps[dep] = struct{}{}
+			deps = append(deps, dep)
+		}
+		task.Dependencies = deps
+	}
+	if _, exists := seen[task.ID]; exists {
+		return fmt.Errorf("%s has duplicate id: %s", label, task.ID)
+	}
+	seen[task.ID] = struct{}{}
+	return nil
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// passThroughEnv reads CODEAGENT_PASS_ENV, a comma-separated list of variable
+// names the backend relies on, and returns those set in the wrapper's
+// environment. The backend already inherits that environment, so the list does
+// not change what it sees; it documents the dependency and surfaces problems:
+// unset names are skipped with a warning, and names in one of the reserved maps
+// (settings or backend injected env) are reported as overridden by the wrapper.
+// Only names are logged, never values.
+func passThroughEnv(reserved ...map[string]string) []string {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_PASS_ENV"))
+	if raw == "" {
+		return nil
+	}
+
+	var passed []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if isReservedEnv(name, reserved) {
+			logWarn(fmt.Sprintf("CODEAGENT_PASS_ENV: %s is set by the wrapper for the backend; the wrapper's value is used", name))
+			continue
+		}
+		if _, ok := os.LookupEnv(name); !ok {
+			logWarn(fmt.Sprintf("CODEAGENT_PASS_ENV: %s is not set; skipping", name))
+			continue
+		}
+		passed = append(passed, name)
+	}
+	return passed
+}
+
+func isReservedEnv(name string, reserved []map[string]string) bool {
+	for _, env := range reserved {
+		if _, ok := env[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	ilogger "codeagent-wrapper/internal/logger"
+)
+
+// rawLogPath returns where CODEAGENT_RAW_LOG should go for a task. Parallel
+// tasks get the task id appended to the file name so they do not truncate
+// each other's logs.
+func rawLogPath(taskID string) string {
+	path := strings.TrimSpace(os.Getenv("CODEAGENT_RAW_LOG"))
+	if path == "" || taskID == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + ilogger.SanitizeLogSuffix(taskID) + ext
+}
+
+// rawEventLog tees the backend's stdout into a file untouched. Write never
+// fails: a write error is logged once and further output is discarded, so
+// a full disk cannot interrupt parsing.
+type rawEventLog struct {
+	mu     sync.Mutex
+	f      *os.File
+	warnFn func(string)
+}
+
+func openRawEventLog(path string, warnFn func(string)) *rawEventLog {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		warnFn(fmt.Sprintf("CODEAGENT_RAW_LOG: %v", err))
+		return nil
+	}
+	return &rawEventLog{f: f, warnFn: warnFn}
+}
+
+func (l *rawEventLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return len(p), nil
+	}
+	if _, err := l.f.Write(p); err != nil {
+		l.warnFn(fmt.Sprintf("CODEAGENT_RAW_LOG: %v; raw event logging disabled", err))
+		_ = l.f.Close()
+		l.f = nil
+	}
+	return len(p), nil
+}
+
+func (l *rawEventLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	if err := l.f.Close(); err != nil {
+		l.warnFn(fmt.Sprintf("CODEAGENT_RAW_LOG: %v", err))
+	}
+	l.f = nil
+}
@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// showReasoning reports whether CODEAGENT_SHOW_REASONING asks for the
+// backend's reasoning to be printed. It is off by default: reasoning is
+// verbose and has never been part of the wrapper's output.
+func showReasoning() bool {
+	return config.EnvFlagEnabled("CODEAGENT_SHOW_REASONING")
+}
+
+// reasoningPrinter returns a parser OnReasoning hook that writes every line
+// of reasoning to out as "[<command>:think] <line>", so the lines can be
+// filtered out of stderr with grep.
+func reasoningPrinter(out io.Writer, commandName string) func(string) {
+	prefix := fmt.Sprintf("[%s:think] ", commandName)
+	return func(text string) {
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			if line = strings.TrimRight(line, " \t\r"); line != "" {
+				fmt.Fprintln(out, prefix+line)
+			}
+		}
+	}
+}
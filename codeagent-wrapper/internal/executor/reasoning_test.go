@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShowReasoning(t *testing.T) {
+	for raw, want := range map[string]bool{"": false, "0": false, "1": true, "true": true} {
+		t.Setenv("CODEAGENT_SHOW_REASONING", raw)
+		if got := showReasoning(); got != want {
+			t.Errorf("showReasoning(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestReasoningPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	emit := reasoningPrinter(&buf, "codex")
+	emit("**Planning**\n\nread the tests first  \n")
+	want := "[codex:think] **Planning**\n[codex:think] read the tests first\n"
+	if buf.String() != want {
+		t.Fatalf("output = %q, want %q", buf.String(), want)
+	}
+}
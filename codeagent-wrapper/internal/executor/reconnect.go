@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reconnectWindow bounds how far apart the reconnects counted against
+// CODEAGENT_MAX_RECONNECTS may be.
+const reconnectWindow = 5 * time.Minute
+
+// maxReconnects reads CODEAGENT_MAX_RECONNECTS: how many consecutive
+// "Reconnecting..." events within reconnectWindow are tolerated before the
+// run is aborted. 0 (the default) disables the check.
+func maxReconnects() int {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_MAX_RECONNECTS"))
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		logWarn(fmt.Sprintf("Invalid CODEAGENT_MAX_RECONNECTS %q, reconnect limit disabled", raw))
+		return 0
+	}
+	return n
+}
+
+// reconnectGuard counts a backend's consecutive reconnect attempts and
+// reports on abort once more than max happened within window.
+type reconnectGuard struct {
+	max    int
+	window time.Duration
+	abort  chan string
+
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func newReconnectGuard(max int, window time.Duration) *reconnectGuard {
+	return &reconnectGuard{max: max, window: window, abort: make(chan string, 1)}
+}
+
+// observe is a parser.ParseOptions.OnReconnect callback.
+func (g *reconnectGuard) observe(consecutive int, message string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if consecutive <= 1 {
+		g.times = g.times[:0]
+	}
+	g.times = append(g.times, now)
+	for len(g.times) > 0 && now.Sub(g.times[0]) > g.window {
+		g.times = g.times[1:]
+	}
+	if len(g.times) <= g.max {
+		return
+	}
+	reason := fmt.Sprintf("%d reconnects within %s (CODEAGENT_MAX_RECONNECTS=%d), last: %s", len(g.times), formatIdle(now.Sub(g.times[0])), g.max, message)
+	select {
+	case g.abort <- reason:
+	default:
+	}
+}
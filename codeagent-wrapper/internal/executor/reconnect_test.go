@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconnectGuard(t *testing.T) {
+	aborted := func(g *reconnectGuard) string {
+		select {
+		case reason := <-g.abort:
+			return reason
+		default:
+			return ""
+		}
+	}
+
+	g := newReconnectGuard(1, 50*time.Millisecond)
+	g.observe(1, "Reconnecting... 1/5")
+	time.Sleep(80 * time.Millisecond)
+	g.observe(2, "Reconnecting... 2/5")
+	if reason := aborted(g); reason != "" {
+		t.Fatalf("reconnects outside the window should not abort, got %q", reason)
+	}
+	g.observe(3, "Reconnecting... 3/5")
+	if reason := aborted(g); !strings.Contains(reason, "2 reconnects") {
+		t.Fatalf("abort reason = %q, want 2 reconnects", reason)
+	}
+
+	// A new streak starts from scratch.
+	g = newReconnectGuard(1, time.Minute)
+	g.observe(1, "Reconnecting... 1/5")
+	g.observe(1, "Reconnecting... 1/5")
+	if reason := aborted(g); reason != "" {
+		t.Fatalf("separate streaks should not add up, got %q", reason)
+	}
+}
+
+func TestMaxReconnects(t *testing.T) {
+	for raw, want := range map[string]int{"": 0, "3": 3, "0": 0, "-1": 0, "many": 0} {
+		t.Setenv("CODEAGENT_MAX_RECONNECTS", raw)
+		if got := maxReconnects(); got != want {
+			t.Errorf("maxReconnects(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package executor
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // extractCoverageGap extracts what's missing from coverage reports.
 func extractCoverageGap(message string) string {
@@ -102,3 +106,68 @@ func extractErrorDetail(message string, maxLen int) string {
 	result := strings.Join(errorLines, " | ")
 	return safeTruncate(result, maxLen)
 }
+
+// formatUsage describes a task's elapsed time and token usage, e.g.
+// "1m23s, 10.3k tokens (9.1k in / 1.2k out)". It is empty when neither is known.
+func formatUsage(res TaskResult) string {
+	var parts []string
+	if res.DurationMs > 0 {
+		d := time.Duration(res.DurationMs) * time.Millisecond
+		if d >= time.Second {
+			d = d.Round(100 * time.Millisecond)
+		}
+		parts = append(parts, d.String())
+	}
+	if res.Tokens > 0 {
+		tokens := formatTokenCount(res.Tokens) + " tokens"
+		if res.InputTokens > 0 || res.OutputTokens > 0 {
+			tokens += fmt.Sprintf(" (%s in / %s out)", formatTokenCount(res.InputTokens), formatTokenCount(res.OutputTokens))
+		}
+		parts = append(parts, tokens)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatTokenCount abbreviates large counts the way the backends print them
+// ("10.3k", "1.2M").
+func formatTokenCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+func sumTokens(results []TaskResult) int {
+	total := 0
+	for _, res := range results {
+		total += res.Tokens
+	}
+	return total
+}
+
+// reportedFiles is the Files line of the summary report: the files the
+// backend reported through file_change events, falling back to the ones
+// mentioned in its message.
+func reportedFiles(res TaskResult) string {
+	if len(res.ChangedFiles) > 0 {
+		return formatChangedFiles(res.ChangedFiles)
+	}
+	return strings.Join(res.FilesChanged, ", ")
+}
+
+// formatChangedFiles renders changes as "a.go (add), b.go (update)".
+func formatChangedFiles(changes []FileChange) string {
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.Kind != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s)", change.Path, change.Kind))
+		} else {
+			parts = append(parts, change.Path)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
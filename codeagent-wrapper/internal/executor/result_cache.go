@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resultCacheKey hashes everything that determines a task's outcome: the
+// backend and its model settings, the session being resumed, the workdir and
+// the final task text.
+func resultCacheKey(task TaskSpec) string {
+	workdir := task.WorkDir
+	if abs, err := filepath.Abs(workdir); err == nil {
+		workdir = abs
+	}
+	h := sha256.New()
+	for _, field := range []string{
+		task.Backend,
+		task.Model,
+		task.ReasoningEffort,
+		task.Mode,
+		task.SessionID,
+		workdir,
+		task.Task,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	// Hashed only when set so keys of runs without a system prompt stay
+	// unchanged.
+	if task.SystemPrompt != "" {
+		h.Write([]byte(task.SystemPrompt))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func resultCachePath(task TaskSpec) string {
+	return filepath.Join(task.CacheDir, resultCacheKey(task)+".json")
+}
+
+// loadCachedResult returns the cached result for task, if any.
+func loadCachedResult(task TaskSpec) (TaskResult, bool) {
+	data, err := os.ReadFile(resultCachePath(task))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logWarn(fmt.Sprintf("Result cache read failed: %v", err))
+		}
+		return TaskResult{}, false
+	}
+	var result TaskResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		logWarn(fmt.Sprintf("Ignoring corrupt result cache entry %s: %v", resultCachePath(task), err))
+		return TaskResult{}, false
+	}
+	return result, true
+}
+
+// storeCachedResult writes result for task atomically.
+func storeCachedResult(task TaskSpec, result TaskResult) error {
+	result.Cached = false
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(task.CacheDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(task.CacheDir, ".result-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), resultCachePath(task)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// RunWithResultCache serves task from task.CacheDir when an identical task
+// succeeded before, and otherwise runs it and caches a successful result.
+// task must already carry its final text and resolved backend. Without a
+// CacheDir it simply calls run.
+func RunWithResultCache(task TaskSpec, run func(TaskSpec) TaskResult) TaskResult {
+	if strings.TrimSpace(task.CacheDir) == "" {
+		return run(task)
+	}
+	if !task.CacheRefresh {
+		if cached, ok := loadCachedResult(task); ok {
+			logInfo(fmt.Sprintf("Task %s: using cached result (%s)", task.ID, resultCacheKey(task)[:12]))
+			cached.TaskID = task.ID
+			cached.Cached = true
+			cached.Tokens, cached.InputTokens, cached.OutputTokens, cached.DurationMs = 0, 0, 0, 0
+			return cached
+		}
+	}
+
+	result := run(task)
+	if result.ExitCode != 0 || result.Error != "" || task.CacheNoWrite {
+		return result
+	}
+	if err := storeCachedResult(task, result); err != nil {
+		logWarn(fmt.Sprintf("Result cache write failed: %v", err))
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunWithResultCache(t *testing.T) {
+	dir := t.TempDir()
+	base := TaskSpec{ID: "t1", Task: "do it", Backend: "codex", WorkDir: t.TempDir(), CacheDir: dir}
+
+	calls := 0
+	run := func(result TaskResult) func(TaskSpec) TaskResult {
+		return func(TaskSpec) TaskResult {
+			calls++
+			return result
+		}
+	}
+	ok := TaskResult{TaskID: "t1", Message: "done", SessionID: "sid"}
+
+	if got := RunWithResultCache(base, run(ok)); got.Cached || calls != 1 {
+		t.Fatalf("first run: cached = %v, calls = %d; want a miss", got.Cached, calls)
+	}
+
+	renamed := base
+	renamed.ID = "t2"
+	got := RunWithResultCache(renamed, run(ok))
+	if !got.Cached || calls != 1 {
+		t.Fatalf("identical task: cached = %v, calls = %d; want a hit", got.Cached, calls)
+	}
+	if got.TaskID != "t2" || got.Message != "done" || got.SessionID != "sid" {
+		t.Fatalf("cached result = %+v", got)
+	}
+
+	changed := base
+	changed.Task = "do something else"
+	if got := RunWithResultCache(changed, run(ok)); got.Cached || calls != 2 {
+		t.Fatalf("changed task: cached = %v, calls = %d; want a miss", got.Cached, calls)
+	}
+
+	otherBackend := base
+	otherBackend.Backend = "claude"
+	if got := RunWithResultCache(otherBackend, run(ok)); got.Cached || calls != 3 {
+		t.Fatalf("changed backend: cached = %v, calls = %d; want a miss", got.Cached, calls)
+	}
+
+	refresh := base
+	refresh.CacheRefresh = true
+	if got := RunWithResultCache(refresh, run(TaskResult{Message: "fresh"})); got.Cached || calls != 4 {
+		t.Fatalf("refresh: cached = %v, calls = %d; want the backend to run", got.Cached, calls)
+	}
+	if got := RunWithResultCache(base, run(ok)); !got.Cached || got.Message != "fresh" {
+		t.Fatalf("refresh should overwrite the entry, got %+v", got)
+	}
+
+	failing := base
+	failing.Task = "fails"
+	RunWithResultCache(failing, run(TaskResult{ExitCode: 1, Error: "boom"}))
+	if got := RunWithResultCache(failing, run(TaskResult{ExitCode: 1, Error: "boom"})); got.Cached {
+		t.Fatalf("failed results must not be cached")
+	}
+
+	noWrite := base
+	noWrite.Task = "not stored"
+	noWrite.CacheNoWrite = true
+	RunWithResultCache(noWrite, run(ok))
+	if _, err := os.Stat(filepath.Join(dir, resultCacheKey(noWrite)+".json")); !os.IsNotExist(err) {
+		t.Fatalf("--no-cache-write stored a result, stat err = %v", err)
+	}
+}
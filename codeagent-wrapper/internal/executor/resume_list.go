@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+// resumeEntry is one element of the JSON form of a resume list.
+type resumeEntry struct {
+	SessionID string `json:"session_id"`
+	Task      string `json:"task"`
+	WorkDir   string `json:"workdir,omitempty"`
+}
+
+// ParseResumeList reads the --resume-file format: either a JSON array of
+// {"session_id", "task", "workdir"} objects or one "session_id<TAB>task"
+// pair per line, with blank lines and "# ..." comments skipped. Each entry
+// becomes an independent resume task whose id is its session id, so the
+// parallel report reads per session.
+func ParseResumeList(data []byte) (*ParallelConfig, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("resume file is empty")
+	}
+
+	var entries []resumeEntry
+	var labels []string
+	if trimmed[0] == '[' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON resume file: %w", err)
+		}
+		if dec.More() {
+			return nil, fmt.Errorf("invalid JSON resume file: unexpected data after the array")
+		}
+		for i := range entries {
+			labels = append(labels, fmt.Sprintf("entry #%d", i+1))
+		}
+	} else {
+		for i, line := range strings.Split(string(trimmed), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			sessionID, task, ok := strings.Cut(line, "\t")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected session_id<TAB>task", i+1)
+			}
+			entries = append(entries, resumeEntry{SessionID: sessionID, Task: task})
+			labels = append(labels, fmt.Sprintf("line %d", i+1))
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no tasks found")
+	}
+
+	var cfg ParallelConfig
+	seen := make(map[string]struct{})
+	for i, entry := range entries {
+		label := labels[i]
+		sessionID, err := config.NormalizeSessionID(entry.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+		task := TaskSpec{
+			ID:        sessionID,
+			SessionID: sessionID,
+			Mode:      "resume",
+			Task:      strings.TrimSpace(entry.Task),
+			WorkDir:   strings.TrimSpace(entry.WorkDir),
+		}
+		switch task.WorkDir {
+		case "":
+			task.WorkDir = defaultWorkdir
+		case "-":
+			return nil, fmt.Errorf("%s has invalid workdir: '-' is not a valid directory path", label)
+		}
+		if _, dup := seen[task.ID]; dup {
+			return nil, fmt.Errorf("%s resumes session %s more than once", label, task.ID)
+		}
+		if err := finishParallelTask(&task, label, "task", false, seen); err != nil {
+			return nil, err
+		}
+		cfg.Tasks = append(cfg.Tasks, task)
+	}
+	return &cfg, nil
+}
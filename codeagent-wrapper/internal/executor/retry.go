@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// taskRetryDelay is the default base pause between attempts of a retried task.
+var taskRetryDelay = time.Second
+
+// retryAfterFn waits between attempts; tests replace it to record delays.
+var retryAfterFn = time.After
+
+// maxRetryDelay caps a single pause so long retry chains cannot overflow or
+// stall for hours.
+const maxRetryDelay = 10 * time.Minute
+
+// Backoff curves for the pause between retries (--retry-backoff).
+const (
+	RetryBackoffFixed       = "fixed"       // base every time
+	RetryBackoffLinear      = "linear"      // base, 2*base, 3*base, ...
+	RetryBackoffExponential = "exponential" // base, 2*base, 4*base, ...
+)
+
+// RetryBackoff shapes the pause before each retry.
+type RetryBackoff struct {
+	// Curve is one of the RetryBackoff* constants; empty means fixed.
+	Curve string
+	// Base is the first pause; zero uses the default of one second.
+	Base time.Duration
+}
+
+// ValidateRetryBackoff normalizes a --retry-backoff value, defaulting to fixed.
+func ValidateRetryBackoff(curve string) (string, error) {
+	curve = strings.ToLower(strings.TrimSpace(curve))
+	switch curve {
+	case "":
+		return RetryBackoffFixed, nil
+	case RetryBackoffFixed, RetryBackoffLinear, RetryBackoffExponential:
+		return curve, nil
+	default:
+		return "", fmt.Errorf("invalid retry backoff %q (want %s, %s or %s)", curve, RetryBackoffFixed, RetryBackoffLinear, RetryBackoffExponential)
+	}
+}
+
+// Delay returns the pause before retry number attempt (starting at 1).
+func (b RetryBackoff) Delay(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = taskRetryDelay
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := base
+	switch b.Curve {
+	case RetryBackoffLinear:
+		if base > maxRetryDelay/time.Duration(attempt) {
+			return maxRetryDelay
+		}
+		delay = base * time.Duration(attempt)
+	case RetryBackoffExponential:
+		for i := 1; i < attempt; i++ {
+			if delay > maxRetryDelay/2 {
+				return maxRetryDelay
+			}
+			delay *= 2
+		}
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// RunTaskWithRetries runs task with the default fixed backoff; see
+// RunTaskWithBackoff.
+func RunTaskWithRetries(task TaskSpec, timeout int, retries int, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	return RunTaskWithBackoff(task, timeout, retries, RetryBackoff{}, runTask)
+}
+
+// RunTaskWithBackoff runs task and re-runs it up to retries more times while
+// it fails, pausing backoff.Delay(n) before retry n. Every attempt starts from
+// a fresh TaskResult, so the returned message and session only ever reflect
+// the last attempt; partial output from failed attempts is discarded.
+func RunTaskWithBackoff(task TaskSpec, timeout int, retries int, backoff RetryBackoff, runTask func(TaskSpec, int) TaskResult) TaskResult {
+	result := runTask(task, timeout)
+	for attempt := 1; attempt <= retries && isRetryableResult(result); attempt++ {
+		ctx := task.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		delay := backoff.Delay(attempt)
+		logWarn(fmt.Sprintf("task %s failed (exit %d); retrying in %s (%d/%d)", taskLabel(task), result.ExitCode, delay, attempt, retries))
+		select {
+		case <-ctx.Done():
+			return result
+		case <-retryAfterFn(delay):
+		}
+		result = runTask(task, timeout)
+	}
+	return result
+}
+
+// isRetryableResult reports whether a failed attempt is worth repeating.
+// Timeouts, cancellations and missing executables are not retried.
+func isRetryableResult(result TaskResult) bool {
+	if result.ExitCode == 0 && result.Error == "" {
+		return false
+	}
+	switch result.ExitCode {
+	case 124, 127, 130:
+		return false
+	}
+	return true
+}
+
+func taskLabel(task TaskSpec) string {
+	if task.ID != "" {
+		return task.ID
+	}
+	return "main"
+}
@@ -4,6 +4,7 @@
 package executor
 
 import (
+	"os/exec"
 	"syscall"
 )
 
@@ -14,3 +15,27 @@ func sendTermSignal(proc processHandle) error {
 	}
 	return proc.Signal(syscall.SIGTERM)
 }
+
+// setOwnProcessGroup makes the command the leader of a new process group.
+func setOwnProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup sends SIGTERM (or SIGKILL when kill is set) to the whole
+// process group led by proc, so helpers the backend spawned go down with it.
+func signalProcessGroup(proc processHandle, kill bool) error {
+	if proc == nil || proc.Pid() <= 0 {
+		return nil
+	}
+	sig := syscall.SIGTERM
+	if kill {
+		sig = syscall.SIGKILL
+	}
+	if err := syscall.Kill(-proc.Pid(), sig); err != nil {
+		return proc.Signal(sig)
+	}
+	return nil
+}
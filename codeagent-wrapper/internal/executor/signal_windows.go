@@ -10,8 +10,30 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
+// setOwnProcessGroup starts the command in a new console process group so it
+// does not receive the wrapper's Ctrl+C.
+func setOwnProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// signalProcessGroup terminates the process tree; sendTermSignal already
+// covers children on Windows.
+func signalProcessGroup(proc processHandle, kill bool) error {
+	if proc == nil {
+		return nil
+	}
+	if kill {
+		return proc.Kill()
+	}
+	return sendTermSignal(proc)
+}
+
 // sendTermSignal on Windows directly kills the process.
 // SIGTERM is not supported on Windows.
 func sendTermSignal(proc processHandle) error {
@@ -1,14 +1,46 @@
 package executor
 
-import "strings"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
 
 const stdinSpecialChars = "\n\\\"'`$"
 
+const (
+	// defaultStdinThreshold is the task length above which the task is sent
+	// on stdin instead of argv.
+	defaultStdinThreshold = 800
+	// minStdinThreshold is the lowest CODEAGENT_STDIN_THRESHOLD accepted.
+	minStdinThreshold = 64
+)
+
+// StdinThreshold returns the task length above which the task is passed on
+// stdin: CODEAGENT_STDIN_THRESHOLD (at least minStdinThreshold), or
+// defaultStdinThreshold when unset or invalid.
+func StdinThreshold() int {
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_STDIN_THRESHOLD"))
+	if raw == "" {
+		return defaultStdinThreshold
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		logWarn(fmt.Sprintf("Invalid CODEAGENT_STDIN_THRESHOLD %q, using %d", raw, defaultStdinThreshold))
+		return defaultStdinThreshold
+	}
+	if n < minStdinThreshold {
+		return minStdinThreshold
+	}
+	return n
+}
+
 func ShouldUseStdin(taskText string, piped bool) bool {
 	if piped {
 		return true
 	}
-	if len(taskText) > 800 {
+	if len(taskText) > StdinThreshold() {
 		return true
 	}
 	return strings.ContainsAny(taskText, stdinSpecialChars)
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds for the --json-stream-passthrough writer: at most streamBufferLines
+// complete lines wait for a slow consumer, and Close waits at most
+// streamBufferDrainTimeout for them to be written; lines still queued after
+// that are dropped rather than written behind the wrapper's final output.
+const streamBufferDrainTimeout = 2 * time.Second
+
+var (
+	streamBufferLines = 1024
+	// streamPassthroughOutput overrides where --json-stream-passthrough
+	// writes (os.Stdout when nil); tests replace it with a slow writer.
+	streamPassthroughOutput io.Writer
+)
+
+// boundedLineWriter decouples the parse loop from a slow display consumer.
+// Write never blocks: complete lines are queued for a background goroutine
+// and, once streamBufferLines are pending, further lines are dropped whole so
+// the consumer never sees a truncated JSON line. The parser reads the backend
+// independently, so drops only affect the display, never the final message.
+type boundedLineWriter struct {
+	out     io.Writer
+	lines   chan []byte
+	done    chan struct{}
+	mu      sync.Mutex
+	partial []byte
+	dropped int
+	closed  bool
+	// stopped is set when Close gives up waiting; run then discards the
+	// lines it receives instead of writing them.
+	stopped   atomic.Bool
+	abandoned atomic.Int64
+}
+
+func newBoundedLineWriter(out io.Writer, capacity int) *boundedLineWriter {
+	if out == nil {
+		out = os.Stdout
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+	w := &boundedLineWriter{
+		out:   out,
+		lines: make(chan []byte, capacity),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *boundedLineWriter) run() {
+	defer close(w.done)
+	for line := range w.lines {
+		if w.stopped.Load() {
+			w.abandoned.Add(1)
+			continue
+		}
+		_, _ = w.out.Write(line)
+	}
+}
+
+func (w *boundedLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return len(p), nil
+	}
+
+	data := p
+	for len(data) > 0 {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			w.partial = append(w.partial, data...)
+			break
+		}
+		line := make([]byte, 0, len(w.partial)+idx+1)
+		line = append(line, w.partial...)
+		line = append(line, data[:idx+1]...)
+		w.partial = w.partial[:0]
+		w.enqueueLocked(line)
+		data = data[idx+1:]
+	}
+	return len(p), nil
+}
+
+func (w *boundedLineWriter) enqueueLocked(line []byte) {
+	select {
+	case w.lines <- line:
+	default:
+		w.dropped++
+	}
+}
+
+// Close queues any trailing partial line and waits, up to timeout, for the
+// consumer to catch up. After the timeout the remaining lines are discarded
+// so nothing is written behind the caller's later output; only a write that
+// is already in progress may still complete. It returns how many lines were
+// dropped.
+func (w *boundedLineWriter) Close(timeout time.Duration) int {
+	w.mu.Lock()
+	if w.closed {
+		dropped := w.dropped
+		w.mu.Unlock()
+		return dropped
+	}
+	if len(w.partial) > 0 {
+		w.enqueueLocked(append([]byte(nil), w.partial...))
+		w.partial = nil
+	}
+	w.closed = true
+	close(w.lines)
+	dropped := w.dropped
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+		w.stopped.Store(true)
+		for range w.lines {
+			w.abandoned.Add(1)
+		}
+		logWarn(fmt.Sprintf("stream passthrough: consumer did not drain within %s; discarding the remaining lines", timeout))
+	}
+	return dropped + int(w.abandoned.Load())
+}
+
+// streamMessagesOutput overrides where CODEAGENT_STREAM text goes (os.Stdout
+// when nil).
+var streamMessagesOutput io.Writer
+
+// messageStreamer prints assistant text as the parser reports it
+// (CODEAGENT_STREAM) and terminates the output with a newline on finish.
+type messageStreamer struct {
+	out         io.Writer
+	mu          sync.Mutex
+	wrote       bool
+	lastNewline bool
+}
+
+func newMessageStreamer(out io.Writer) *messageStreamer {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &messageStreamer{out: out}
+}
+
+func (s *messageStreamer) text(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := io.WriteString(s.out, text); err != nil {
+		return
+	}
+	s.wrote = true
+	s.lastNewline = text[len(text)-1] == '\n'
+}
+
+func (s *messageStreamer) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wrote && !s.lastNewline {
+		_, _ = io.WriteString(s.out, "\n")
+		s.lastNewline = true
+	}
+}
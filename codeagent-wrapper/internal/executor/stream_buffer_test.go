@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks every Write until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	lines   []string
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	w.lines = append(w.lines, string(p))
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func TestBoundedLineWriter_CloseTimeoutStopsWriting(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+	w := newBoundedLineWriter(out, 8)
+	_, _ = w.Write([]byte("{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"))
+	time.Sleep(20 * time.Millisecond) // let run pick up the first line
+
+	if dropped := w.Close(50 * time.Millisecond); dropped != 2 {
+		t.Fatalf("Close() dropped = %d, want the 2 queued lines", dropped)
+	}
+	close(out.release)
+	<-w.done
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if len(out.lines) != 1 || out.lines[0] != "{\"n\":1}\n" {
+		t.Fatalf("lines written after Close = %q, want only the one in progress", out.lines)
+	}
+}
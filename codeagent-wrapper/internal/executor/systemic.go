@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	config "codeagent-wrapper/internal/config"
+)
+
+const (
+	// defaultSystemicFailureRatio is the minimum share of a layer's tasks that
+	// must fail with the same infrastructure exit code before the failure is
+	// reported as systemic.
+	defaultSystemicFailureRatio = 0.5
+	// minSystemicFailures keeps a single failed task from being read as an
+	// outage.
+	minSystemicFailures = 2
+)
+
+// infrastructureExitCodes are the exit codes that point at the environment
+// (backend install, network, quota) rather than at the task itself.
+var infrastructureExitCodes = map[int]string{
+	127: "backend command not found",
+	124: "timeout",
+}
+
+// systemicFailureRatio reads CODEAGENT_SYSTEMIC_FAILURE_RATIO, a fraction in
+// (0, 1]; 1 means every task that ran failed. "0" or "off" disables
+// detection; unset or invalid values use defaultSystemicFailureRatio.
+func systemicFailureRatio() float64 {
+	raw := strings.TrimSpace(strings.ToLower(os.Getenv("CODEAGENT_SYSTEMIC_FAILURE_RATIO")))
+	switch raw {
+	case "":
+		return defaultSystemicFailureRatio
+	case "off", "false", "no":
+		return 0
+	}
+	ratio, err := strconv.ParseFloat(raw, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		logWarn(fmt.Sprintf("Invalid CODEAGENT_SYSTEMIC_FAILURE_RATIO %q, using %.2f", raw, defaultSystemicFailureRatio))
+		return defaultSystemicFailureRatio
+	}
+	return ratio
+}
+
+// systemicFailureAbort reports whether CODEAGENT_SYSTEMIC_FAILURE_ABORT asks
+// to skip the remaining layers once a systemic failure is detected.
+func systemicFailureAbort() bool {
+	return config.EnvFlagEnabled("CODEAGENT_SYSTEMIC_FAILURE_ABORT")
+}
+
+// detectSystemicFailure looks for an infrastructure exit code shared by at
+// least ratio of the tasks that ran in a layer (and by at least
+// minSystemicFailures of them). It returns the code and how many tasks hit it.
+func detectSystemicFailure(results []TaskResult, ratio float64) (code, count int, ok bool) {
+	if ratio <= 0 || len(results) == 0 {
+		return 0, 0, false
+	}
+	counts := make(map[int]int)
+	for _, res := range results {
+		if _, infra := infrastructureExitCodes[res.ExitCode]; infra {
+			counts[res.ExitCode]++
+		}
+	}
+	for c, n := range counts {
+		// Dividing keeps exact fractions such as 7/10 equal to a ratio of 0.7.
+		if n < minSystemicFailures || float64(n)/float64(len(results)) < ratio {
+			continue
+		}
+		if n > count || (n == count && c > code) {
+			code, count, ok = c, n, true
+		}
+	}
+	return code, count, ok
+}
+
+// systemicFailureMessage describes a detected systemic failure in layer
+// (1-based) for the log and stderr.
+func systemicFailureMessage(layer, code, count, total int) string {
+	return fmt.Sprintf("systemic failure: %d of %d tasks in layer %d exited %d (%s); this usually means the environment is broken (backend install, PATH, network, quota), not the tasks", count, total, layer, code, infrastructureExitCodes[code])
+}
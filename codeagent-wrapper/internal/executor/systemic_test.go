@@ -0,0 +1,55 @@
+package executor
+
+import "testing"
+
+func TestSystemicFailureRatio(t *testing.T) {
+	for raw, want := range map[string]float64{
+		"":     defaultSystemicFailureRatio,
+		"0.8":  0.8,
+		"1":    1,
+		"0":    0,
+		"off":  0,
+		"1.5":  defaultSystemicFailureRatio,
+		"most": defaultSystemicFailureRatio,
+	} {
+		t.Setenv("CODEAGENT_SYSTEMIC_FAILURE_RATIO", raw)
+		if got := systemicFailureRatio(); got != want {
+			t.Errorf("systemicFailureRatio(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestDetectSystemicFailure(t *testing.T) {
+	results := func(codes ...int) []TaskResult {
+		out := make([]TaskResult, len(codes))
+		for i, code := range codes {
+			out[i] = TaskResult{ExitCode: code}
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		results   []TaskResult
+		ratio     float64
+		wantCode  int
+		wantCount int
+		wantOK    bool
+	}{
+		{"majority not found", results(127, 127, 127, 0), 0.5, 127, 3, true},
+		{"half meets the default ratio", results(124, 124, 0, 0), 0.5, 124, 2, true},
+		{"below the ratio", results(124, 124, 0, 0, 0), 0.5, 0, 0, false},
+		{"exact decimal ratio", results(127, 127, 127, 127, 127, 127, 127, 0, 0, 0), 0.7, 127, 7, true},
+		{"ratio 1 every task failed", results(127, 127, 127), 1, 127, 3, true},
+		{"ratio 1 one task succeeded", results(127, 127, 0), 1, 0, 0, false},
+		{"single task layer", results(127), 0.5, 0, 0, false},
+		{"task failures are not infrastructure", results(1, 1, 1), 0.5, 0, 0, false},
+		{"mixed infrastructure codes", results(127, 124, 0), 0.5, 0, 0, false},
+		{"disabled", results(127, 127), 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		code, count, ok := detectSystemicFailure(tt.results, tt.ratio)
+		if code != tt.wantCode || count != tt.wantCount || ok != tt.wantOK {
+			t.Errorf("%s: got (%d, %d, %v), want (%d, %d, %v)", tt.name, code, count, ok, tt.wantCode, tt.wantCount, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Intra-layer start orders for parallel execution.
+const (
+	TaskOrderDeclared   = "declared"   // config order
+	TaskOrderID         = "id"         // lexical by task id
+	TaskOrderDependency = "dependency" // most-depended-upon first
+)
+
+// ValidateTaskOrder normalizes a --task-order value, defaulting to declared.
+func ValidateTaskOrder(order string) (string, error) {
+	order = strings.ToLower(strings.TrimSpace(order))
+	switch order {
+	case "":
+		return TaskOrderDeclared, nil
+	case TaskOrderDeclared, TaskOrderID, TaskOrderDependency:
+		return order, nil
+	default:
+		return "", fmt.Errorf("invalid task order %q (want %s, %s or %s)", order, TaskOrderDeclared, TaskOrderDependency, TaskOrderID)
+	}
+}
+
+// countDependents returns how many tasks directly depend on each task id.
+func countDependents(layers [][]TaskSpec) map[string]int {
+	counts := make(map[string]int)
+	for _, layer := range layers {
+		for _, task := range layer {
+			for _, dep := range task.Dependencies {
+				counts[dep]++
+			}
+		}
+	}
+	return counts
+}
+
+// orderLayer returns the layer's tasks in start order: higher Priority first,
+// then the requested order policy. Ties keep their declared order.
+func orderLayer(layer []TaskSpec, order string, dependents map[string]int) []TaskSpec {
+	ordered := append([]TaskSpec(nil), layer...)
+	switch order {
+	case TaskOrderID:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].ID < ordered[j].ID })
+	case TaskOrderDependency:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return dependents[ordered[i].ID] > dependents[ordered[j].ID]
+		})
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority > ordered[j].Priority })
+	return ordered
+}
@@ -1,6 +1,9 @@
 package executor
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // ParallelConfig defines the JSON schema for parallel execution.
 type ParallelConfig struct {
@@ -21,6 +24,7 @@ type TaskSpec struct {
 	Agent           string          `json:"agent,omitempty"`
 	PromptFile      string          `json:"prompt_file,omitempty"`
 	SkipPermissions bool            `json:"skip_permissions,omitempty"`
+	Yolo            bool            `json:"yolo,omitempty"`
 	Worktree        bool            `json:"worktree,omitempty"`
 	AllowedTools    []string        `json:"allowed_tools,omitempty"`
 	DisallowedTools []string        `json:"disallowed_tools,omitempty"`
@@ -28,6 +32,35 @@ type TaskSpec struct {
 	Mode            string          `json:"-"`
 	UseStdin        bool            `json:"-"`
 	Context         context.Context `json:"-"`
+	// Priority orders tasks within a layer: higher starts first, ties keep
+	// the --task-order policy (declared order by default).
+	Priority int `json:"priority,omitempty"`
+	// StreamPassthrough tees the backend's raw stdout to os.Stdout.
+	StreamPassthrough bool `json:"-"`
+	// ClaudeAllowFile is forwarded to claude as --settings.
+	ClaudeAllowFile string `json:"-"`
+	// FailOnTurnFailed marks the task failed when the stream reported
+	// turn.failed/error events even though the backend exited 0.
+	FailOnTurnFailed bool `json:"-"`
+	// ValidateStream checks every backend event against the expected schema
+	// and logs violations as warnings.
+	ValidateStream bool `json:"-"`
+	// FailIfNoFilesChanged marks the task failed when the stream reported
+	// no file_change items.
+	FailIfNoFilesChanged bool `json:"-"`
+	// CacheDir enables the result cache (see RunWithResultCache);
+	// CacheRefresh skips lookups and CacheNoWrite skips stores.
+	CacheDir     string `json:"-"`
+	CacheRefresh bool   `json:"-"`
+	CacheNoWrite bool   `json:"-"`
+	// StreamMessages prints assistant text to stdout as it arrives
+	// (CODEAGENT_STREAM).
+	StreamMessages bool `json:"-"`
+	// Quiet suppresses the banner lines (injected env, Claude tmpdir) the
+	// executor adds to stderr.
+	Quiet bool `json:"-"`
+	// SystemPrompt is forwarded to claude as --append-system-prompt.
+	SystemPrompt string `json:"-"`
 }
 
 // TaskResult captures the execution outcome of a task.
@@ -38,6 +71,15 @@ type TaskResult struct {
 	SessionID string `json:"session_id"`
 	Error     string `json:"error"`
 	LogPath   string `json:"log_path"`
+	// BackendExitCode is the backend process's own exit status as reported
+	// by Wait, independent of the wrapper's verdict in ExitCode (which may be
+	// 1 for "no message" even though the backend exited 0). It is nil when
+	// the backend never ran or its status is unknown, and -1 when it was
+	// killed by a signal.
+	BackendExitCode *int `json:"backend_exit_code,omitempty"`
+	// WorkDir is the directory the backend actually ran in (the worktree
+	// directory when worktree isolation is active).
+	WorkDir string `json:"work_dir,omitempty"`
 	// Structured report fields
 	Coverage       string   `json:"coverage,omitempty"`        // extracted coverage percentage (e.g., "92%")
 	CoverageNum    float64  `json:"coverage_num,omitempty"`    // numeric coverage for comparison
@@ -46,5 +88,19 @@ type TaskResult struct {
 	KeyOutput      string   `json:"key_output,omitempty"`      // brief summary of what was done
 	TestsPassed    int      `json:"tests_passed,omitempty"`    // number of tests passed
 	TestsFailed    int      `json:"tests_failed,omitempty"`    // number of tests failed
-	sharedLog      bool
+	StreamErrors   []string `json:"stream_errors,omitempty"`   // turn.failed/error events reported by the backend
+	Cached         bool     `json:"cached,omitempty"`          // served from the --cache-dir result cache
+	Interrupted    bool     `json:"interrupted,omitempty"`     // never started because the run was interrupted
+	// ChangedFiles lists the file_change events the backend reported,
+	// deduplicated by path (kind reflects the latest change).
+	ChangedFiles []FileChange `json:"changed_files,omitempty"`
+	// Usage and timing; zero when the backend did not report usage or the
+	// result came from the cache.
+	Tokens       int   `json:"tokens,omitempty"`        // total tokens reported by the backend
+	InputTokens  int   `json:"input_tokens,omitempty"`  // prompt tokens, including cache reads/writes
+	OutputTokens int   `json:"output_tokens,omitempty"` // completion tokens
+	DurationMs   int64 `json:"duration_ms,omitempty"`   // wall-clock time from spawning the backend to its exit
+	// SpawnDuration is how long starting the backend process took.
+	SpawnDuration time.Duration `json:"-"`
+	sharedLog     bool
 }
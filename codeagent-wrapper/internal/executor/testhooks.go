@@ -2,9 +2,12 @@ package executor
 
 import (
 	"context"
+	"io"
 	"os/exec"
+	"time"
 
 	backend "codeagent-wrapper/internal/backend"
+	worktree "codeagent-wrapper/internal/worktree"
 )
 
 type CommandRunner = commandRunner
@@ -16,6 +19,56 @@ func SetForceKillDelay(seconds int32) (restore func()) {
 	return func() { forceKillDelay.Store(prev) }
 }
 
+func SetInterruptPollInterval(d time.Duration) (restore func()) {
+	prev := interruptPollInterval
+	interruptPollInterval = d
+	return func() { interruptPollInterval = prev }
+}
+
+func SetTaskRetryDelay(d time.Duration) (restore func()) {
+	prev := taskRetryDelay
+	taskRetryDelay = d
+	return func() { taskRetryDelay = prev }
+}
+
+func SetRetryAfterFn(fn func(time.Duration) <-chan time.Time) (restore func()) {
+	prev := retryAfterFn
+	if fn != nil {
+		retryAfterFn = fn
+	} else {
+		retryAfterFn = time.After
+	}
+	return func() { retryAfterFn = prev }
+}
+
+func SetStreamPassthroughOutput(w io.Writer, capacity int) (restore func()) {
+	prevOut, prevLines := streamPassthroughOutput, streamBufferLines
+	if w != nil {
+		streamPassthroughOutput = w
+	}
+	if capacity > 0 {
+		streamBufferLines = capacity
+	}
+	return func() { streamPassthroughOutput, streamBufferLines = prevOut, prevLines }
+}
+
+// SetStreamMessagesOutput redirects CODEAGENT_STREAM text to w.
+func SetStreamMessagesOutput(w io.Writer) (restore func()) {
+	prev := streamMessagesOutput
+	streamMessagesOutput = w
+	return func() { streamMessagesOutput = prev }
+}
+
+func SetCreateWorktreeFn(fn func(string) (*worktree.Paths, error)) (restore func()) {
+	prev := createWorktreeFn
+	if fn != nil {
+		createWorktreeFn = fn
+	} else {
+		createWorktreeFn = worktree.CreateWorktree
+	}
+	return func() { createWorktreeFn = prev }
+}
+
 func SetSelectBackendFn(fn func(string) (Backend, error)) (restore func()) {
 	prev := selectBackendFn
 	if fn != nil {
@@ -26,26 +79,42 @@ func SetSelectBackendFn(fn func(string) (Backend, error)) (restore func()) {
 	return func() { selectBackendFn = prev }
 }
 
+// injectedLookPath stands in for exec.LookPath while a command factory is
+// injected: the factory decides what runs, so the backend name need not exist.
+func injectedLookPath(name string) (string, error) { return name, nil }
+
 func SetCommandContextFn(fn func(context.Context, string, ...string) *exec.Cmd) (restore func()) {
 	prev := commandContext
+	prevLookPath := lookPathFn
 	if fn != nil {
 		commandContext = fn
+		lookPathFn = injectedLookPath
 	} else {
 		commandContext = exec.CommandContext
+		lookPathFn = exec.LookPath
+	}
+	return func() {
+		commandContext = prev
+		lookPathFn = prevLookPath
 	}
-	return func() { commandContext = prev }
 }
 
 func SetNewCommandRunner(fn func(context.Context, string, ...string) CommandRunner) (restore func()) {
 	prev := newCommandRunner
+	prevLookPath := lookPathFn
 	if fn != nil {
 		newCommandRunner = fn
+		lookPathFn = injectedLookPath
 	} else {
 		newCommandRunner = func(ctx context.Context, name string, args ...string) commandRunner {
 			return &realCmd{cmd: commandContext(ctx, name, args...)}
 		}
+		lookPathFn = exec.LookPath
+	}
+	return func() {
+		newCommandRunner = prev
+		lookPathFn = prevLookPath
 	}
-	return func() { newCommandRunner = prev }
 }
 
 func WithTaskLogger(ctx context.Context, logger *Logger) context.Context {
@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Limits for BuildWorkdirContext so the listing stays a small part of the
+// prompt even for large repositories.
+const (
+	workdirContextMaxDepth   = 3
+	workdirContextMaxEntries = 200
+	workdirContextMaxBytes   = 8000
+)
+
+// skippedContextDirs are never listed when the workdir is not a git repo.
+var skippedContextDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+}
+
+// BuildWorkdirContext renders a bounded tree-like listing of workDir wrapped
+// in <workdir-tree> tags. Inside a git repository the file list comes from git,
+// so .gitignore is respected; otherwise hidden and common build directories
+// are skipped. Directories deeper than workdirContextMaxDepth are collapsed and
+// the listing stops at workdirContextMaxEntries lines or
+// workdirContextMaxBytes bytes. It returns "" when nothing can be listed.
+func BuildWorkdirContext(workDir string) string {
+	if strings.TrimSpace(workDir) == "" {
+		workDir = "."
+	}
+	files, err := listWorkdirFiles(workDir)
+	if err != nil {
+		logWarn(fmt.Sprintf("workdir context: failed to list %s: %v", workDir, err))
+		return ""
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	sort.Strings(files)
+
+	var lines []string
+	seenDirs := make(map[string]bool)
+	for _, file := range files {
+		parts := strings.Split(file, "/")
+		for depth := 1; depth < len(parts) && depth <= workdirContextMaxDepth; depth++ {
+			dir := strings.Join(parts[:depth], "/")
+			if seenDirs[dir] {
+				continue
+			}
+			seenDirs[dir] = true
+			lines = append(lines, strings.Repeat("  ", depth-1)+parts[depth-1]+"/")
+		}
+		if len(parts) <= workdirContextMaxDepth {
+			lines = append(lines, strings.Repeat("  ", len(parts)-1)+parts[len(parts)-1])
+		}
+	}
+
+	root := workDir
+	if abs, err := filepath.Abs(workDir); err == nil {
+		root = abs
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<workdir-tree root=%q>\n", root))
+	written := 0
+	for _, line := range lines {
+		if written >= workdirContextMaxEntries || sb.Len()+len(line)+1 > workdirContextMaxBytes {
+			break
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+		written++
+	}
+	if omitted := len(lines) - written; omitted > 0 {
+		sb.WriteString(fmt.Sprintf("... (%d more entries omitted)\n", omitted))
+	}
+	sb.WriteString("</workdir-tree>")
+	return sb.String()
+}
+
+// listWorkdirFiles returns workdir-relative file paths (slash separated).
+func listWorkdirFiles(workDir string) ([]string, error) {
+	cmd := exec.Command("git", "-C", workDir, "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	if out, err := cmd.Output(); err == nil {
+		var files []string
+		for _, name := range bytes.Split(out, []byte{0}) {
+			if len(name) > 0 {
+				files = append(files, string(name))
+			}
+		}
+		return files, nil
+	}
+
+	// Not a git repository (or git is unavailable): walk the tree ourselves,
+	// bounded so huge directories do not stall the wrapper.
+	limit := workdirContextMaxEntries * 10
+	var files []string
+	err := filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, relErr := filepath.Rel(workDir, path)
+		if relErr != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || skippedContextDirs[name] || strings.Count(rel, "/") >= workdirContextMaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		files = append(files, rel)
+		if len(files) >= limit {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return files, err
+}
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +38,12 @@ type Logger struct {
 	workerErr    error
 	errorEntries []string // Cache of recent ERROR/WARN entries
 	errorMu      sync.Mutex
+	mirror       atomic.Pointer[io.Writer] // optional live copy of entries (e.g. stderr)
+	fallback     io.Writer                 // WARN/ERROR sink for loggers without a file
+	minLevel     zerolog.Level             // entries below this level are dropped
+	counter      *countingWriter           // bytes written to the log file so far
+	maxBytes     int64                     // size cap for the log file; 0 means unlimited
+	truncated    bool                      // set once the size cap has been hit (worker only)
 }
 
 type logEntry struct {
@@ -102,12 +110,15 @@ func NewLoggerWithSuffix(suffix string) (*Logger, error) {
 		path:     path,
 		file:     f,
 		writer:   bufio.NewWriterSize(f, 4096),
+		minLevel: logLevelFromEnv(),
+		maxBytes: logMaxBytesFromEnv(),
 		ch:       make(chan logEntry, 1000),
 		flushReq: make(chan chan struct{}, 1),
 		done:     make(chan struct{}),
 	}
 
-	l.zlogger = zerolog.New(l.writer).With().Timestamp().Logger()
+	l.counter = &countingWriter{w: l.writer}
+	l.zlogger = zerolog.New(l.counter).With().Timestamp().Logger()
 
 	l.workerWG.Add(1)
 	go l.run()
@@ -115,6 +126,39 @@ func NewLoggerWithSuffix(suffix string) (*Logger, error) {
 	return l, nil
 }
 
+// countingWriter tracks how many bytes have passed through to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewFallbackLogger creates a logger that has no backing file: entries are
+// discarded except WARN and ERROR, which are written to w. It is used when no
+// temp directory is writable so that logging degrades instead of aborting.
+func NewFallbackLogger(w io.Writer) *Logger {
+	l := &Logger{
+		writer:   bufio.NewWriter(io.Discard),
+		fallback: w,
+		minLevel: logLevelFromEnv(),
+		ch:       make(chan logEntry, 1000),
+		flushReq: make(chan chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	l.zlogger = zerolog.New(l.writer).With().Timestamp().Logger()
+
+	l.workerWG.Add(1)
+	go l.run()
+
+	return l
+}
+
 func sanitizeLogSuffix(raw string) string {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -182,6 +226,19 @@ func isSafeLogRune(r rune) bool {
 	}
 }
 
+// MirrorTo makes the logger also write every entry to w as it is logged.
+// Passing nil stops mirroring.
+func (l *Logger) MirrorTo(w io.Writer) {
+	if l == nil {
+		return
+	}
+	if w == nil {
+		l.mirror.Store(nil)
+		return
+	}
+	l.mirror.Store(&w)
+}
+
 // Path returns the underlying log file path (useful for tests/inspection).
 func (l *Logger) Path() string {
 	if l == nil {
@@ -269,6 +326,40 @@ func loggerCloseTimeout() time.Duration {
 	return time.Duration(ms) * time.Millisecond
 }
 
+// logLevelFromEnv reads the minimum level to record from CODEAGENT_LOG_LEVEL
+// (debug, info, warn or error). Unset or unrecognised values mean info.
+func logLevelFromEnv() zerolog.Level {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("CODEAGENT_LOG_LEVEL"))) {
+	case "debug":
+		return zerolog.DebugLevel
+	case "warn", "warning":
+		return zerolog.WarnLevel
+	case "error":
+		return zerolog.ErrorLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// logMaxBytesFromEnv reads the log file size cap from CODEAGENT_LOG_MAX_BYTES.
+// Unset or invalid values keep the default; 0 or less disables the cap.
+func logMaxBytesFromEnv() int64 {
+	const defaultMaxBytes = 100 << 20
+
+	raw := strings.TrimSpace(os.Getenv("CODEAGENT_LOG_MAX_BYTES"))
+	if raw == "" {
+		return defaultMaxBytes
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return defaultMaxBytes
+	}
+	if n <= 0 {
+		return 0
+	}
+	return n
+}
+
 // RemoveLogFile removes the log file. Should only be called after Close().
 func (l *Logger) RemoveLogFile() error {
 	if l == nil {
@@ -355,6 +446,10 @@ func (l *Logger) logWithLevel(entryLevel zerolog.Level, msg string) {
 	if l.closed.Load() {
 		return
 	}
+	// Filter before pendingWG.Add so Flush never waits on a dropped entry.
+	if entryLevel < l.minLevel {
+		return
+	}
 
 	isError := entryLevel == zerolog.WarnLevel || entryLevel == zerolog.ErrorLevel
 	entry := logEntry{msg: msg, level: entryLevel, isError: isError}
@@ -379,7 +474,20 @@ func (l *Logger) run() {
 	defer ticker.Stop()
 
 	writeEntry := func(entry logEntry) {
-		l.zlogger.WithLevel(entry.level).Msg(entry.msg)
+		switch {
+		case l.maxBytes > 0 && l.counter != nil && l.counter.n >= l.maxBytes:
+			if !l.truncated {
+				l.truncated = true
+				l.zlogger.Warn().Msg(fmt.Sprintf("log truncated: reached %d bytes (CODEAGENT_LOG_MAX_BYTES); further entries are dropped", l.maxBytes))
+			}
+		default:
+			l.zlogger.WithLevel(entry.level).Msg(entry.msg)
+		}
+		if w := l.mirror.Load(); w != nil {
+			fmt.Fprintf(*w, "[%s] %s\n", strings.ToUpper(entry.level.String()), entry.msg)
+		} else if l.fallback != nil && entry.isError {
+			fmt.Fprintf(l.fallback, "[%s] %s\n", strings.ToUpper(entry.level.String()), entry.msg)
+		}
 
 		// Cache error/warn entries in memory for fast extraction
 		if entry.isError {
@@ -398,6 +506,9 @@ func (l *Logger) run() {
 		if err := l.writer.Flush(); err != nil && l.workerErr == nil {
 			l.workerErr = err
 		}
+		if l.file == nil {
+			return
+		}
 		if err := l.file.Sync(); err != nil && l.workerErr == nil {
 			l.workerErr = err
 		}
@@ -421,7 +532,9 @@ func (l *Logger) run() {
 		case flushDone := <-l.flushReq:
 			// Explicit flush request - flush writer and sync to disk
 			_ = l.writer.Flush()
-			_ = l.file.Sync()
+			if l.file != nil {
+				_ = l.file.Sync()
+			}
 			close(flushDone)
 
 		case <-l.done:
@@ -451,24 +564,10 @@ func cleanupOldLogs() (CleanupStats, error) {
 	var stats CleanupStats
 	tempDir := os.TempDir()
 
-	prefixes := LogPrefixes()
-
-	seen := make(map[string]struct{})
-	var matches []string
-	for _, prefix := range prefixes {
-		pattern := filepath.Join(tempDir, fmt.Sprintf("%s-*.log", prefix))
-		found, err := globLogFiles(pattern)
-		if err != nil {
-			logWarn(fmt.Sprintf("cleanupOldLogs: failed to list logs: %v", err))
-			return stats, fmt.Errorf("cleanupOldLogs: %w", err)
-		}
-		for _, path := range found {
-			if _, ok := seen[path]; ok {
-				continue
-			}
-			seen[path] = struct{}{}
-			matches = append(matches, path)
-		}
+	matches, err := listLogFiles(tempDir)
+	if err != nil {
+		logWarn(fmt.Sprintf("cleanupOldLogs: failed to list logs: %v", err))
+		return stats, fmt.Errorf("cleanupOldLogs: %w", err)
 	}
 
 	var removeErr error
@@ -546,6 +645,162 @@ func cleanupOldLogs() (CleanupStats, error) {
 	return stats, nil
 }
 
+// listLogFiles returns the wrapper log files in tempDir, de-duplicated across
+// all known log prefixes.
+func listLogFiles(tempDir string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var matches []string
+	for _, prefix := range LogPrefixes() {
+		pattern := filepath.Join(tempDir, fmt.Sprintf("%s-*.log", prefix))
+		found, err := globLogFiles(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range found {
+			if _, ok := seen[path]; ok {
+				continue
+			}
+			seen[path] = struct{}{}
+			matches = append(matches, path)
+		}
+	}
+	return matches, nil
+}
+
+// trimLogsToLimit keeps at most maxLogs wrapper logs in os.TempDir(), removing
+// the oldest ones by modification time regardless of whether their owning
+// process is still alive. Logs belonging to the current process are never
+// removed and do not count towards the limit. A non-positive maxLogs disables
+// trimming.
+func trimLogsToLimit(maxLogs int) (CleanupStats, error) {
+	var stats CleanupStats
+	if maxLogs <= 0 {
+		return stats, nil
+	}
+	tempDir := os.TempDir()
+
+	matches, err := listLogFiles(tempDir)
+	if err != nil {
+		logWarn(fmt.Sprintf("trimLogsToLimit: failed to list logs: %v", err))
+		return stats, fmt.Errorf("trimLogsToLimit: %w", err)
+	}
+
+	type candidate struct {
+		path    string
+		modTime time.Time
+	}
+
+	currentPID := os.Getpid()
+	var candidates []candidate
+	for _, path := range matches {
+		stats.Scanned++
+		filename := filepath.Base(path)
+
+		if pid, ok := parsePIDFromLog(path); ok && pid == currentPID {
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, filename)
+			continue
+		}
+		if shouldSkipFile, reason := isUnsafeFile(path, tempDir); shouldSkipFile {
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, filename)
+			if reason != "" {
+				logDebug(fmt.Sprintf("trimLogsToLimit: skipping %s: %s", filename, reason))
+			}
+			continue
+		}
+		info, err := fileStatFn(path)
+		if err != nil {
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, filename)
+			continue
+		}
+		candidates = append(candidates, candidate{path: path, modTime: info.ModTime()})
+	}
+
+	// Newest first; ties are broken by name so the result is deterministic.
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].modTime.Equal(candidates[j].modTime) {
+			return candidates[i].path > candidates[j].path
+		}
+		return candidates[i].modTime.After(candidates[j].modTime)
+	})
+
+	var removeErr error
+	for i, c := range candidates {
+		filename := filepath.Base(c.path)
+		if i < maxLogs {
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, filename)
+			continue
+		}
+		if err := removeLogFileFn(c.path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				stats.Kept++
+				stats.KeptFiles = append(stats.KeptFiles, filename+" (already deleted)")
+				continue
+			}
+			stats.Errors++
+			logWarn(fmt.Sprintf("trimLogsToLimit: failed to remove %s: %v", filename, err))
+			removeErr = errors.Join(removeErr, fmt.Errorf("failed to remove %s: %w", filename, err))
+			continue
+		}
+		stats.Deleted++
+		stats.DeletedFiles = append(stats.DeletedFiles, filename)
+	}
+
+	if removeErr != nil {
+		return stats, fmt.Errorf("trimLogsToLimit: %w", removeErr)
+	}
+	return stats, nil
+}
+
+// latestLogPath returns the most recently modified wrapper log in
+// os.TempDir() that the current user can read. Logs of the current process
+// are ignored so the lookup never reports its own (empty) log.
+func latestLogPath() (string, error) {
+	tempDir := os.TempDir()
+
+	matches, err := listLogFiles(tempDir)
+	if err != nil {
+		return "", fmt.Errorf("latestLogPath: %w", err)
+	}
+
+	currentPID := os.Getpid()
+	var (
+		newest     string
+		newestTime time.Time
+	)
+	for _, path := range matches {
+		if pid, ok := parsePIDFromLog(path); ok && pid == currentPID {
+			continue
+		}
+		if shouldSkipFile, _ := isUnsafeFile(path, tempDir); shouldSkipFile {
+			continue
+		}
+		info, err := fileStatFn(path)
+		if err != nil {
+			continue
+		}
+		// Logs are created 0600, so unreadable files belong to other users.
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		_ = f.Close()
+
+		modTime := info.ModTime()
+		if newest == "" || modTime.After(newestTime) || (modTime.Equal(newestTime) && path > newest) {
+			newest, newestTime = path, modTime
+		}
+	}
+
+	if newest == "" {
+		return "", fmt.Errorf("no wrapper logs found in %s", tempDir)
+	}
+	return newest, nil
+}
+
 // isUnsafeFile checks if a file is unsafe to delete (symlink or outside tempDir).
 // Returns (true, reason) if the file should be skipped.
 func isUnsafeFile(path string, tempDir string) (bool, string) {
@@ -674,6 +929,10 @@ func renderWorkerLimit(limit int) string {
 
 func CleanupOldLogs() (CleanupStats, error) { return cleanupOldLogs() }
 
+func TrimLogsToLimit(maxLogs int) (CleanupStats, error) { return trimLogsToLimit(maxLogs) }
+
+func LatestLogPath() (string, error) { return latestLogPath() }
+
 func IsUnsafeFile(path string, tempDir string) (bool, string) { return isUnsafeFile(path, tempDir) }
 
 func IsPIDReused(logPath string, pid int) bool { return isPIDReused(logPath, pid) }
@@ -22,6 +22,7 @@ func TestLoggerNilReceiverNoop(t *testing.T) {
 
 func TestLoggerConcurrencyLogHelpers(t *testing.T) {
 	setTempDirEnv(t, t.TempDir())
+	t.Setenv("CODEAGENT_LOG_LEVEL", "debug")
 
 	logger, err := NewLoggerWithSuffix("concurrency")
 	if err != nil {
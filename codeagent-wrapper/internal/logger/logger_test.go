@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -46,6 +47,7 @@ func TestLoggerCreatesFileWithPID(t *testing.T) {
 
 func TestLoggerWritesLevels(t *testing.T) {
 	setTempDirEnv(t, t.TempDir())
+	t.Setenv("CODEAGENT_LOG_LEVEL", "debug")
 
 	logger, err := NewLogger()
 	if err != nil {
@@ -74,6 +76,127 @@ func TestLoggerWritesLevels(t *testing.T) {
 	}
 }
 
+func TestLoggerLevelFilter(t *testing.T) {
+	tests := []struct {
+		level string
+		want  []string
+		drop  []string
+	}{
+		{level: "", want: []string{"info message", "warn message", "error message"}, drop: []string{"debug message"}},
+		{level: "debug", want: []string{"debug message", "info message", "warn message", "error message"}},
+		{level: "WARN", want: []string{"warn message", "error message"}, drop: []string{"debug message", "info message"}},
+		{level: "error", want: []string{"error message"}, drop: []string{"debug message", "info message", "warn message"}},
+		{level: "bogus", want: []string{"info message"}, drop: []string{"debug message"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("level="+tt.level, func(t *testing.T) {
+			setTempDirEnv(t, t.TempDir())
+			t.Setenv("CODEAGENT_LOG_LEVEL", tt.level)
+
+			logger, err := NewLogger()
+			if err != nil {
+				t.Fatalf("NewLogger() error = %v", err)
+			}
+			defer logger.Close()
+
+			logger.Debug("debug message")
+			logger.Info("info message")
+			logger.Warn("warn message")
+			logger.Error("error message")
+			logger.Flush()
+
+			data, err := os.ReadFile(logger.Path())
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+			content := string(data)
+			for _, c := range tt.want {
+				if !strings.Contains(content, c) {
+					t.Errorf("log file missing entry %q, content: %s", c, content)
+				}
+			}
+			for _, c := range tt.drop {
+				if strings.Contains(content, c) {
+					t.Errorf("log file should not contain %q, content: %s", c, content)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggerMaxBytes(t *testing.T) {
+	setTempDirEnv(t, t.TempDir())
+	t.Setenv("CODEAGENT_LOG_MAX_BYTES", "512")
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 100; i++ {
+		logger.Info(fmt.Sprintf("entry-%03d %s", i, strings.Repeat("x", 40)))
+	}
+	logger.Error("late error")
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	content := string(data)
+	if strings.Count(content, "log truncated") != 1 {
+		t.Fatalf("want exactly one truncation marker, content: %s", content)
+	}
+	if strings.Contains(content, "entry-099") || strings.Contains(content, "late error") {
+		t.Fatalf("entries past the cap should be dropped, content: %s", content)
+	}
+	if len(data) > 1024 {
+		t.Fatalf("log file size = %d, want it to stay near the 512-byte cap", len(data))
+	}
+	// Dropped entries still feed the in-memory error summary.
+	if got := logger.ExtractRecentErrors(1); len(got) != 1 || got[0] != "late error" {
+		t.Fatalf("ExtractRecentErrors() = %v, want [late error]", got)
+	}
+}
+
+func TestLoggerMirrorTo(t *testing.T) {
+	setTempDirEnv(t, t.TempDir())
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	var mirror bytes.Buffer
+	logger.Info("file only")
+	logger.Flush()
+
+	logger.MirrorTo(&mirror)
+	logger.Warn("mirrored warn")
+	logger.Flush()
+
+	logger.MirrorTo(nil)
+	logger.Info("file only again")
+	logger.Flush()
+
+	if got, want := mirror.String(), "[WARN] mirrored warn\n"; got != want {
+		t.Fatalf("mirror = %q, want %q", got, want)
+	}
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	for _, c := range []string{"file only", "mirrored warn", "file only again"} {
+		if !strings.Contains(string(data), c) {
+			t.Fatalf("log file missing entry %q, content: %s", c, data)
+		}
+	}
+}
+
 func TestLoggerCloseStopsWorkerAndKeepsFile(t *testing.T) {
 	setTempDirEnv(t, t.TempDir())
 
@@ -102,6 +225,7 @@ func TestLoggerCloseStopsWorkerAndKeepsFile(t *testing.T) {
 
 func TestLoggerConcurrentWritesSafe(t *testing.T) {
 	setTempDirEnv(t, t.TempDir())
+	t.Setenv("CODEAGENT_LOG_LEVEL", "debug")
 
 	logger, err := NewLogger()
 	if err != nil {
@@ -456,6 +580,104 @@ func TestLoggerCleanupOldLogsKeepsCurrentProcessLog(t *testing.T) {
 	}
 }
 
+func TestLoggerTrimLogsToLimitKeepsNewestAndCurrent(t *testing.T) {
+	tempDir := setTempDirEnv(t, t.TempDir())
+
+	const total = 20
+	const maxLogs = 5
+	base := time.Now().Add(-time.Hour)
+	var seeded []string
+	for i := 0; i < total; i++ {
+		path := createTempLog(t, tempDir, fmt.Sprintf("codeagent-wrapper-%d.log", 1000+i))
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes(%s) error: %v", path, err)
+		}
+		seeded = append(seeded, path)
+	}
+
+	// The current process log is the oldest file but must survive the trim.
+	currentLog := createTempLog(t, tempDir, fmt.Sprintf("codeagent-wrapper-%d.log", os.Getpid()))
+	oldest := base.Add(-time.Hour)
+	if err := os.Chtimes(currentLog, oldest, oldest); err != nil {
+		t.Fatalf("Chtimes(%s) error: %v", currentLog, err)
+	}
+
+	stats, err := trimLogsToLimit(maxLogs)
+	if err != nil {
+		t.Fatalf("trimLogsToLimit() unexpected error: %v", err)
+	}
+	want := CleanupStats{Scanned: total + 1, Deleted: total - maxLogs, Kept: maxLogs + 1}
+	if !compareCleanupStats(stats, want) {
+		t.Fatalf("trim stats mismatch: got %+v, want %+v", stats, want)
+	}
+
+	for i, path := range seeded {
+		_, err := os.Stat(path)
+		if i >= total-maxLogs {
+			if err != nil {
+				t.Fatalf("expected newest log %s to remain, err=%v", path, err)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected old log %s to be removed, err=%v", path, err)
+		}
+	}
+	if _, err := os.Stat(currentLog); err != nil {
+		t.Fatalf("expected current process log to remain, err=%v", err)
+	}
+}
+
+func TestLoggerTrimLogsToLimitDisabled(t *testing.T) {
+	tempDir := setTempDirEnv(t, t.TempDir())
+	path := createTempLog(t, tempDir, "codeagent-wrapper-111.log")
+
+	stats, err := trimLogsToLimit(0)
+	if err != nil {
+		t.Fatalf("trimLogsToLimit(0) unexpected error: %v", err)
+	}
+	if stats.Scanned != 0 || stats.Deleted != 0 {
+		t.Fatalf("expected no-op stats, got %+v", stats)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected log to remain, err=%v", err)
+	}
+}
+
+func TestLoggerLatestLogPathPicksNewest(t *testing.T) {
+	tempDir := setTempDirEnv(t, t.TempDir())
+
+	if _, err := latestLogPath(); err == nil {
+		t.Fatalf("expected error when no logs exist")
+	}
+
+	base := time.Now().Add(-time.Hour)
+	offsets := map[string]time.Duration{
+		"codeagent-wrapper-1001.log":      10 * time.Minute,
+		"codeagent-wrapper-1002.log":      40 * time.Minute,
+		"codeagent-wrapper-1003-task.log": 25 * time.Minute,
+		"codeagent-wrapper-1004.log":      5 * time.Minute,
+	}
+	for name, offset := range offsets {
+		path := createTempLog(t, tempDir, name)
+		modTime := base.Add(offset)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes(%s) error: %v", path, err)
+		}
+	}
+	// The current process log is newest but must never be reported.
+	createTempLog(t, tempDir, fmt.Sprintf("codeagent-wrapper-%d.log", os.Getpid()))
+
+	got, err := latestLogPath()
+	if err != nil {
+		t.Fatalf("latestLogPath() unexpected error: %v", err)
+	}
+	if want := filepath.Join(tempDir, "codeagent-wrapper-1002.log"); got != want {
+		t.Fatalf("latestLogPath() = %q, want %q", got, want)
+	}
+}
+
 func TestLoggerIsPIDReusedScenarios(t *testing.T) {
 	now := time.Now()
 	tests := []struct {
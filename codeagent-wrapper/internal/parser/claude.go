@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// extractClaudeText collects the text carried by a Claude message payload.
+// It accepts a message object ({"content": ...}), a bare content array or a
+// plain string, and recurses into tool_result blocks and nested block arrays.
+// Non-text blocks such as tool_use and thinking are ignored.
+func extractClaudeText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(claudeTextFromValue(v))
+}
+
+func claudeTextFromValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []interface{}:
+		parts := make([]string, 0, len(val))
+		for _, item := range val {
+			if text := claudeTextFromValue(item); strings.TrimSpace(text) != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case map[string]interface{}:
+		blockType, _ := val["type"].(string)
+		switch blockType {
+		case "text":
+			text, _ := val["text"].(string)
+			return text
+		case "tool_use", "server_tool_use", "thinking", "redacted_thinking", "image":
+			return ""
+		}
+		if content, ok := val["content"]; ok {
+			return claudeTextFromValue(content)
+		}
+		if text, ok := val["text"].(string); ok {
+			return text
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// extractClaudeThinking collects the text of the thinking blocks in a Claude
+// message payload ({"content": [...]} or a bare content array).
+// redacted_thinking blocks carry no readable text and are skipped.
+func extractClaudeThinking(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var msg struct {
+		Content json.RawMessage `json:"content"`
+	}
+	content := raw
+	if err := json.Unmarshal(raw, &msg); err == nil && len(msg.Content) > 0 {
+		content = msg.Content
+	}
+	var blocks []struct {
+		Type     string `json:"type"`
+		Thinking string `json:"thinking"`
+	}
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return ""
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "thinking" && strings.TrimSpace(block.Thinking) != "" {
+			parts = append(parts, strings.TrimSpace(block.Thinking))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
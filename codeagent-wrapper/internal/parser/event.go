@@ -37,22 +37,27 @@ type GeminiEvent struct {
 // to avoid multiple JSON unmarshal operations per event.
 type UnifiedEvent struct {
 	// Common fields
-	Type string `json:"type"`
+	Type  string          `json:"type"`
+	Error json.RawMessage `json:"error,omitempty"` // turn.failed/error payload (string or object)
+	Usage json.RawMessage `json:"usage,omitempty"` // Codex turn.completed / Claude result token usage
 
 	// Codex-specific fields
 	ThreadID string          `json:"thread_id,omitempty"`
 	Item     json.RawMessage `json:"item,omitempty"` // Lazy parse
 
 	// Claude-specific fields
-	Subtype   string `json:"subtype,omitempty"`
-	SessionID string `json:"session_id,omitempty"`
-	Result    string `json:"result,omitempty"`
+	Subtype   string          `json:"subtype,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	Message   json.RawMessage `json:"message,omitempty"` // Lazy parse
+	IsError   bool            `json:"is_error,omitempty"`
 
 	// Gemini-specific fields
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
-	Delta   *bool  `json:"delta,omitempty"`
-	Status  string `json:"status,omitempty"`
+	Role    string          `json:"role,omitempty"`
+	Content string          `json:"content,omitempty"`
+	Delta   *bool           `json:"delta,omitempty"`
+	Status  string          `json:"status,omitempty"`
+	Stats   json.RawMessage `json:"stats,omitempty"` // result token usage
 
 	// Opencode-specific fields (camelCase sessionID)
 	OpencodeSessionID string          `json:"sessionID,omitempty"`
@@ -65,6 +70,31 @@ type OpencodePart struct {
 	Text      string `json:"text,omitempty"`
 	Reason    string `json:"reason,omitempty"`
 	SessionID string `json:"sessionID,omitempty"`
+	MessageID string `json:"messageID,omitempty"`
+	// Tokens is the usage a step-finish part reports.
+	Tokens *struct {
+		Input  int `json:"input"`
+		Output int `json:"output"`
+	} `json:"tokens,omitempty"`
+}
+
+// TokenUsage is the token count a backend reported for a run.
+type TokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+func (u *TokenUsage) add(other TokenUsage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// FileChange is one entry of a Codex file_change item.
+type FileChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind,omitempty"`
 }
 
 // ItemContent represents the parsed item.text field for Codex events.
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// ExtractorRule teaches the parser to read a backend's output when its event
+// shape is not one of the built-in formats. Paths are dot-separated; numeric
+// segments index into arrays ("content.0.text").
+type ExtractorRule struct {
+	// MessageType is the event "type" whose MessagePath holds the message.
+	MessageType string
+	// MessagePath locates the message text inside a MessageType event.
+	MessagePath string
+	// SessionPath optionally locates the session id in any event.
+	SessionPath string
+	// CompleteType optionally names the event type that ends the turn.
+	CompleteType string
+	// Append concatenates the text of successive message events (streamed
+	// deltas) instead of keeping only the last one.
+	Append bool
+}
+
+func (r *ExtractorRule) enabled() bool {
+	return r != nil && strings.TrimSpace(r.MessageType) != "" && strings.TrimSpace(r.MessagePath) != ""
+}
+
+// ParseOptions tunes ParseJSONStreamWithOptions.
+type ParseOptions struct {
+	// Validate checks every event against the expected schema (see
+	// ParseJSONStreamValidated).
+	Validate bool
+	// Extractor, when set, is tried on every event before the built-in
+	// backend detection.
+	Extractor *ExtractorRule
+	// OnText, when set, receives assistant text as it arrives: the appended
+	// delta for streaming shapes (Gemini, opencode, appending extractors) and
+	// each new whole message for the others (Codex, Claude).
+	OnText func(text string)
+	// OnReasoning, when set, receives the text of Codex reasoning items and
+	// Claude thinking blocks. That text never becomes part of the message.
+	OnReasoning func(text string)
+	// OnEvent, when set, is called with the type of every event that
+	// decoded as JSON, before it is interpreted.
+	OnEvent func(eventType string)
+	// OnReconnect, when set, is called for every error event announcing a
+	// reconnect ("Reconnecting... 2/5") with the number of such events seen
+	// in a row; any other event resets the count.
+	OnReconnect func(consecutive int, message string)
+	// OnPlainLine, when set, receives every non-empty line that is not valid
+	// JSON. The slice is only valid for the duration of the call.
+	OnPlainLine func(line []byte)
+	// MaxLineBytes caps a single event line; longer lines are skipped with a
+	// warning without being buffered. 0 keeps the default (10 MiB) and a
+	// negative value selects the hard ceiling (256 MiB), which also bounds
+	// larger caps: a line within the cap is held whole while it is decoded.
+	MaxLineBytes int
+}
+
+// customExtraction is what an ExtractorRule found in a single event.
+type customExtraction struct {
+	message  string
+	hasText  bool
+	session  string
+	complete bool
+}
+
+// extract applies rule to a raw event line of the given type.
+func (r *ExtractorRule) extract(eventType string, line []byte) (customExtraction, bool) {
+	var out customExtraction
+	wantMessage := eventType == r.MessageType
+	wantSession := strings.TrimSpace(r.SessionPath) != ""
+	out.complete = r.CompleteType != "" && eventType == r.CompleteType
+	if !wantMessage && !wantSession && !out.complete {
+		return out, false
+	}
+
+	var doc any
+	if err := json.Unmarshal(line, &doc); err != nil {
+		return out, false
+	}
+	if wantSession {
+		if value, ok := lookupJSONPath(doc, r.SessionPath); ok {
+			if session, ok := value.(string); ok {
+				out.session = session
+			}
+		}
+	}
+	if wantMessage {
+		if value, ok := lookupJSONPath(doc, r.MessagePath); ok {
+			out.message = NormalizeText(value)
+			out.hasText = out.message != ""
+		}
+	}
+	return out, out.hasText || out.complete
+}
+
+// lookupJSONPath walks a decoded JSON document along a dot-separated path.
+func lookupJSONPath(doc any, path string) (any, bool) {
+	current := doc
+	for _, segment := range strings.Split(strings.TrimSpace(path), ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -18,6 +18,10 @@ const (
 	jsonLinePreviewBytes = 256
 )
 
+// jsonLineCeilingBytes bounds the memory a single event line may take: a line
+// is held whole while it is decoded, so "no limit" means this ceiling.
+const jsonLineCeilingBytes = 256 * 1024 * 1024
+
 type lineScratch struct {
 	buf     []byte
 	preview []byte
@@ -34,7 +38,65 @@ var lineScratchPool = sync.Pool{
 	},
 }
 
+// StreamResult is the detailed outcome of parsing a backend JSON stream.
+type StreamResult struct {
+	Message  string
+	ThreadID string
+	// Errors holds the messages of turn.failed/error events (and failed result
+	// events) in stream order, even when the backend later exits 0.
+	Errors []string
+	// SchemaViolations lists events missing fields expected for their type.
+	// It is only populated by ParseJSONStreamValidated.
+	SchemaViolations []string
+	// FileChanges lists the files reported by file_change items, one entry
+	// per path in first-seen order (kind reflects the latest change).
+	FileChanges []FileChange
+	// Usage sums the token counts reported by the backend (Codex
+	// turn.completed, Claude/Gemini result, opencode step-finish events).
+	Usage TokenUsage
+}
+
 func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func()) (message, threadID string) {
+	res := ParseJSONStream(r, warnFn, infoFn, onMessage, onComplete)
+	return res.Message, res.ThreadID
+}
+
+// ParseJSONStream parses a backend JSON stream and returns the final message,
+// session id and any error events seen along the way.
+func ParseJSONStream(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func()) StreamResult {
+	return parseJSONStream(r, warnFn, infoFn, onMessage, onComplete, ParseOptions{})
+}
+
+// ParseJSONStreamValidated is ParseJSONStream that additionally checks every
+// event for the minimal fields expected for its type. Violations are logged as
+// warnings and returned in SchemaViolations; they never fail the parse.
+func ParseJSONStreamValidated(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func()) StreamResult {
+	return parseJSONStream(r, warnFn, infoFn, onMessage, onComplete, ParseOptions{Validate: true})
+}
+
+// ParseJSONStreamWithOptions is ParseJSONStream with schema validation and a
+// custom message extractor selectable through opts.
+func ParseJSONStreamWithOptions(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func(), opts ParseOptions) StreamResult {
+	return parseJSONStream(r, warnFn, infoFn, onMessage, onComplete, opts)
+}
+
+func parseJSONStream(r io.Reader, warnFn func(string), infoFn func(string), onMessage func(), onComplete func(), opts ParseOptions) StreamResult {
+	validate := opts.Validate
+	maxLineBytes := resolveMaxLineBytes(opts.MaxLineBytes)
+	extractor := opts.Extractor
+	if !extractor.enabled() {
+		extractor = nil
+	}
+
+	var (
+		message    string
+		threadID   string
+		streamErrs []string
+		violations []string
+		changes    []FileChange
+		usage      TokenUsage
+	)
+	changeIndex := make(map[string]int)
 	reader := bufio.NewReaderSize(r, jsonLineReaderSize)
 	scratch := lineScratchPool.Get().(*lineScratch)
 	if scratch.buf == nil {
@@ -74,6 +136,22 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 		}
 	}
 
+	// emitDelta and emitMessage feed opts.OnText; emitMessage skips a
+	// message equal to the last one (Claude repeats its text in result).
+	var lastEmitted string
+	emitDelta := func(text string) {
+		if opts.OnText != nil && text != "" {
+			opts.OnText(text)
+		}
+	}
+	emitMessage := func(text string) {
+		if opts.OnText == nil || text == "" || text == lastEmitted {
+			return
+		}
+		lastEmitted = text
+		opts.OnText(text + "\n")
+	}
+
 	notifyComplete := func() {
 		if onComplete != nil {
 			onComplete()
@@ -81,16 +159,24 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 	}
 
 	totalEvents := 0
+	reconnects := 0
 
 	var (
 		codexMessage    string
 		claudeMessage   string
+		claudeContent   string
 		geminiBuffer    strings.Builder
 		opencodeMessage strings.Builder
+		customMessage   strings.Builder
+
+		// opencodeMessageID is the assistant message opencodeMessage holds;
+		// text from a newer message (the next step after tool calls)
+		// replaces it so the result is the final assistant message.
+		opencodeMessageID string
 	)
 
 	for {
-		line, tooLong, err := readLineWithLimit(reader, jsonLineMaxBytes, jsonLinePreviewBytes, scratch)
+		line, tooLong, err := readLineWithLimit(reader, maxLineBytes, jsonLinePreviewBytes, scratch)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
@@ -106,7 +192,7 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 		totalEvents++
 
 		if tooLong {
-			warnFn(fmt.Sprintf("Skipped overlong JSON line (> %d bytes): %s", jsonLineMaxBytes, TruncateBytes(line, 100)))
+			warnFn(fmt.Sprintf("Skipped overlong JSON line (> %d bytes): %s", maxLineBytes, TruncateBytes(line, 100)))
 			continue
 		}
 
@@ -114,6 +200,67 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 		var event UnifiedEvent
 		if err := json.Unmarshal(line, &event); err != nil {
 			warnFn(fmt.Sprintf("Failed to parse event: %s", TruncateBytes(line, 100)))
+			if opts.OnPlainLine != nil {
+				opts.OnPlainLine(line)
+			}
+			continue
+		}
+		if opts.OnEvent != nil {
+			opts.OnEvent(event.Type)
+		}
+		if event.Type != "error" && event.Type != "turn.failed" {
+			reconnects = 0
+		}
+
+		if validate {
+			if problems := validateEvent(event); len(problems) > 0 {
+				violation := fmt.Sprintf("event #%d type=%q: %s", totalEvents, event.Type, strings.Join(problems, ", "))
+				violations = append(violations, violation)
+				warnFn("Schema violation: " + violation + " (" + TruncateBytes(line, 100) + ")")
+			}
+		}
+
+		// A configured extractor takes precedence over built-in detection so
+		// custom event shapes are not misread as one of the known backends.
+		if extractor != nil {
+			if found, ok := extractor.extract(event.Type, line); ok || found.session != "" {
+				if found.session != "" && threadID == "" {
+					threadID = found.session
+				}
+				if found.hasText {
+					if !extractor.Append {
+						customMessage.Reset()
+						emitMessage(found.message)
+					} else {
+						emitDelta(found.message)
+					}
+					customMessage.WriteString(found.message)
+					infoFn(fmt.Sprintf("Parsed custom event #%d type=%s message_len=%d", totalEvents, event.Type, len(found.message)))
+					notifyMessage()
+				}
+				if found.complete {
+					notifyComplete()
+				}
+				if ok {
+					continue
+				}
+			}
+		}
+
+		// Error events carry no backend-specific markers; record them first.
+		// Reconnect notices are not failures: the turn may still complete.
+		if event.Type == "turn.failed" || event.Type == "error" {
+			errMsg := eventErrorMessage(event)
+			warnFn(fmt.Sprintf("%s event: %s", event.Type, errMsg))
+			if isReconnectMessage(errMsg) {
+				reconnects++
+				if opts.OnReconnect != nil {
+					opts.OnReconnect(reconnects, errMsg)
+				}
+			} else {
+				streamErrs = append(streamErrs, errMsg)
+				reconnects = 0
+			}
 			continue
 		}
 
@@ -135,6 +282,9 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 		if !isClaude && event.Type == "result" && event.SessionID != "" && event.Status == "" {
 			isClaude = true
 		}
+		if !isClaude && event.Type == "assistant" && len(event.Message) > 0 {
+			isClaude = true
+		}
 		isGemini := (event.Type == "init" && event.SessionID != "") || event.Role != "" || event.Delta != nil || event.Status != ""
 		isOpencode := event.OpencodeSessionID != "" && len(event.Part) > 0
 
@@ -158,10 +308,26 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 			infoFn(fmt.Sprintf("Parsed Opencode event #%d type=%s part_type=%s", totalEvents, event.Type, part.Type))
 
 			if event.Type == "text" && part.Text != "" {
+				if part.MessageID != "" && part.MessageID != opencodeMessageID {
+					if opencodeMessage.Len() > 0 {
+						emitDelta("\n")
+					}
+					opencodeMessage.Reset()
+					opencodeMessageID = part.MessageID
+				}
 				opencodeMessage.WriteString(part.Text)
+				emitDelta(part.Text)
 				notifyMessage()
 			}
 
+			if part.Type == "step-finish" && part.Tokens != nil {
+				usage.add(TokenUsage{
+					InputTokens:  part.Tokens.Input,
+					OutputTokens: part.Tokens.Output,
+					TotalTokens:  part.Tokens.Input + part.Tokens.Output,
+				})
+			}
+
 			if part.Type == "step-finish" && part.Reason == "stop" {
 				notifyComplete()
 			}
@@ -194,6 +360,9 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 				notifyComplete()
 
 			case "turn.completed":
+				if turn, ok := parseTokenUsage(event.Usage); ok {
+					usage.add(turn)
+				}
 				infoFn("turn.completed event")
 				notifyComplete()
 
@@ -216,11 +385,41 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 						infoFn(fmt.Sprintf("item.completed event item_type=%s message_len=%d", itemType, len(normalized)))
 						if normalized != "" {
 							codexMessage = normalized
+							emitMessage(normalized)
 							notifyMessage()
 						}
 					} else {
 						warnFn(fmt.Sprintf("Failed to parse item content: %s", err.Error()))
 					}
+				} else if itemType == "reasoning" && len(event.Item) > 0 {
+					var item ItemContent
+					if err := json.Unmarshal(event.Item, &item); err == nil {
+						text := strings.TrimSpace(NormalizeText(item.Text))
+						infoFn(fmt.Sprintf("item.completed event item_type=reasoning text_len=%d", len(text)))
+						if opts.OnReasoning != nil && text != "" {
+							opts.OnReasoning(text)
+						}
+					}
+				} else if itemType == "file_change" {
+					var item struct {
+						Changes []FileChange `json:"changes"`
+					}
+					if err := json.Unmarshal(event.Item, &item); err != nil {
+						warnFn(fmt.Sprintf("Failed to parse file_change item: %s", err.Error()))
+						continue
+					}
+					for _, change := range item.Changes {
+						if change.Path == "" {
+							continue
+						}
+						if idx, ok := changeIndex[change.Path]; ok {
+							changes[idx].Kind = change.Kind
+							continue
+						}
+						changeIndex[change.Path] = len(changes)
+						changes = append(changes, change)
+					}
+					infoFn(fmt.Sprintf("item.completed event item_type=file_change changes=%d", len(item.Changes)))
 				} else {
 					infoFn(fmt.Sprintf("item.completed event item_type=%s", itemType))
 				}
@@ -236,12 +435,42 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 
 			infoFn(fmt.Sprintf("Parsed Claude event #%d type=%s subtype=%s result_len=%d", totalEvents, event.Type, event.Subtype, len(event.Result)))
 
+			// Assistant content blocks are kept as a fallback for streams whose
+			// result event carries no text.
+			if event.Type == "assistant" {
+				if opts.OnReasoning != nil {
+					if thinking := extractClaudeThinking(event.Message); thinking != "" {
+						opts.OnReasoning(thinking)
+					}
+				}
+				if text := extractClaudeText(event.Message); text != "" {
+					claudeContent = text
+					emitMessage(text)
+					notifyMessage()
+				}
+				continue
+			}
+
 			if event.Result != "" {
 				claudeMessage = event.Result
+				if !event.IsError {
+					emitMessage(event.Result)
+				}
 				notifyMessage()
 			}
 
 			if event.Type == "result" {
+				// The result event reports the usage of the whole session.
+				if total, ok := parseTokenUsage(event.Usage); ok {
+					usage = total
+				}
+				if event.IsError || strings.HasPrefix(event.Subtype, "error") {
+					errMsg := strings.TrimSpace(event.Result)
+					if errMsg == "" {
+						errMsg = event.Subtype
+					}
+					streamErrs = append(streamErrs, errMsg)
+				}
 				notifyComplete()
 			}
 			continue
@@ -255,12 +484,19 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 
 			if event.Content != "" {
 				geminiBuffer.WriteString(event.Content)
+				emitDelta(event.Content)
 			}
 
 			if event.Status != "" {
 				notifyMessage()
 
 				if event.Type == "result" && (event.Status == "success" || event.Status == "error" || event.Status == "complete" || event.Status == "failed") {
+					if event.Status == "error" || event.Status == "failed" {
+						streamErrs = append(streamErrs, eventErrorMessage(event))
+					}
+					if total, ok := parseTokenUsage(event.Stats); ok {
+						usage = total
+					}
 					notifyComplete()
 				}
 			}
@@ -279,18 +515,91 @@ func ParseJSONStreamInternal(r io.Reader, warnFn func(string), infoFn func(strin
 	}
 
 	switch {
+	case customMessage.Len() > 0:
+		message = customMessage.String()
 	case opencodeMessage.Len() > 0:
 		message = opencodeMessage.String()
 	case geminiBuffer.Len() > 0:
 		message = geminiBuffer.String()
 	case claudeMessage != "":
 		message = claudeMessage
+	case claudeContent != "":
+		message = claudeContent
 	default:
 		message = codexMessage
 	}
 
-	infoFn(fmt.Sprintf("parseJSONStream completed: events=%d, message_len=%d, thread_id_found=%t", totalEvents, len(message), threadID != ""))
-	return message, threadID
+	infoFn(fmt.Sprintf("parseJSONStream completed: events=%d, message_len=%d, thread_id_found=%t, tokens=%d", totalEvents, len(message), threadID != "", usage.TotalTokens))
+	if validate {
+		if len(violations) > 0 {
+			warnFn(fmt.Sprintf("Stream schema validation: %d of %d events violated the expected schema", len(violations), totalEvents))
+		} else {
+			infoFn(fmt.Sprintf("Stream schema validation: %d events OK", totalEvents))
+		}
+	}
+	return StreamResult{Message: message, ThreadID: threadID, Errors: streamErrs, SchemaViolations: violations, FileChanges: changes, Usage: usage}
+}
+
+// parseTokenUsage decodes a usage/stats object. Claude reports cache reads
+// and writes separately from input_tokens, so they are counted as input;
+// total_tokens is used when the backend provides it.
+func parseTokenUsage(raw json.RawMessage) (TokenUsage, bool) {
+	if len(raw) == 0 {
+		return TokenUsage{}, false
+	}
+	var fields struct {
+		InputTokens         int `json:"input_tokens"`
+		OutputTokens        int `json:"output_tokens"`
+		TotalTokens         int `json:"total_tokens"`
+		CacheCreationTokens int `json:"cache_creation_input_tokens"`
+		CacheReadTokens     int `json:"cache_read_input_tokens"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return TokenUsage{}, false
+	}
+	usage := TokenUsage{
+		InputTokens:  fields.InputTokens + fields.CacheCreationTokens + fields.CacheReadTokens,
+		OutputTokens: fields.OutputTokens,
+		TotalTokens:  fields.TotalTokens,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+	return usage, usage.TotalTokens > 0
+}
+
+// isReconnectMessage reports whether an error event only announces that the
+// backend is retrying its connection (Codex: "Reconnecting... 1/5").
+func isReconnectMessage(msg string) bool {
+	return strings.Contains(strings.ToLower(msg), "reconnecting")
+}
+
+// eventErrorMessage extracts a human-readable message from an error event.
+// The error field may be a plain string or an object with a message; codex
+// "error" events put the text in a top-level message string instead.
+func eventErrorMessage(event UnifiedEvent) string {
+	if len(event.Error) > 0 {
+		var text string
+		if json.Unmarshal(event.Error, &text) == nil && strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text)
+		}
+		var obj struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(event.Error, &obj) == nil && strings.TrimSpace(obj.Message) != "" {
+			return strings.TrimSpace(obj.Message)
+		}
+	}
+	if len(event.Message) > 0 {
+		var text string
+		if json.Unmarshal(event.Message, &text) == nil && strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text)
+		}
+	}
+	if event.Status != "" {
+		return event.Type + " status=" + event.Status
+	}
+	return event.Type
 }
 
 func HasKey(m map[string]json.RawMessage, key string) bool {
@@ -324,6 +633,20 @@ func DiscardInvalidJSON(decoder *json.Decoder, reader *bufio.Reader) (*bufio.Rea
 	return bufio.NewReader(io.MultiReader(bytes.NewReader(remaining), reader)), err
 }
 
+// resolveMaxLineBytes maps ParseOptions.MaxLineBytes to the per-line cap: 0
+// keeps the default, a negative value selects jsonLineCeilingBytes and larger
+// caps are clamped to it.
+func resolveMaxLineBytes(n int) int {
+	switch {
+	case n == 0:
+		return jsonLineMaxBytes
+	case n < 0 || n > jsonLineCeilingBytes:
+		return jsonLineCeilingBytes
+	default:
+		return n
+	}
+}
+
 func readLineWithLimit(r *bufio.Reader, maxBytes int, previewBytes int, scratch *lineScratch) (line []byte, tooLong bool, err error) {
 	if r == nil {
 		return nil, false, errors.New("reader is nil")
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestExtractClaudeText(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "plain text blocks",
+			raw:  `{"role":"assistant","content":[{"type":"text","text":"Hello"},{"type":"text","text":"World"}]}`,
+			want: "Hello\nWorld",
+		},
+		{
+			name: "tool_result with string content",
+			raw:  `{"role":"assistant","content":[{"type":"tool_result","tool_use_id":"t1","content":"answer from tool"}]}`,
+			want: "answer from tool",
+		},
+		{
+			name: "tool_result with nested block array",
+			raw:  `{"content":[{"type":"tool_result","content":[{"type":"text","text":"nested"},[{"type":"text","text":"deeper"}]]}]}`,
+			want: "nested\ndeeper",
+		},
+		{
+			name: "skips tool_use and thinking",
+			raw:  `{"content":[{"type":"thinking","thinking":"hmm"},{"type":"tool_use","name":"Read","input":{"text":"x"}},{"type":"text","text":"final"}]}`,
+			want: "final",
+		},
+		{
+			name: "bare string content",
+			raw:  `{"content":"just text"}`,
+			want: "just text",
+		},
+		{name: "empty", raw: ``, want: ""},
+		{name: "invalid json", raw: `{`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractClaudeText(json.RawMessage(tt.raw)); got != tt.want {
+				t.Fatalf("extractClaudeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONStream_ClaudeToolResultContent(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"system","subtype":"init","session_id":"sess-1"}`,
+		`{"type":"assistant","session_id":"sess-1","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Task","input":{}}]}}`,
+		`{"type":"assistant","session_id":"sess-1","message":{"role":"assistant","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"final answer"}]}]}}`,
+		`{"type":"result","subtype":"success","session_id":"sess-1"}`,
+	}, "\n")
+
+	messages := 0
+	message, threadID := ParseJSONStreamInternal(strings.NewReader(input), nil, nil, func() { messages++ }, nil)
+	if message != "final answer" {
+		t.Fatalf("message=%q, want %q", message, "final answer")
+	}
+	if threadID != "sess-1" {
+		t.Fatalf("threadID=%q, want %q", threadID, "sess-1")
+	}
+	if messages == 0 {
+		t.Fatalf("expected onMessage to fire for assistant content")
+	}
+}
+
+func TestParseJSONStream_ClaudeResultTakesPrecedence(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"assistant","session_id":"sess-2","message":{"content":[{"type":"text","text":"draft"}]}}`,
+		`{"type":"result","subtype":"success","result":"final","session_id":"sess-2"}`,
+	}, "\n")
+
+	message, _ := ParseJSONStreamInternal(strings.NewReader(input), nil, nil, nil, nil)
+	if message != "final" {
+		t.Fatalf("message=%q, want %q", message, "final")
+	}
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSONStreamWithOptions_CustomExtractor(t *testing.T) {
+	// A made-up CLI: the session arrives in meta.conversation, the answer in
+	// a "final" event under payload.blocks.0.body, and "done" ends the turn.
+	// The "status" field would otherwise look like a Gemini event.
+	lines := []string{
+		`{"type":"hello","meta":{"conversation":"conv-7"}}`,
+		`{"type":"progress","pct":50}`,
+		`{"type":"final","status":"ok","payload":{"blocks":[{"body":"first draft"}]}}`,
+		`{"type":"final","status":"ok","payload":{"blocks":[{"body":"custom answer"}]}}`,
+		`{"type":"done"}`,
+	}
+	rule := &ExtractorRule{
+		MessageType:  "final",
+		MessagePath:  "payload.blocks.0.body",
+		SessionPath:  "meta.conversation",
+		CompleteType: "done",
+	}
+
+	var messages, completes int
+	res := ParseJSONStreamWithOptions(strings.NewReader(strings.Join(lines, "\n")), nil, nil,
+		func() { messages++ }, func() { completes++ }, ParseOptions{Extractor: rule})
+
+	if res.Message != "custom answer" {
+		t.Fatalf("Message = %q, want %q", res.Message, "custom answer")
+	}
+	if res.ThreadID != "conv-7" {
+		t.Fatalf("ThreadID = %q, want %q", res.ThreadID, "conv-7")
+	}
+	if messages != 2 || completes != 1 {
+		t.Fatalf("callbacks: messages=%d completes=%d, want 2 and 1", messages, completes)
+	}
+
+	// Append mode concatenates streamed deltas.
+	rule.Append = true
+	res = ParseJSONStreamWithOptions(strings.NewReader(strings.Join(lines, "\n")), nil, nil, nil, nil, ParseOptions{Extractor: rule})
+	if res.Message != "first draftcustom answer" {
+		t.Fatalf("appended Message = %q", res.Message)
+	}
+
+	// Without a rule the same stream yields no message.
+	res = ParseJSONStream(strings.NewReader(strings.Join(lines, "\n")), nil, nil, nil, nil)
+	if res.Message != "" {
+		t.Fatalf("Message without extractor = %q, want empty", res.Message)
+	}
+}
+
+func TestParseJSONStreamWithOptions_ExtractorKeepsBuiltinBackends(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started","thread_id":"codex-1"}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"codex answer"}}`,
+	}
+	rule := &ExtractorRule{MessageType: "final", MessagePath: "text"}
+	res := ParseJSONStreamWithOptions(strings.NewReader(strings.Join(lines, "\n")), nil, nil, nil, nil, ParseOptions{Extractor: rule})
+	if res.Message != "codex answer" || res.ThreadID != "codex-1" {
+		t.Fatalf("result = %+v, want codex message and thread", res)
+	}
+
+	// Incomplete rules are ignored.
+	res = ParseJSONStreamWithOptions(strings.NewReader(`{"type":"final","text":"x"}`), nil, nil, nil, nil, ParseOptions{Extractor: &ExtractorRule{MessageType: "final"}})
+	if res.Message != "" {
+		t.Fatalf("Message with incomplete rule = %q, want empty", res.Message)
+	}
+}
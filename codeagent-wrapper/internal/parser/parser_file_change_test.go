@@ -0,0 +1,25 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONStream_CollectsFileChanges(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"item.completed","item":{"type":"file_change","changes":[{"path":"a.go","kind":"add"},{"path":"b.go","kind":"update"}]}}`,
+		`{"type":"item.completed","item":{"type":"file_change","changes":[{"path":"a.go","kind":"update"},{"path":""}]}}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+	}
+	res := ParseJSONStream(strings.NewReader(strings.Join(lines, "\n")), nil, nil, nil, nil)
+
+	want := []FileChange{{Path: "a.go", Kind: "update"}, {Path: "b.go", Kind: "update"}}
+	if !reflect.DeepEqual(res.FileChanges, want) {
+		t.Fatalf("FileChanges = %+v, want %+v", res.FileChanges, want)
+	}
+	if res.Message != "done" {
+		t.Fatalf("Message = %q, want %q", res.Message, "done")
+	}
+}
@@ -48,3 +48,22 @@ func TestParseJSONStream_Opencode_NoStopReason(t *testing.T) {
 		t.Errorf("message = %q, want %q", message, "Content")
 	}
 }
+
+func TestParseJSONStream_Opencode_FinalAssistantMessage(t *testing.T) {
+	input := `{"type":"step_start","sessionID":"ses_789","part":{"type":"step-start","messageID":"msg_1"}}
+{"type":"text","sessionID":"ses_789","part":{"type":"text","messageID":"msg_1","text":"Let me read the file."}}
+{"type":"tool_use","sessionID":"ses_789","part":{"type":"tool","messageID":"msg_1"}}
+{"type":"step_finish","sessionID":"ses_789","part":{"type":"step-finish","messageID":"msg_1","reason":"tool-calls"}}
+{"type":"text","sessionID":"ses_789","part":{"type":"text","messageID":"msg_2","text":"The file "}}
+{"type":"text","sessionID":"ses_789","part":{"type":"text","messageID":"msg_2","text":"looks fine."}}
+{"type":"step_finish","sessionID":"ses_789","part":{"type":"step-finish","messageID":"msg_2","reason":"stop"}}`
+
+	message, threadID := ParseJSONStreamInternal(strings.NewReader(input), nil, nil, nil, nil)
+
+	if threadID != "ses_789" {
+		t.Errorf("threadID = %q, want %q", threadID, "ses_789")
+	}
+	if message != "The file looks fine." {
+		t.Errorf("message = %q, want the final assistant message %q", message, "The file looks fine.")
+	}
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSONStreamValidated_ReportsSchemaViolations(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started"}`,
+		`{"type":"item.completed","item":{"text":"no type"}}`,
+		`{"type":"item.completed","item":{"type":"agent_message"}}`,
+		`{"type":"turn.failed"}`,
+		`{"thread_id":"t1"}`,
+		`{"type":"result","session_id":"s1"}`,
+		`{"type":"result","subtype":"success","result":"claude done"}`,
+		`{"type":"init"}`,
+		`{"type":"text","sessionID":"oc1","part":{}}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"valid"}}`,
+	}
+
+	var warnings []string
+	res := ParseJSONStreamValidated(strings.NewReader(strings.Join(lines, "\n")), func(msg string) {
+		warnings = append(warnings, msg)
+	}, nil, nil, nil)
+
+	want := []string{
+		`event #1 type="thread.started": missing thread_id`,
+		`event #2 type="item.completed": missing item.type`,
+		`event #3 type="item.completed": missing item.text`,
+		`event #4 type="turn.failed": missing error or message`,
+		`event #5 type="": missing type`,
+		`event #6 type="result": missing subtype or status`,
+		`event #7 type="result": missing session_id`,
+		`event #8 type="init": missing session_id`,
+		`event #9 type="text": missing part.type`,
+	}
+	if strings.Join(res.SchemaViolations, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("SchemaViolations =\n%s\nwant\n%s", strings.Join(res.SchemaViolations, "\n"), strings.Join(want, "\n"))
+	}
+
+	joined := strings.Join(warnings, "\n")
+	for _, v := range want {
+		if !strings.Contains(joined, "Schema violation: "+v) {
+			t.Fatalf("warnings missing %q:\n%s", v, joined)
+		}
+	}
+	if !strings.Contains(joined, "9 of 10 events violated the expected schema") {
+		t.Fatalf("warnings missing summary:\n%s", joined)
+	}
+
+	// Validation only reports; parsing proceeds as usual.
+	if res.Message != "claude done" {
+		t.Fatalf("Message = %q, want parsing unaffected", res.Message)
+	}
+}
+
+func TestParseJSONStreamValidated_CleanStream(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"turn.started"}`,
+		`{"type":"item.completed","item":{"type":"reasoning","text":"thinking"}}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`,
+		`{"type":"error","message":"Reconnecting... 1/5"}`,
+		`{"type":"turn.completed"}`,
+	}
+	var warnings []string
+	res := ParseJSONStreamValidated(strings.NewReader(strings.Join(lines, "\n")), func(msg string) {
+		warnings = append(warnings, msg)
+	}, nil, nil, nil)
+	if len(res.SchemaViolations) != 0 {
+		t.Fatalf("unexpected violations: %v", res.SchemaViolations)
+	}
+	for _, w := range warnings {
+		if strings.Contains(w, "Schema violation") {
+			t.Fatalf("unexpected warning: %s", w)
+		}
+	}
+
+	plain := ParseJSONStream(strings.NewReader(`{"type":"thread.started"}`), nil, nil, nil, nil)
+	if len(plain.SchemaViolations) != 0 {
+		t.Fatalf("ParseJSONStream should not validate, got %v", plain.SchemaViolations)
+	}
+}
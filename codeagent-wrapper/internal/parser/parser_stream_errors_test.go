@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONStream_CollectsErrorEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name: "codex turn.failed and error",
+			lines: []string{
+				`{"type":"thread.started","thread_id":"t1"}`,
+				`{"type":"error","message":"Reconnecting... 1/5"}`,
+				`{"type":"turn.failed","error":{"message":"stream disconnected"}}`,
+				`{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+			},
+			want: []string{"stream disconnected"},
+		},
+		{
+			name: "codex reconnect then completed turn",
+			lines: []string{
+				`{"type":"thread.started","thread_id":"t1"}`,
+				`{"type":"error","message":"Reconnecting... 1/5"}`,
+				`{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+				`{"type":"turn.completed"}`,
+			},
+			want: nil,
+		},
+		{
+			name: "claude error result",
+			lines: []string{
+				`{"type":"result","subtype":"error_max_turns","is_error":true,"session_id":"s1","result":""}`,
+			},
+			want: []string{"error_max_turns"},
+		},
+		{
+			name: "gemini failed result",
+			lines: []string{
+				`{"type":"init","session_id":"g1"}`,
+				`{"type":"result","status":"error","error":{"message":"quota exceeded"}}`,
+			},
+			want: []string{"quota exceeded"},
+		},
+		{
+			name: "clean stream",
+			lines: []string{
+				`{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`,
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ParseJSONStream(strings.NewReader(strings.Join(tt.lines, "\n")), nil, nil, nil, nil)
+			if !reflect.DeepEqual(res.Errors, tt.want) {
+				t.Fatalf("Errors = %#v, want %#v", res.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONStream_ErrorEventsKeepMessage(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"turn.failed","error":{"message":"boom"}}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"partial"}}`,
+	}, "\n")
+
+	res := ParseJSONStream(strings.NewReader(input), nil, nil, nil, nil)
+	if res.Message != "partial" || res.ThreadID != "t1" {
+		t.Fatalf("got message=%q thread=%q, want partial/t1", res.Message, res.ThreadID)
+	}
+}
+
+func TestParseJSONStreamWithOptions_OnReconnect(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"error","message":"Reconnecting... 1/5"}`,
+		`{"type":"error","message":"Reconnecting... 2/5"}`,
+		`{"type":"turn.started"}`,
+		`{"type":"error","message":"Reconnecting... 1/5"}`,
+		`{"type":"turn.failed","error":{"message":"stream disconnected"}}`,
+		`{"type":"error","message":"Reconnecting... 2/5"}`,
+	}
+	var got []int
+	ParseJSONStreamWithOptions(strings.NewReader(strings.Join(lines, "\n")), nil, nil, nil, nil, ParseOptions{
+		OnReconnect: func(consecutive int, message string) {
+			if !strings.HasPrefix(message, "Reconnecting") {
+				t.Errorf("unexpected message %q", message)
+			}
+			got = append(got, consecutive)
+		},
+	})
+	if want := []int{1, 2, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("OnReconnect counts = %v, want %v", got, want)
+	}
+}
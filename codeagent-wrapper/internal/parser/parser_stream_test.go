@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSONStreamWithOptions_OnText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "gemini deltas",
+			input: `{"type":"init","session_id":"g1"}
+{"type":"message","role":"assistant","content":"Hel","delta":true}
+{"type":"message","role":"assistant","content":"lo","delta":true}
+{"type":"result","status":"success"}`,
+			want: []string{"Hel", "lo"},
+		},
+		{
+			name: "codex whole messages",
+			input: `{"type":"thread.started","thread_id":"t1"}
+{"type":"item.completed","item":{"type":"agent_message","text":"first"}}
+{"type":"item.completed","item":{"type":"agent_message","text":"second"}}`,
+			want: []string{"first\n", "second\n"},
+		},
+		{
+			name: "claude result repeating the assistant text",
+			input: `{"type":"assistant","session_id":"c1","message":{"content":[{"type":"text","text":"done"}]}}
+{"type":"result","session_id":"c1","subtype":"success","result":"done"}`,
+			want: []string{"done\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			res := ParseJSONStreamWithOptions(strings.NewReader(tt.input), nil, nil, nil, nil, ParseOptions{
+				OnText: func(text string) { got = append(got, text) },
+			})
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("OnText calls = %q, want %q", got, tt.want)
+			}
+			if res.Message == "" {
+				t.Fatalf("final message should still be collected")
+			}
+		})
+	}
+}
+
+func TestParseJSONStreamWithOptions_OnEvent(t *testing.T) {
+	input := `{"type":"thread.started","thread_id":"t1"}
+not json
+{"type":"item.completed","item":{"type":"agent_message","text":"hi"}}
+{"type":"turn.completed"}`
+	var got []string
+	ParseJSONStreamWithOptions(strings.NewReader(input), nil, nil, nil, nil, ParseOptions{
+		OnEvent: func(eventType string) { got = append(got, eventType) },
+	})
+	want := []string{"thread.started", "item.completed", "turn.completed"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("OnEvent calls = %q, want %q", got, want)
+	}
+}
+
+func TestParseJSONStreamWithOptions_OnReasoning(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        []string
+		wantMessage string
+	}{
+		{
+			name: "codex reasoning items",
+			input: `{"type":"thread.started","thread_id":"t1"}
+{"type":"item.completed","item":{"type":"reasoning","text":"**Planning**\nread the tests first"}}
+{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+			want:        []string{"**Planning**\nread the tests first"},
+			wantMessage: "done",
+		},
+		{
+			name: "claude thinking blocks",
+			input: `{"type":"assistant","session_id":"c1","message":{"content":[{"type":"thinking","thinking":"check the diff"},{"type":"redacted_thinking","data":"x"},{"type":"text","text":"ok"}]}}
+{"type":"result","session_id":"c1","subtype":"success","result":"ok"}`,
+			want:        []string{"check the diff"},
+			wantMessage: "ok",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			res := ParseJSONStreamWithOptions(strings.NewReader(tt.input), nil, nil, nil, nil, ParseOptions{
+				OnReasoning: func(text string) { got = append(got, text) },
+			})
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("OnReasoning calls = %q, want %q", got, tt.want)
+			}
+			if res.Message != tt.wantMessage {
+				t.Fatalf("Message = %q, want %q (reasoning must not leak into it)", res.Message, tt.wantMessage)
+			}
+		})
+	}
+}
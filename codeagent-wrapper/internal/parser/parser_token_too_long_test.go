@@ -29,3 +29,49 @@ func TestParseJSONStream_SkipsOverlongLineAndContinues(t *testing.T) {
 		t.Fatalf("expected warning about overlong JSON line, got %v", warns)
 	}
 }
+
+func TestResolveMaxLineBytes(t *testing.T) {
+	tests := []struct {
+		in, want int
+	}{
+		{0, jsonLineMaxBytes},
+		{-1, jsonLineCeilingBytes},
+		{64, 64},
+		{jsonLineCeilingBytes + 1, jsonLineCeilingBytes},
+	}
+	for _, tt := range tests {
+		if got := resolveMaxLineBytes(tt.in); got != tt.want {
+			t.Errorf("resolveMaxLineBytes(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONStreamWithOptions_MaxLineBytes(t *testing.T) {
+	long := `{"type":"item.completed","item":{"type":"agent_message","text":"` + strings.Repeat("b", 11*1024*1024) + `"}}`
+	short := `{"type":"item.completed","item":{"type":"agent_message","text":"` + strings.Repeat("c", 100) + `"}}`
+
+	tests := []struct {
+		name      string
+		max       int
+		input     string
+		wantLen   int
+		wantWarns bool
+	}{
+		{"default cap skips", 0, long, 0, true},
+		{"ceiling keeps", -1, long, 11 * 1024 * 1024, false},
+		{"custom cap skips", 64, short, 0, true},
+		{"custom cap keeps", 1024, short, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warns []string
+			res := ParseJSONStreamWithOptions(strings.NewReader(tt.input), func(msg string) { warns = append(warns, msg) }, nil, nil, nil, ParseOptions{MaxLineBytes: tt.max})
+			if len(res.Message) != tt.wantLen {
+				t.Fatalf("message length = %d, want %d (warns=%v)", len(res.Message), tt.wantLen, warns)
+			}
+			if gotWarn := len(warns) > 0 && strings.Contains(warns[0], "Skipped overlong JSON line"); gotWarn != tt.wantWarns {
+				t.Fatalf("warns = %v, want overlong warning %v", warns, tt.wantWarns)
+			}
+		})
+	}
+}
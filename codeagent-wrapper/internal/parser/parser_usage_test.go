@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSONStream_TokenUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  TokenUsage
+	}{
+		{
+			name: "codex sums turns",
+			lines: []string{
+				`{"type":"thread.started","thread_id":"t1"}`,
+				`{"type":"turn.completed","usage":{"input_tokens":1000,"cached_input_tokens":400,"output_tokens":200}}`,
+				`{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+				`{"type":"turn.completed","usage":{"input_tokens":500,"output_tokens":50}}`,
+			},
+			want: TokenUsage{InputTokens: 1500, OutputTokens: 250, TotalTokens: 1750},
+		},
+		{
+			name: "claude result counts cache tokens as input",
+			lines: []string{
+				`{"type":"assistant","session_id":"c1","message":{"content":[{"type":"text","text":"done"}],"usage":{"input_tokens":3,"output_tokens":1}}}`,
+				`{"type":"result","subtype":"success","session_id":"c1","result":"done","usage":{"input_tokens":10,"cache_creation_input_tokens":100,"cache_read_input_tokens":1000,"output_tokens":20}}`,
+			},
+			want: TokenUsage{InputTokens: 1110, OutputTokens: 20, TotalTokens: 1130},
+		},
+		{
+			name: "gemini result stats",
+			lines: []string{
+				`{"type":"init","session_id":"g1"}`,
+				`{"type":"message","role":"assistant","content":"done","delta":true}`,
+				`{"type":"result","status":"success","stats":{"total_tokens":10300,"input_tokens":9000,"output_tokens":1200,"duration_ms":5000}}`,
+			},
+			want: TokenUsage{InputTokens: 9000, OutputTokens: 1200, TotalTokens: 10300},
+		},
+		{
+			name: "opencode step-finish parts",
+			lines: []string{
+				`{"type":"text","sessionID":"o1","part":{"type":"text","text":"done","messageID":"m1"}}`,
+				`{"type":"step_finish","sessionID":"o1","part":{"type":"step-finish","reason":"tool-calls","tokens":{"input":100,"output":10}}}`,
+				`{"type":"step_finish","sessionID":"o1","part":{"type":"step-finish","reason":"stop","tokens":{"input":200,"output":20}}}`,
+			},
+			want: TokenUsage{InputTokens: 300, OutputTokens: 30, TotalTokens: 330},
+		},
+		{
+			name: "no usage reported",
+			lines: []string{
+				`{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`,
+				`{"type":"turn.completed"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ParseJSONStream(strings.NewReader(strings.Join(tt.lines, "\n")), nil, nil, nil, nil)
+			if res.Usage != tt.want {
+				t.Fatalf("Usage = %+v, want %+v", res.Usage, tt.want)
+			}
+			if res.Message != "done" {
+				t.Fatalf("Message = %q, want %q", res.Message, "done")
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// validateEvent checks event against the minimal shape the parser relies on
+// for its type and returns one entry per missing or empty field. It is used
+// by ParseJSONStreamValidated to spot backend output format changes.
+func validateEvent(event UnifiedEvent) []string {
+	var problems []string
+	missing := func(field string) {
+		problems = append(problems, "missing "+field)
+	}
+
+	if strings.TrimSpace(event.Type) == "" {
+		missing("type")
+		return problems
+	}
+
+	// Opencode events are keyed by sessionID and carry their payload in part.
+	if event.OpencodeSessionID != "" {
+		var part OpencodePart
+		if len(event.Part) == 0 || json.Unmarshal(event.Part, &part) != nil {
+			missing("part")
+		} else if part.Type == "" {
+			missing("part.type")
+		}
+		return problems
+	}
+
+	switch event.Type {
+	case "thread.started":
+		if event.ThreadID == "" {
+			missing("thread_id")
+		}
+	case "item.started", "item.updated", "item.completed":
+		var item ItemContent
+		if len(event.Item) == 0 || json.Unmarshal(event.Item, &item) != nil {
+			missing("item")
+			break
+		}
+		if item.Type == "" {
+			missing("item.type")
+		}
+		if event.Type == "item.completed" && item.Type == "agent_message" && item.Text == nil {
+			missing("item.text")
+		}
+	case "turn.failed", "error":
+		if len(event.Error) == 0 && len(event.Message) == 0 {
+			missing("error or message")
+		}
+	case "init":
+		if event.SessionID == "" {
+			missing("session_id")
+		}
+	case "system":
+		if event.Subtype == "init" && event.SessionID == "" {
+			missing("session_id")
+		}
+	case "assistant":
+		if len(event.Message) == 0 {
+			missing("message")
+		}
+	case "result":
+		// Claude results carry a subtype, Gemini results a status.
+		if event.Subtype == "" && event.Status == "" {
+			missing("subtype or status")
+		}
+		if event.Subtype != "" && event.SessionID == "" {
+			missing("session_id")
+		}
+	case "message":
+		if event.Role == "" {
+			missing("role")
+		}
+	}
+	return problems
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
 func Truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -37,6 +40,20 @@ func SafeTruncate(s string, maxLen int) string {
 	return string(runes[:cutoff]) + "..."
 }
 
+// TruncateLines keeps the first maxLines lines of s and reports how many were
+// cut. maxLines <= 0 disables the cap; a trailing newline does not count as an
+// extra line.
+func TruncateLines(s string, maxLines int) (string, int) {
+	if maxLines <= 0 || s == "" {
+		return s, 0
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return s, 0
+	}
+	return strings.Join(lines[:maxLines], "\n"), len(lines) - maxLines
+}
+
 // SanitizeOutput removes ANSI escape sequences and control characters.
 func SanitizeOutput(s string) string {
 	var result strings.Builder
@@ -60,3 +77,86 @@ func SanitizeOutput(s string) string {
 	}
 	return result.String()
 }
+
+// StripControlSequences removes terminal control output that only makes sense
+// on a TTY: CSI and OSC escape sequences, other escapes, and C0 control
+// characters except newline and tab. Carriage-return progress redraws keep
+// only the last rewrite of each line and backspaces erase the preceding rune,
+// so captured text reads as it would have appeared on screen. Unlike
+// SanitizeOutput it preserves multi-byte UTF-8 text.
+func StripControlSequences(s string) string {
+	var lines []string
+	var line []rune
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\x1b':
+			i = skipEscapeSequence(runes, i)
+		case r == '\n':
+			lines = append(lines, string(line))
+			line = line[:0]
+		case r == '\r':
+			if i+1 < len(runes) && runes[i+1] == '\n' {
+				continue
+			}
+			line = line[:0]
+		case r == '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case r == '\t' || r >= 0x20 && r != 0x7f && (r < 0x80 || r > 0x9f):
+			line = append(line, r)
+		}
+	}
+	lines = append(lines, string(line))
+	return strings.Join(lines, "\n")
+}
+
+// skipEscapeSequence returns the index of the last rune of the escape sequence
+// starting at runes[start] (an ESC).
+func skipEscapeSequence(runes []rune, start int) int {
+	if start+1 >= len(runes) {
+		return start
+	}
+	switch runes[start+1] {
+	case '[': // CSI: parameters and intermediates, then a final byte in @-~.
+		for i := start + 2; i < len(runes); i++ {
+			if runes[i] >= 0x40 && runes[i] <= 0x7e {
+				return i
+			}
+		}
+		return len(runes) - 1
+	case ']': // OSC: terminated by BEL or ESC \.
+		for i := start + 2; i < len(runes); i++ {
+			if runes[i] == '\a' {
+				return i
+			}
+			if runes[i] == '\x1b' && i+1 < len(runes) && runes[i+1] == '\\' {
+				return i + 1
+			}
+		}
+		return len(runes) - 1
+	case '(', ')', '#': // Character set selection and similar take one more byte.
+		if start+2 < len(runes) {
+			return start + 2
+		}
+		return len(runes) - 1
+	default: // Two-byte escapes such as ESC 7 / ESC 8 / ESC M.
+		return start + 1
+	}
+}
+
+// secretPattern matches tokens that commonly carry credentials: API keys with
+// an sk- prefix (OpenAI, Anthropic), GitHub and Slack tokens, AWS access key
+// IDs and JWTs.
+var secretPattern = regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}|\bgh[pousr]_[A-Za-z0-9]{20,}|\bxox[abprs]-[A-Za-z0-9-]{10,}|\bAKIA[0-9A-Z]{16}\b|\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)
+
+// RedactSecrets masks anything in s that looks like a secret, keeping the
+// first and last 4 characters of each match (e.g. "sk-a****wxyz") so the
+// value stays recognisable without being usable.
+func RedactSecrets(s string) string {
+	return secretPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return m[:4] + "****" + m[len(m)-4:]
+	})
+}
@@ -5,10 +5,14 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"codeagent-wrapper/internal/logger"
 )
 
 // Paths contains worktree information
@@ -20,11 +24,17 @@ type Paths struct {
 
 // Hook points for testing
 var (
-	randReader  io.Reader = rand.Reader
-	timeNowFunc           = time.Now
-	execCommand           = exec.Command
+	randReader     io.Reader = rand.Reader
+	timeNowFunc              = time.Now
+	execCommand              = exec.Command
+	ownerPID                 = os.Getpid
+	processRunning           = logger.IsProcessRunning
 )
 
+// ownerFile is written into a worktree's git admin directory and records the
+// pid of the wrapper process that created it.
+const ownerFile = "codeagent-owner.pid"
+
 // generateTaskID creates a unique task ID in format: YYYYMMDD-{6 hex chars}
 func generateTaskID() (string, error) {
 	bytes := make([]byte, 3)
@@ -88,6 +98,7 @@ func CreateWorktree(projectDir string) (*Paths, error) {
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return nil, fmt.Errorf("failed to create worktree: %w\noutput: %s", err, string(output))
 	}
+	recordOwner(worktreeDir)
 
 	return &Paths{
 		Dir:    worktreeDir,
@@ -95,3 +106,248 @@ func CreateWorktree(projectDir string) (*Paths, error) {
 		TaskID: taskID,
 	}, nil
 }
+
+// UncommittedChanges returns the `git status --porcelain` entries for dir.
+// isRepo is false (and no error is returned) when dir is not inside a git
+// work tree.
+func UncommittedChanges(dir string) (changes []string, isRepo bool, err error) {
+	if dir == "" {
+		dir = "."
+	}
+	if !isGitRepo(dir) {
+		return nil, false, nil
+	}
+
+	cmd := execCommand("git", "-C", dir, "status", "--porcelain")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to get git status: %w", err)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			changes = append(changes, strings.TrimRight(line, "\r"))
+		}
+	}
+	return changes, true, nil
+}
+
+// FindWorktree returns the Paths CreateWorktree produced for taskID in the
+// repository containing projectDir. It fails when neither the worktree
+// directory nor the task branch exists.
+func FindWorktree(projectDir, taskID string) (*Paths, error) {
+	if projectDir == "" {
+		projectDir = "."
+	}
+	taskID = strings.TrimSpace(taskID)
+	if taskID == "" || strings.ContainsAny(taskID, `/\`) || strings.Contains(taskID, "..") {
+		return nil, fmt.Errorf("invalid worktree task id %q", taskID)
+	}
+	if !isGitRepo(projectDir) {
+		return nil, fmt.Errorf("not a git repository: %s", projectDir)
+	}
+	gitRoot, err := getGitRoot(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := &Paths{
+		Dir:    filepath.Join(gitRoot, ".worktrees", fmt.Sprintf("do-%s", taskID)),
+		Branch: fmt.Sprintf("do/%s", taskID),
+		TaskID: taskID,
+	}
+	_, statErr := os.Stat(paths.Dir)
+	if statErr != nil && !branchExists(gitRoot, paths.Branch) {
+		return nil, fmt.Errorf("no worktree or branch found for task %s in %s", taskID, gitRoot)
+	}
+	return paths, nil
+}
+
+// RemoveWorktree removes a worktree created by CreateWorktree; a directory
+// that was already deleted by hand is pruned instead. With deleteBranch the
+// task branch is deleted too, which git refuses while it holds commits that
+// are not merged into the repository's current branch.
+func RemoveWorktree(paths *Paths, deleteBranch bool) error {
+	if paths == nil || paths.Dir == "" {
+		return fmt.Errorf("worktree paths are empty")
+	}
+	gitRoot := worktreeRepoRoot(paths)
+
+	var cmd *exec.Cmd
+	if _, err := os.Stat(paths.Dir); err == nil {
+		cmd = execCommand("git", "-C", gitRoot, "worktree", "remove", paths.Dir)
+	} else {
+		cmd = execCommand("git", "-C", gitRoot, "worktree", "prune")
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove worktree %s: %w\noutput: %s", paths.Dir, err, string(output))
+	}
+
+	if !deleteBranch || paths.Branch == "" || !branchExists(gitRoot, paths.Branch) {
+		return nil
+	}
+	cmd = execCommand("git", "-C", gitRoot, "branch", "-d", paths.Branch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("removed worktree %s but kept branch %s: %w\noutput: %s", paths.Dir, paths.Branch, err, string(output))
+	}
+	return nil
+}
+
+// MergeWorktree merges the task branch into the branch named into, which must
+// be checked out in the main working tree (an empty into means whatever is
+// checked out there). A fast-forward is tried first; otherwise a merge commit
+// is created, and a conflicting merge is aborted and reported.
+func MergeWorktree(paths *Paths, into string) error {
+	if paths == nil || paths.Branch == "" {
+		return fmt.Errorf("worktree paths are empty")
+	}
+	gitRoot := worktreeRepoRoot(paths)
+
+	output, err := execCommand("git", "-C", gitRoot, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+	current := strings.TrimSpace(string(output))
+	if into = strings.TrimSpace(into); into == "" {
+		into = current
+	}
+	if current != into {
+		return fmt.Errorf("cannot merge %s into %s: %s has %s checked out", paths.Branch, into, gitRoot, current)
+	}
+
+	if err := execCommand("git", "-C", gitRoot, "merge", "--ff-only", paths.Branch).Run(); err == nil {
+		return nil
+	}
+	if output, err := execCommand("git", "-C", gitRoot, "merge", "--no-edit", paths.Branch).CombinedOutput(); err != nil {
+		_ = execCommand("git", "-C", gitRoot, "merge", "--abort").Run()
+		return fmt.Errorf("failed to merge %s into %s: %w\noutput: %s", paths.Branch, into, err, string(output))
+	}
+	return nil
+}
+
+// worktreeRepoRoot returns the repository root of a CreateWorktree layout
+// (<root>/.worktrees/do-<task_id>).
+func worktreeRepoRoot(paths *Paths) string {
+	return filepath.Dir(filepath.Dir(paths.Dir))
+}
+
+func branchExists(gitRoot, branch string) bool {
+	return execCommand("git", "-C", gitRoot, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil
+}
+
+// CleanupStale scans the .worktrees/do-* checkouts of the repository
+// containing projectDir and removes those whose creating wrapper process has
+// exited, or, for worktrees without an owner record, whose branch is merged
+// into HEAD. Merged branches are deleted as well; unmerged ones are kept so
+// no commits are lost, and git itself refuses to remove a dirty checkout.
+// Symlinks and paths resolving outside .worktrees are never touched.
+func CleanupStale(projectDir string) (logger.CleanupStats, error) {
+	var stats logger.CleanupStats
+	if projectDir == "" {
+		projectDir = "."
+	}
+	if !isGitRepo(projectDir) {
+		return stats, fmt.Errorf("not a git repository: %s", projectDir)
+	}
+	gitRoot, err := getGitRoot(projectDir)
+	if err != nil {
+		return stats, err
+	}
+	worktreesDir := filepath.Join(gitRoot, ".worktrees")
+	matches, err := filepath.Glob(filepath.Join(worktreesDir, "do-*"))
+	if err != nil {
+		return stats, fmt.Errorf("failed to scan %s: %w", worktreesDir, err)
+	}
+
+	for _, dir := range matches {
+		stats.Scanned++
+		if unsafe, reason := logger.IsUnsafeFile(dir, worktreesDir); unsafe {
+			stats.Kept++
+			if reason != "" {
+				stats.KeptFiles = append(stats.KeptFiles, fmt.Sprintf("%s (%s)", dir, reason))
+			} else {
+				stats.KeptFiles = append(stats.KeptFiles, dir)
+			}
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, fmt.Sprintf("%s (not a directory)", dir))
+			continue
+		}
+
+		taskID := strings.TrimPrefix(filepath.Base(dir), "do-")
+		paths := &Paths{Dir: dir, Branch: "do/" + taskID, TaskID: taskID}
+		merged := branchMerged(gitRoot, paths.Branch)
+		pid, recorded := readOwner(dir)
+		switch {
+		case recorded && processRunning(pid):
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, fmt.Sprintf("%s (owner pid %d still running)", dir, pid))
+			continue
+		case !recorded && !merged:
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, fmt.Sprintf("%s (branch %s not merged)", dir, paths.Branch))
+			continue
+		}
+
+		if err := RemoveWorktree(paths, merged); err != nil {
+			stats.Errors++
+			stats.Kept++
+			stats.KeptFiles = append(stats.KeptFiles, fmt.Sprintf("%s (%s)", dir, firstLine(err.Error())))
+			continue
+		}
+		stats.Deleted++
+		stats.DeletedFiles = append(stats.DeletedFiles, dir)
+	}
+	return stats, nil
+}
+
+// recordOwner stores the current pid in the worktree's git admin directory,
+// outside the checkout so it never shows up as an untracked file. Failures
+// only cost CleanupStale the owner check, so they are ignored.
+func recordOwner(worktreeDir string) {
+	adminDir, err := worktreeAdminDir(worktreeDir)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(adminDir, ownerFile), []byte(strconv.Itoa(ownerPID())+"\n"), 0o600)
+}
+
+func readOwner(worktreeDir string) (int, bool) {
+	adminDir, err := worktreeAdminDir(worktreeDir)
+	if err != nil {
+		return 0, false
+	}
+	data, err := os.ReadFile(filepath.Join(adminDir, ownerFile))
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+func worktreeAdminDir(worktreeDir string) (string, error) {
+	output, err := execCommand("git", "-C", worktreeDir, "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git dir of %s: %w", worktreeDir, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// branchMerged reports whether branch exists and is an ancestor of HEAD.
+func branchMerged(gitRoot, branch string) bool {
+	if !branchExists(gitRoot, branch) {
+		return false
+	}
+	return execCommand("git", "-C", gitRoot, "merge-base", "--is-ancestor", "refs/heads/"+branch, "HEAD").Run() == nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -8,15 +8,20 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"codeagent-wrapper/internal/logger"
 )
 
 func resetHooks() {
 	randReader = rand.Reader
 	timeNowFunc = time.Now
 	execCommand = exec.Command
+	ownerPID = os.Getpid
+	processRunning = logger.IsProcessRunning
 }
 
 func TestGenerateTaskID(t *testing.T) {
@@ -447,3 +452,216 @@ func (f *fixedReader) Read(p []byte) (n int, err error) {
 	f.pos += n
 	return n, nil
 }
+
+func TestUncommittedChanges(t *testing.T) {
+	defer resetHooks()
+
+	tmpDir := t.TempDir()
+	if _, isRepo, err := UncommittedChanges(tmpDir); err != nil || isRepo {
+		t.Fatalf("UncommittedChanges(non-git) = isRepo %v, err %v; want false, nil", isRepo, err)
+	}
+
+	if err := exec.Command("git", "-C", tmpDir, "init").Run(); err != nil {
+		t.Fatalf("failed to init git repo: %v", err)
+	}
+	changes, isRepo, err := UncommittedChanges(tmpDir)
+	if err != nil || !isRepo || len(changes) != 0 {
+		t.Fatalf("UncommittedChanges(clean) = %v, %v, %v; want no changes", changes, isRepo, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changes, isRepo, err = UncommittedChanges(tmpDir)
+	if err != nil || !isRepo {
+		t.Fatalf("UncommittedChanges(dirty) isRepo = %v, err = %v", isRepo, err)
+	}
+	if len(changes) != 1 || changes[0] != "?? new.txt" {
+		t.Fatalf("UncommittedChanges(dirty) = %q, want [\"?? new.txt\"]", changes)
+	}
+}
+
+// initCommittedRepo creates a git repository with one commit on branch main.
+func initCommittedRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-b", "main"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	gitCommitFile(t, dir, "base.txt", "base")
+	return dir
+}
+
+func gitCommitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", name}, {"commit", "-m", "add " + name}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestMergeAndRemoveWorktree(t *testing.T) {
+	defer resetHooks()
+	repo := initCommittedRepo(t)
+
+	paths, err := CreateWorktree(repo)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	found, err := FindWorktree(repo, paths.TaskID)
+	if err != nil || found.Dir != paths.Dir || found.Branch != paths.Branch {
+		t.Fatalf("FindWorktree() = %+v, %v; want %+v", found, err, paths)
+	}
+
+	gitCommitFile(t, paths.Dir, "agent.txt", "from agent")
+	if err := MergeWorktree(paths, "other"); err == nil {
+		t.Fatalf("MergeWorktree() into a branch that is not checked out should fail")
+	}
+	if err := MergeWorktree(paths, ""); err != nil {
+		t.Fatalf("MergeWorktree() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(repo, "agent.txt")); err != nil || string(data) != "from agent" {
+		t.Fatalf("merged file = %q, %v; want the agent's commit in main", data, err)
+	}
+
+	if err := RemoveWorktree(paths, true); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	if _, err := os.Stat(paths.Dir); !os.IsNotExist(err) {
+		t.Fatalf("worktree dir still exists: %v", err)
+	}
+	if branchExists(repo, paths.Branch) {
+		t.Fatalf("branch %s should be deleted", paths.Branch)
+	}
+	if _, err := FindWorktree(repo, paths.TaskID); err == nil {
+		t.Fatalf("FindWorktree() should fail once the worktree and branch are gone")
+	}
+}
+
+func TestRemoveWorktree_KeepsUnmergedBranch(t *testing.T) {
+	defer resetHooks()
+	repo := initCommittedRepo(t)
+
+	paths, err := CreateWorktree(repo)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	gitCommitFile(t, paths.Dir, "unmerged.txt", "work in progress")
+
+	err = RemoveWorktree(paths, true)
+	if err == nil || !strings.Contains(err.Error(), "kept branch "+paths.Branch) {
+		t.Fatalf("RemoveWorktree() error = %v, want the unmerged branch to be kept", err)
+	}
+	if _, statErr := os.Stat(paths.Dir); !os.IsNotExist(statErr) {
+		t.Fatalf("worktree dir should be removed even when the branch is kept: %v", statErr)
+	}
+	if !branchExists(repo, paths.Branch) {
+		t.Fatalf("unmerged branch %s should be kept", paths.Branch)
+	}
+}
+
+func TestRemoveWorktree_PrunesMissingDir(t *testing.T) {
+	defer resetHooks()
+	repo := initCommittedRepo(t)
+
+	paths, err := CreateWorktree(repo)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	if err := os.RemoveAll(paths.Dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindWorktree(repo, paths.TaskID); err != nil {
+		t.Fatalf("FindWorktree() should still find the branch: %v", err)
+	}
+	if err := RemoveWorktree(paths, true); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	out, err := exec.Command("git", "-C", repo, "worktree", "list").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), paths.Dir) {
+		t.Fatalf("stale worktree was not pruned:\n%s", out)
+	}
+}
+
+func TestFindWorktree_InvalidTaskID(t *testing.T) {
+	for _, id := range []string{"", " ", "../x", "a/b"} {
+		if _, err := FindWorktree(".", id); err == nil || !strings.Contains(err.Error(), "invalid worktree task id") {
+			t.Fatalf("FindWorktree(%q) error = %v, want invalid id", id, err)
+		}
+	}
+}
+
+func TestCleanupStale(t *testing.T) {
+	defer resetHooks()
+	repo := initCommittedRepo(t)
+	addWorktree := func(taskID string) string {
+		dir := filepath.Join(repo, ".worktrees", "do-"+taskID)
+		if out, err := exec.Command("git", "-C", repo, "worktree", "add", "-b", "do/"+taskID, dir).CombinedOutput(); err != nil {
+			t.Fatalf("git worktree add: %v\n%s", err, out)
+		}
+		return dir
+	}
+
+	owned, err := CreateWorktree(repo)
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	gitCommitFile(t, owned.Dir, "owned.txt", "unmerged work")
+	merged := addWorktree("merged")
+	unmerged := addWorktree("unmerged")
+	gitCommitFile(t, unmerged, "wip.txt", "wip")
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(repo, ".worktrees", "do-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner (this test process) is alive: only the merged worktree goes.
+	stats, err := CleanupStale(repo)
+	if err != nil {
+		t.Fatalf("CleanupStale() error = %v", err)
+	}
+	if stats.Scanned != 4 || stats.Deleted != 1 || stats.Kept != 3 || stats.Errors != 0 {
+		t.Fatalf("stats = %+v, want 4 scanned / 1 deleted / 3 kept", stats)
+	}
+	if len(stats.DeletedFiles) != 1 || stats.DeletedFiles[0] != merged {
+		t.Fatalf("DeletedFiles = %v, want [%s]", stats.DeletedFiles, merged)
+	}
+	if branchExists(repo, "do/merged") {
+		t.Fatalf("merged branch should be deleted")
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("symlink target must not be touched: %v", err)
+	}
+	if !strings.Contains(strings.Join(stats.KeptFiles, "\n"), "refusing to delete symlink") {
+		t.Fatalf("KeptFiles = %v, want the symlink refused", stats.KeptFiles)
+	}
+
+	// Once the owner is gone its checkout is removed but the unmerged branch stays.
+	processRunning = func(int) bool { return false }
+	stats, err = CleanupStale(repo)
+	if err != nil {
+		t.Fatalf("CleanupStale() error = %v", err)
+	}
+	if stats.Deleted != 1 || stats.DeletedFiles[0] != owned.Dir {
+		t.Fatalf("stats = %+v, want only %s deleted", stats, owned.Dir)
+	}
+	if !branchExists(repo, owned.Branch) {
+		t.Fatalf("unmerged branch %s should be kept", owned.Branch)
+	}
+	if _, err := os.Stat(unmerged); err != nil {
+		t.Fatalf("unmerged worktree without an owner should be kept: %v", err)
+	}
+}